@@ -1,9 +1,16 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/aykay76/ici/internal/container"
+	"github.com/aykay76/ici/internal/git"
 	"github.com/aykay76/ici/internal/parser"
+	"github.com/aykay76/ici/internal/report"
 	"github.com/aykay76/ici/internal/runner"
 	"github.com/spf13/cobra"
 )
@@ -16,28 +23,131 @@ var runCmd = &cobra.Command{
 Examples:
   ici run .github/workflows/test.yml
   ici run .github/workflows/build.yml --job build
-  ici run workflow.yml --event push`,
-	Args: cobra.ExactArgs(1),
+  ici run .github/workflows/ci.yml --parallel 4
+  ici run --all-workflows --event pull_request
+  ici run .github/workflows/test.yml --matrix go=1.25 --matrix os=ubuntu-latest
+  ici run .github/workflows/test.yml --workspace-mode copy
+  ici run .github/workflows/deploy.yml --secret TOKEN=abc --secret-file .secrets
+  ici run .github/workflows/deploy.yml --var DEPLOY_REGION=eu-west-1 --var-file vars.yml
+  ici run workflow.yml --event push
+  ici run workflow.yml --event pull_request --event-file pr.json
+  ici run release.yml --event workflow_dispatch --input version=1.2.0 --input dry-run=true
+  ici run .github/workflows/test.yml --runtime api
+  ici run .github/workflows/test.yml --report json=out.json --report junit=out.xml`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: runWorkflow,
 }
 
 var (
-	jobName   string
-	eventName string
-	dryRun    bool
+	jobName      string
+	eventName    string
+	dryRun       bool
+	parallel     int
+	matrix       []string
+	wsMode       string
+	secrets      []string
+	secretFile   string
+	vars         []string
+	varFile      string
+	eventFile    string
+	inputs       []string
+	allWorkflows bool
+	runtimeName  string
+	reports      []string
+	quiet        bool
 )
 
 func init() {
 	rootCmd.AddCommand(runCmd)
 	runCmd.Flags().StringVarP(&jobName, "job", "j", "", "specific job to run (default: all jobs)")
 	runCmd.Flags().StringVarP(&eventName, "event", "e", "push", "event that triggers the workflow")
+	runCmd.Flags().StringVar(&eventFile, "event-file", "", "JSON event payload (default: synthesized for the event)")
+	runCmd.Flags().StringArrayVar(&inputs, "input", nil, "workflow_dispatch input as key=value (repeatable)")
 	runCmd.Flags().BoolVar(&dryRun, "dry-run", false, "parse and plan without executing")
+	runCmd.Flags().IntVarP(&parallel, "parallel", "p", 1, "maximum number of independent jobs to run concurrently")
+	runCmd.Flags().StringArrayVar(&matrix, "matrix", nil, "only run matrix combinations with key=value (repeatable)")
+	runCmd.Flags().StringVar(&wsMode, "workspace-mode", runner.WorkspaceBind, "how the repository reaches job containers (bind, copy)")
+	runCmd.Flags().StringArrayVar(&secrets, "secret", nil, "secret as NAME=VALUE, or NAME to read it from the environment (repeatable)")
+	runCmd.Flags().StringVar(&secretFile, "secret-file", "", "dotenv file with secrets")
+	runCmd.Flags().StringArrayVar(&vars, "var", nil, "configuration variable as NAME=VALUE (repeatable)")
+	runCmd.Flags().StringVar(&varFile, "var-file", "", "YAML file with configuration variables")
+	runCmd.Flags().StringVar(&runtimeName, "runtime", "cli", "how containers are managed: cli (podman or docker CLI) or api (engine API socket)")
+	runCmd.Flags().StringArrayVar(&reports, "report", nil, "write a report of the run as format=path, format being json or junit (repeatable)")
+	runCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "do not show the output of steps (it is still included in reports)")
+	runCmd.Flags().BoolVar(&allWorkflows, "all-workflows", false, "run every workflow in .github/workflows that triggers on the event for the local changes")
 }
 
 func runWorkflow(cmd *cobra.Command, args []string) error {
-	workflowFile := args[0]
 	verbose, _ := cmd.Flags().GetBool("verbose")
 
+	switch {
+	case allWorkflows && len(args) > 0:
+		return errors.New("--all-workflows cannot be combined with a workflow file")
+	case allWorkflows && jobName != "":
+		return errors.New("--job cannot be combined with --all-workflows")
+	case !allWorkflows && len(args) == 0:
+		return errors.New("requires a workflow file, or --all-workflows")
+	}
+
+	if parallel < 1 {
+		return fmt.Errorf("--parallel must be at least 1, got %d", parallel)
+	}
+
+	matrixFilter, err := parseKeyValues("--matrix", matrix)
+	if err != nil {
+		return err
+	}
+	if !runner.ValidWorkspaceMode(wsMode) {
+		return fmt.Errorf("unsupported --workspace-mode %q (use %s or %s)", wsMode, runner.WorkspaceBind, runner.WorkspaceCopy)
+	}
+
+	secretValues, err := loadSecrets(secrets, secretFile)
+	if err != nil {
+		return err
+	}
+	varValues, err := loadVars(vars, varFile)
+	if err != nil {
+		return err
+	}
+	inputValues, err := parseKeyValues("--input", inputs)
+	if err != nil {
+		return err
+	}
+	if len(inputValues) > 0 && eventName != "workflow_dispatch" {
+		return errors.New("--input requires --event workflow_dispatch")
+	}
+	newRuntime, err := runtimeFactory(runtimeName)
+	if err != nil {
+		return err
+	}
+	reportFiles, err := parseReports(reports)
+	if err != nil {
+		return err
+	}
+	var event map[string]interface{}
+	if eventFile != "" {
+		if event, err = runner.ReadEventFile(eventFile); err != nil {
+			return err
+		}
+	}
+
+	opts := runner.Options{
+		Verbose:       verbose,
+		Parallel:      parallel,
+		Matrix:        matrixFilter,
+		WorkspaceMode: wsMode,
+		Secrets:       secretValues,
+		Vars:          varValues,
+		Event:         event,
+		Inputs:        inputValues,
+		NewRuntime:    newRuntime,
+		Quiet:         quiet,
+	}
+	if allWorkflows {
+		return runAllWorkflows(opts, reportFiles)
+	}
+
+	workflowFile := args[0]
 	if verbose {
 		fmt.Printf("Running workflow: %s\n", workflowFile)
 		fmt.Printf("Event: %s\n", eventName)
@@ -57,12 +167,236 @@ func runWorkflow(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Jobs found: %d\n", len(workflow.Jobs))
 	}
 
+	// A workflow that does not trigger on the event would not run on
+	// GitHub: refuse an explicitly requested event, warn for the default.
+	if events := workflow.Events(); len(events) > 0 && !workflow.TriggeredBy(eventName) {
+		msg := fmt.Sprintf("workflow does not run on %s (on: %s)", eventName, strings.Join(events, ", "))
+		if cmd.Flags().Changed("event") {
+			return errors.New(msg)
+		}
+		fmt.Printf("⚠️  %s; running it anyway\n", msg)
+	}
+
 	if dryRun {
 		fmt.Println("Dry run mode - workflow parsed successfully")
 		return nil
 	}
 
+	workspace, err := resolveWorkspace(workflowFile)
+	if err != nil {
+		return err
+	}
+	if verbose {
+		fmt.Printf("Workspace: %s (%s)\n", workspace, wsMode)
+	}
+
 	// Execute the workflow
-	executor := runner.NewExecutor(verbose)
-	return executor.Run(workflow, jobName, eventName)
+	opts.Workspace = workspace
+	executor := runner.NewExecutorWithOptions(opts)
+	result, err := executor.RunWithResult(workflow, jobName, eventName)
+	if result == nil {
+		return err
+	}
+	if reportErr := writeReports(reportFiles, []*runner.RunResult{result}); err == nil {
+		err = reportErr
+	}
+	return err
+}
+
+// runAllWorkflows runs every workflow of the repository that GitHub would
+// run for the event, given the branch, tag and path filters of its triggers
+// and the local changes.
+func runAllWorkflows(opts runner.Options, reportFiles map[string]string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to determine working directory: %w", err)
+	}
+	workspace, err := git.TopLevel(cwd)
+	if err != nil {
+		workspace = cwd
+	}
+	opts.Workspace = workspace
+
+	files, err := workflowFiles(filepath.Join(workspace, ".github", "workflows"))
+	if err != nil {
+		return err
+	}
+	state := triggerState(workspace, eventName)
+	if opts.Verbose {
+		fmt.Printf("Ref: %s\n", state.Ref)
+		fmt.Printf("Changed files: %d\n", len(state.ChangedFiles))
+	}
+
+	var ran int
+	var failed []string
+	var results []*runner.RunResult
+	for _, file := range files {
+		rel, _ := filepath.Rel(workspace, file)
+		workflow, err := parser.ParseWorkflow(file)
+		if err != nil {
+			fmt.Printf("✗ %s: failed to parse workflow: %v\n", rel, err)
+			failed = append(failed, rel)
+			continue
+		}
+		if ok, reason := workflow.On.Match(eventName, state); !ok {
+			fmt.Printf("- Skipping %s: %s\n", rel, reason)
+			continue
+		}
+
+		ran++
+		fmt.Printf("=== Workflow: %s (%s) ===\n", workflow.Name, rel)
+		if dryRun {
+			continue
+		}
+		result, err := runner.NewExecutorWithOptions(opts).RunWithResult(workflow, "", eventName)
+		if result != nil {
+			results = append(results, result)
+		}
+		if err != nil {
+			fmt.Printf("✗ %s: %v\n", rel, err)
+			failed = append(failed, rel)
+		}
+	}
+
+	if ran == 0 {
+		fmt.Printf("No workflow runs on %s for the current changes\n", eventName)
+	}
+	if !dryRun {
+		if err := writeReports(reportFiles, results); err != nil {
+			return err
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("workflow(s) failed: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// workflowFiles returns the workflow files in dir, sorted by name.
+func workflowFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflows: %w", err)
+	}
+	var files []string
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if !e.IsDir() && (ext == ".yml" || ext == ".yaml") {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	return files, nil
+}
+
+// triggerState describes what trigger filters are evaluated against: the
+// pending push of the current branch, compared with its upstream branch, or
+// a pull request from it into the default branch.
+func triggerState(workspace string, event string) parser.TriggerState {
+	var state parser.TriggerState
+	info, err := git.Inspect(workspace)
+	if err != nil {
+		return state
+	}
+	state.Ref = info.Ref
+
+	defaultBranch := git.DefaultBranch(workspace)
+	var bases []string
+	switch event {
+	case "pull_request", "pull_request_target":
+		state.Ref = "refs/heads/" + defaultBranch
+	default:
+		if upstream, err := git.Upstream(workspace); err == nil {
+			bases = append(bases, upstream)
+		}
+	}
+	bases = append(bases, "origin/"+defaultBranch, defaultBranch, "HEAD")
+	for _, base := range bases {
+		if files, err := git.ChangedFiles(workspace, base); err == nil {
+			state.ChangedFiles = files
+			break
+		}
+	}
+	return state
+}
+
+// parseKeyValues parses repeated key=value flag values into a map.
+func parseKeyValues(flag string, values []string) (map[string]string, error) {
+	out := make(map[string]string, len(values))
+	for _, kv := range values {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid %s value %q: expected key=value", flag, kv)
+		}
+		out[strings.TrimSpace(key)] = value
+	}
+	return out, nil
+}
+
+// parseReports parses the --report values, format=path, into the path of
+// each report by format.
+func parseReports(values []string) (map[string]string, error) {
+	files, err := parseKeyValues("--report", values)
+	if err != nil {
+		return nil, err
+	}
+	for format, path := range files {
+		if !report.ValidFormat(format) {
+			return nil, fmt.Errorf("unsupported --report format %q (use %s)", format, strings.Join(report.Formats, " or "))
+		}
+		if path == "" {
+			return nil, fmt.Errorf("--report %s needs a file path", format)
+		}
+	}
+	return files, nil
+}
+
+// writeReports writes the reports of the runs requested with --report.
+func writeReports(files map[string]string, results []*runner.RunResult) error {
+	for _, format := range report.Formats {
+		path, ok := files[format]
+		if !ok {
+			continue
+		}
+		if err := report.WriteFile(format, path, results); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s report to %s\n", format, path)
+	}
+	return nil
+}
+
+// resolveWorkspace returns the root of the git repository containing the
+// workflow file, falling back to the current directory outside a repository.
+func resolveWorkspace(workflowFile string) (string, error) {
+	dir, err := filepath.Abs(filepath.Dir(workflowFile))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve workflow directory: %w", err)
+	}
+	if root, err := git.TopLevel(dir); err == nil {
+		return root, nil
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine working directory: %w", err)
+	}
+	return cwd, nil
+}
+
+// runtimeFactory returns the container runtime factory for --runtime. The
+// api runtime talks to the socket of the local podman or docker service.
+func runtimeFactory(name string) (func(verbose bool) container.Runtime, error) {
+	switch name {
+	case "cli":
+		return container.NewRuntime, nil
+	case "api":
+		socket, err := container.DefaultSocket()
+		if err != nil {
+			return nil, err
+		}
+		return func(verbose bool) container.Runtime {
+			return container.NewAPIRuntime(socket, verbose)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported --runtime %q (use cli or api)", name)
+	}
 }
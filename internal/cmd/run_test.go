@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseReports(t *testing.T) {
+	got, err := parseReports([]string{"json=out.json", "junit=reports/ici.xml"})
+	if err != nil {
+		t.Fatalf("parseReports failed: %v", err)
+	}
+	want := map[string]string{"json": "out.json", "junit": "reports/ici.xml"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected reports: %v", got)
+	}
+
+	for value, msg := range map[string]string{
+		"html=out.html": "unsupported --report format",
+		"junit=":        "needs a file path",
+		"out.xml":       "expected key=value",
+	} {
+		if _, err := parseReports([]string{value}); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("parseReports(%q) error = %v, want %q", value, err, msg)
+		}
+	}
+}
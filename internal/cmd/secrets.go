@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// loadSecrets builds the secrets for a run from a dotenv file and --secret
+// flags, which override the file. A --secret flag without a value passes
+// the variable of the same name through from the environment.
+func loadSecrets(flags []string, file string) (map[string]string, error) {
+	secrets := make(map[string]string)
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret file: %w", err)
+		}
+		values, err := parseDotenv(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret file %s: %w", file, err)
+		}
+		for k, v := range values {
+			secrets[k] = v
+		}
+	}
+
+	for _, flag := range flags {
+		name, value, ok := strings.Cut(flag, "=")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("invalid --secret value %q: expected NAME=VALUE or NAME", flag)
+		}
+		if !ok {
+			env, found := os.LookupEnv(name)
+			if !found {
+				return nil, fmt.Errorf("--secret %s: environment variable %s is not set", name, name)
+			}
+			value = env
+		}
+		secrets[name] = value
+	}
+	return secrets, nil
+}
+
+// parseDotenv parses dotenv-style NAME=VALUE lines. Blank lines, comments
+// and an `export ` prefix are ignored; values may be single quoted (taken
+// literally) or double quoted (with \n, \" and \\ escapes, spanning lines).
+func parseDotenv(data string) (map[string]string, error) {
+	out := make(map[string]string)
+	lines := strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("line %d: expected NAME=VALUE", i+1)
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case strings.HasPrefix(value, `"`):
+			start := i
+			for !closesDoubleQuote(value) {
+				if i++; i >= len(lines) {
+					return nil, fmt.Errorf("line %d: unterminated double quote", start+1)
+				}
+				value += "\n" + lines[i]
+			}
+			end := strings.LastIndex(value, `"`)
+			unquoted, err := strconv.Unquote(strings.ReplaceAll(value[:end+1], "\n", `\n`))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", start+1, err)
+			}
+			value = unquoted
+		case strings.HasPrefix(value, "'"):
+			end := strings.LastIndex(value, "'")
+			if end == 0 {
+				return nil, fmt.Errorf("line %d: unterminated single quote", i+1)
+			}
+			value = value[1:end]
+		default:
+			if j := strings.Index(value, " #"); j >= 0 {
+				value = strings.TrimSpace(value[:j])
+			}
+		}
+		out[name] = value
+	}
+	return out, nil
+}
+
+// closesDoubleQuote reports whether a value starting with a double quote
+// contains its unescaped closing quote.
+func closesDoubleQuote(value string) bool {
+	escaped := false
+	for _, r := range value[1:] {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			return true
+		}
+	}
+	return false
+}
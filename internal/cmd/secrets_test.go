@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	data := "# comment\n" +
+		"TOKEN=abc123\n" +
+		"export REGION = eu-west-1 # inline comment\n" +
+		"LITERAL='a $b \\n'\n" +
+		"\n" +
+		"CERT=\"line1\nline2\"\n" +
+		"ESCAPED=\"say \\\"hi\\\"\\n\"\n"
+	got, err := parseDotenv(data)
+	if err != nil {
+		t.Fatalf("parseDotenv failed: %v", err)
+	}
+	want := map[string]string{
+		"TOKEN":   "abc123",
+		"REGION":  "eu-west-1",
+		"LITERAL": `a $b \n`,
+		"CERT":    "line1\nline2",
+		"ESCAPED": "say \"hi\"\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected values: %#v", got)
+	}
+
+	for _, bad := range []string{"NOVALUE\n", "A=\"unterminated\n", "=x\n"} {
+		if _, err := parseDotenv(bad); err == nil {
+			t.Fatalf("expected %q to fail", bad)
+		}
+	}
+}
+
+func TestLoadSecrets(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ".secrets")
+	if err := os.WriteFile(file, []byte("TOKEN=from-file\nOTHER=x\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ICI_TEST_SECRET", "from-env")
+
+	got, err := loadSecrets([]string{"TOKEN=from-flag", "ICI_TEST_SECRET"}, file)
+	if err != nil {
+		t.Fatalf("loadSecrets failed: %v", err)
+	}
+	want := map[string]string{"TOKEN": "from-flag", "OTHER": "x", "ICI_TEST_SECRET": "from-env"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected secrets: %v", got)
+	}
+
+	if _, err := loadSecrets([]string{"ICI_TEST_UNSET_SECRET"}, ""); err == nil {
+		t.Fatalf("expected an unset environment variable to fail")
+	}
+}
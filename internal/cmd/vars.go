@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadVars builds the configuration variables for a run from a YAML file
+// mapping names to values and --var flags, which override the file.
+func loadVars(flags []string, file string) (map[string]string, error) {
+	vars := make(map[string]string)
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read var file: %w", err)
+		}
+		values, err := parseVarFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid var file %s: %w", file, err)
+		}
+		for k, v := range values {
+			vars[k] = v
+		}
+	}
+
+	values, err := parseKeyValues("--var", flags)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range values {
+		vars[k] = v
+	}
+	return vars, nil
+}
+
+// parseVarFile parses a YAML mapping of variable names to scalar values.
+// Like GitHub configuration variables, every value is a string.
+func parseVarFile(data []byte) (map[string]string, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	out := make(map[string]string)
+	if len(node.Content) == 0 {
+		return out, nil
+	}
+	root := node.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: expected a mapping of names to values", root.Line)
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if value.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("line %d: value of %s must be a string, number or boolean", value.Line, key.Value)
+		}
+		out[key.Value] = value.Value
+	}
+	return out, nil
+}
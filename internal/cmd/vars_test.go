@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadVars(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "vars.yml")
+	data := "DEPLOY_REGION: eu-west-1\nGO_VERSION: 1.25\nENABLED: true\nEMPTY:\n"
+	if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := loadVars([]string{"DEPLOY_REGION=us-east-1"}, file)
+	if err != nil {
+		t.Fatalf("loadVars failed: %v", err)
+	}
+	want := map[string]string{"DEPLOY_REGION": "us-east-1", "GO_VERSION": "1.25", "ENABLED": "true", "EMPTY": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected vars: %v", got)
+	}
+
+	if _, err := parseVarFile([]byte("NESTED:\n  a: b\n")); err == nil {
+		t.Fatalf("expected a nested value to fail")
+	}
+	if _, err := parseVarFile([]byte("- a\n")); err == nil {
+		t.Fatalf("expected a list to fail")
+	}
+	if _, err := loadVars([]string{"novalue"}, ""); err == nil {
+		t.Fatalf("expected an invalid --var to fail")
+	}
+}
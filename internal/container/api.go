@@ -0,0 +1,816 @@
+package container
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiVersion is the Docker Engine API version requested. Podman serves the
+// same API on its socket.
+const apiVersion = "v1.41"
+
+// APIRuntime implements Runtime with the Docker Engine API, which both
+// Docker and Podman serve on a local Unix socket. Unlike Manager it does
+// not start a CLI process per operation, and commands report their exit
+// codes and stream stdout and stderr separately.
+type APIRuntime struct {
+	socket  string
+	verbose bool
+	client  *http.Client
+	stdout  io.Writer
+	stderr  io.Writer
+
+	mu sync.Mutex
+	// auths holds the X-Registry-Auth header value per registry.
+	auths map[string]string
+}
+
+// NewAPIRuntime returns a runtime that talks to the API served on the Unix
+// socket at path.
+func NewAPIRuntime(socket string, verbose bool) *APIRuntime {
+	a := &APIRuntime{socket: socket, verbose: verbose, auths: make(map[string]string)}
+	a.client = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return a.dial(ctx)
+		},
+	}}
+	return a
+}
+
+// DefaultSocket returns the API socket of the local container engine: the
+// one CONTAINER_HOST or DOCKER_HOST point at, else the first rootless
+// Podman, rootful Podman or Docker socket that exists.
+func DefaultSocket() (string, error) {
+	for _, env := range []string{"CONTAINER_HOST", "DOCKER_HOST"} {
+		if v := os.Getenv(env); v != "" {
+			if !strings.HasPrefix(v, "unix://") {
+				return "", fmt.Errorf("%s=%s: only unix:// sockets are supported", env, v)
+			}
+			return strings.TrimPrefix(v, "unix://"), nil
+		}
+	}
+	var candidates []string
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, "podman", "podman.sock"))
+	}
+	candidates = append(candidates, "/run/podman/podman.sock", "/var/run/docker.sock")
+	for _, c := range candidates {
+		if info, err := os.Stat(c); err == nil && info.Mode()&fs.ModeSocket != 0 {
+			return c, nil
+		}
+	}
+	return "", errors.New("no container engine socket found: start the podman or docker service, or set CONTAINER_HOST")
+}
+
+func (a *APIRuntime) dial(ctx context.Context) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, "unix", a.socket)
+}
+
+// SetOutput sets where the output of commands and verbose output go.
+func (a *APIRuntime) SetOutput(stdout, stderr io.Writer) {
+	a.stdout = stdout
+	a.stderr = stderr
+}
+
+func (a *APIRuntime) output() (io.Writer, io.Writer) {
+	stdout, stderr := a.stdout, a.stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	return stdout, stderr
+}
+
+func (a *APIRuntime) logf(format string, args ...interface{}) {
+	if a.verbose {
+		stdout, _ := a.output()
+		fmt.Fprintf(stdout, format, args...)
+	}
+}
+
+// apiError is the body of an API error response.
+type apiError struct {
+	Message string `json:"message"`
+}
+
+// do sends a request to the API and decodes a JSON response into out, if
+// out is not nil. Status codes of 300 and above are errors, except 304 Not
+// Modified, which start and stop return for a no-op.
+func (a *APIRuntime) do(method, path string, query url.Values, body interface{}, header http.Header, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	resp, err := a.request(method, path, query, reader, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotModified {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("%s %s: invalid response: %w", method, path, err)
+		}
+	}
+	return nil
+}
+
+// request sends a request and returns the response of a successful one.
+func (a *APIRuntime) request(method, path string, query url.Values, body io.Reader, header http.Header) (*http.Response, error) {
+	u := "http://engine/" + apiVersion + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	a.logf("api: %s %s\n", method, path)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotModified {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		var e apiError
+		msg := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &e) == nil && e.Message != "" {
+			msg = e.Message
+		}
+		return nil, fmt.Errorf("%s %s: %s (%d)", method, path, msg, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// Login checks the credentials of the registry of image and uses them for
+// later pulls from it.
+func (a *APIRuntime) Login(image string, username string, password string) error {
+	registry := Registry(image)
+	a.logf("Logging in to %s as %s\n", registry, username)
+	auth := map[string]string{"username": username, "password": password, "serveraddress": registry}
+	if err := a.do(http.MethodPost, "/auth", nil, auth, nil, nil); err != nil {
+		return fmt.Errorf("failed to log in to %s: %w", registry, err)
+	}
+	data, _ := json.Marshal(auth)
+	a.mu.Lock()
+	a.auths[registry] = base64.URLEncoding.EncodeToString(data)
+	a.mu.Unlock()
+	return nil
+}
+
+// PullImage pulls an image, with the credentials of its registry if Login
+// was called for it.
+func (a *APIRuntime) PullImage(image string) error {
+	a.logf("Pulling image: %s\n", image)
+	header := http.Header{}
+	a.mu.Lock()
+	if auth, ok := a.auths[Registry(image)]; ok {
+		header.Set("X-Registry-Auth", auth)
+	}
+	a.mu.Unlock()
+	resp, err := a.request(http.MethodPost, "/images/create", url.Values{"fromImage": {withDefaultTag(image)}}, nil, header)
+	if err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", image, err)
+	}
+	defer resp.Body.Close()
+
+	// The pull reports progress, and failures, as a stream of JSON messages.
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		}
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to pull image %s: %w", image, err)
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("failed to pull image %s: %s", image, msg.Error)
+		}
+	}
+}
+
+// withDefaultTag returns image with the latest tag when it has neither a tag
+// nor a digest, as the CLI pulls it: the API pulls every tag of an untagged
+// image.
+func withDefaultTag(image string) string {
+	if strings.Contains(image, "@") {
+		return image
+	}
+	name := image
+	if i := strings.LastIndex(image, "/"); i >= 0 {
+		name = image[i+1:]
+	}
+	if strings.Contains(name, ":") {
+		return image
+	}
+	return image + ":latest"
+}
+
+// createRequest is the body of a container create request.
+type createRequest struct {
+	Image            string              `json:"Image"`
+	Entrypoint       []string            `json:"Entrypoint,omitempty"`
+	Cmd              []string            `json:"Cmd,omitempty"`
+	Env              []string            `json:"Env,omitempty"`
+	WorkingDir       string              `json:"WorkingDir,omitempty"`
+	User             string              `json:"User,omitempty"`
+	Hostname         string              `json:"Hostname,omitempty"`
+	Labels           map[string]string   `json:"Labels,omitempty"`
+	ExposedPorts     map[string]struct{} `json:"ExposedPorts,omitempty"`
+	Healthcheck      *healthConfig       `json:"Healthcheck,omitempty"`
+	HostConfig       hostConfig          `json:"HostConfig"`
+	NetworkingConfig *networkingConfig   `json:"NetworkingConfig,omitempty"`
+}
+
+type healthConfig struct {
+	Test        []string      `json:"Test,omitempty"`
+	Interval    time.Duration `json:"Interval,omitempty"`
+	Timeout     time.Duration `json:"Timeout,omitempty"`
+	StartPeriod time.Duration `json:"StartPeriod,omitempty"`
+	Retries     int           `json:"Retries,omitempty"`
+}
+
+type hostConfig struct {
+	Binds        []string                 `json:"Binds,omitempty"`
+	PortBindings map[string][]portBinding `json:"PortBindings,omitempty"`
+	NetworkMode  string                   `json:"NetworkMode,omitempty"`
+	Privileged   bool                     `json:"Privileged,omitempty"`
+	NanoCPUs     int64                    `json:"NanoCpus,omitempty"`
+	Memory       int64                    `json:"Memory,omitempty"`
+}
+
+type portBinding struct {
+	HostIP   string `json:"HostIp,omitempty"`
+	HostPort string `json:"HostPort"`
+}
+
+type networkingConfig struct {
+	EndpointsConfig map[string]endpointConfig `json:"EndpointsConfig"`
+}
+
+type endpointConfig struct {
+	Aliases []string `json:"Aliases,omitempty"`
+}
+
+// CreateContainerWithConfig pulls image, then creates and starts a
+// container from it.
+func (a *APIRuntime) CreateContainerWithConfig(image string, name string, cfg *ContainerConfig) (string, error) {
+	a.logf("Creating container with config: %s (image: %s)\n", name, image)
+	if err := a.PullImage(image); err != nil {
+		return "", err
+	}
+	if cfg == nil {
+		cfg = &ContainerConfig{}
+	}
+	req, err := buildCreateRequest(image, cfg)
+	if err != nil {
+		return "", fmt.Errorf("failed to create container %s: %w", name, err)
+	}
+
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := a.do(http.MethodPost, "/containers/create", url.Values{"name": {name}}, req, nil, &created); err != nil {
+		return "", fmt.Errorf("failed to create container %s from image %s: %w", name, image, err)
+	}
+	if err := a.StartContainer(created.ID); err != nil {
+		_ = a.RemoveContainer(created.ID)
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// buildCreateRequest translates a ContainerConfig into a create request.
+func buildCreateRequest(image string, cfg *ContainerConfig) (*createRequest, error) {
+	req := &createRequest{
+		Image:      image,
+		Env:        cfg.Env,
+		WorkingDir: cfg.WorkDir,
+		User:       cfg.User,
+		HostConfig: hostConfig{Binds: cfg.Volumes, NetworkMode: cfg.Network},
+	}
+	if !cfg.ImageCommand {
+		// keep the container idle so that commands can be executed in it
+		req.Entrypoint = []string{"tail"}
+		req.Cmd = []string{"-f", "/dev/null"}
+	}
+	for _, p := range cfg.Ports {
+		port, binding, err := parsePort(p)
+		if err != nil {
+			return nil, err
+		}
+		if req.ExposedPorts == nil {
+			req.ExposedPorts = make(map[string]struct{})
+			req.HostConfig.PortBindings = make(map[string][]portBinding)
+		}
+		req.ExposedPorts[port] = struct{}{}
+		req.HostConfig.PortBindings[port] = append(req.HostConfig.PortBindings[port], binding)
+	}
+	if cfg.Network != "" && len(cfg.NetworkAliases) > 0 {
+		req.NetworkingConfig = &networkingConfig{EndpointsConfig: map[string]endpointConfig{
+			cfg.Network: {Aliases: cfg.NetworkAliases},
+		}}
+	}
+	if cfg.Options != "" {
+		if err := applyOptions(req, cfg.Options); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// parsePort parses a published port, [[host-ip:]host-port:]container-port[/protocol].
+func parsePort(p string) (string, portBinding, error) {
+	spec, proto, ok := strings.Cut(p, "/")
+	if !ok {
+		proto = "tcp"
+	}
+	parts := strings.Split(spec, ":")
+	var b portBinding
+	switch len(parts) {
+	case 1:
+	case 2:
+		b.HostPort = parts[0]
+	case 3:
+		b.HostIP, b.HostPort = parts[0], parts[1]
+	default:
+		return "", b, fmt.Errorf("invalid port %q", p)
+	}
+	port := parts[len(parts)-1]
+	if _, err := strconv.Atoi(port); err != nil {
+		return "", b, fmt.Errorf("invalid port %q", p)
+	}
+	return port + "/" + proto, b, nil
+}
+
+// applyOptions applies the create flags in options that the API supports:
+// health checks, --env, --label, --hostname, --user, --privileged, --cpus
+// and --memory.
+func applyOptions(req *createRequest, options string) error {
+	args, err := splitCommandLine(options)
+	if err != nil {
+		return fmt.Errorf("invalid container options %q: %w", options, err)
+	}
+	health := func() *healthConfig {
+		if req.Healthcheck == nil {
+			req.Healthcheck = &healthConfig{}
+		}
+		return req.Healthcheck
+	}
+	for i := 0; i < len(args); i++ {
+		flag, value, hasValue := strings.Cut(args[i], "=")
+		if flag == "--privileged" && !hasValue {
+			req.HostConfig.Privileged = true
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return fmt.Errorf("option %s needs a value", flag)
+			}
+			i++
+			value = args[i]
+		}
+		switch flag {
+		case "--health-cmd":
+			health().Test = []string{"CMD-SHELL", value}
+		case "--health-interval", "--health-timeout", "--health-start-period":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", flag, err)
+			}
+			switch flag {
+			case "--health-interval":
+				health().Interval = d
+			case "--health-timeout":
+				health().Timeout = d
+			default:
+				health().StartPeriod = d
+			}
+		case "--health-retries":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", flag, err)
+			}
+			health().Retries = n
+		case "-e", "--env":
+			req.Env = append(req.Env, value)
+		case "-l", "--label":
+			k, v, _ := strings.Cut(value, "=")
+			if req.Labels == nil {
+				req.Labels = make(map[string]string)
+			}
+			req.Labels[k] = v
+		case "-h", "--hostname":
+			req.Hostname = value
+		case "-u", "--user":
+			req.User = value
+		case "--cpus":
+			cpus, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", flag, err)
+			}
+			req.HostConfig.NanoCPUs = int64(cpus * 1e9)
+		case "-m", "--memory":
+			n, err := parseBytes(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", flag, err)
+			}
+			req.HostConfig.Memory = n
+		default:
+			return fmt.Errorf("container option %s is not supported by the API runtime", flag)
+		}
+	}
+	return nil
+}
+
+// parseBytes parses a size such as 512m or 2g.
+func parseBytes(s string) (int64, error) {
+	units := map[byte]int64{'b': 1, 'k': 1 << 10, 'm': 1 << 20, 'g': 1 << 30}
+	s = strings.ToLower(s)
+	mult := int64(1)
+	if n := len(s); n > 0 {
+		if u, ok := units[s[n-1]]; ok {
+			mult, s = u, s[:n-1]
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n * mult, nil
+}
+
+// StartContainer starts a container.
+func (a *APIRuntime) StartContainer(containerID string) error {
+	if err := a.do(http.MethodPost, "/containers/"+containerID+"/start", nil, nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to start container %s: %w", containerID, err)
+	}
+	return nil
+}
+
+// StopContainer stops a container.
+func (a *APIRuntime) StopContainer(containerID string) error {
+	if err := a.do(http.MethodPost, "/containers/"+containerID+"/stop", url.Values{"t": {"5"}}, nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to stop container %s: %w", containerID, err)
+	}
+	return nil
+}
+
+// RemoveContainer removes a container, stopping it if needed.
+func (a *APIRuntime) RemoveContainer(containerID string) error {
+	query := url.Values{"force": {"true"}, "v": {"true"}}
+	if err := a.do(http.MethodDelete, "/containers/"+containerID, query, nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to remove container %s: %w", containerID, err)
+	}
+	return nil
+}
+
+// execConfig is the body of an exec create request.
+type execConfig struct {
+	AttachStdin  bool     `json:"AttachStdin"`
+	AttachStdout bool     `json:"AttachStdout"`
+	AttachStderr bool     `json:"AttachStderr"`
+	Tty          bool     `json:"Tty"`
+	Cmd          []string `json:"Cmd"`
+	Env          []string `json:"Env,omitempty"`
+	WorkingDir   string   `json:"WorkingDir,omitempty"`
+}
+
+// Exec runs args in a container with stdin, if not nil, attached, and
+// copies the command's stdout and stderr to the given writers. A command
+// that exits with a non-zero code returns an *ExitError.
+func (a *APIRuntime) Exec(containerID string, args []string, opts *ExecOptions, stdin io.Reader, stdout, stderr io.Writer) error {
+	cfg := execConfig{AttachStdin: stdin != nil, AttachStdout: true, AttachStderr: true, Cmd: args}
+	if opts != nil {
+		cfg.Env = opts.Env
+		cfg.WorkingDir = opts.WorkDir
+	}
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := a.do(http.MethodPost, "/containers/"+containerID+"/exec", nil, cfg, nil, &created); err != nil {
+		return fmt.Errorf("failed to exec in container %s: %w", containerID, err)
+	}
+	if err := a.attach(created.ID, stdin, stdout, stderr); err != nil {
+		return fmt.Errorf("failed to exec in container %s: %w", containerID, err)
+	}
+
+	var inspect struct {
+		Running  bool `json:"Running"`
+		ExitCode int  `json:"ExitCode"`
+	}
+	if err := a.do(http.MethodGet, "/exec/"+created.ID+"/json", nil, nil, nil, &inspect); err != nil {
+		return fmt.Errorf("failed to inspect exec in container %s: %w", containerID, err)
+	}
+	if inspect.ExitCode != 0 {
+		return &ExitError{Code: inspect.ExitCode}
+	}
+	return nil
+}
+
+// attach starts an exec instance on a hijacked connection, sends stdin to
+// it and demultiplexes its output until it exits.
+func (a *APIRuntime) attach(execID string, stdin io.Reader, stdout, stderr io.Writer) error {
+	conn, err := a.dial(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	body := `{"Detach":false,"Tty":false}`
+	req, err := http.NewRequest(http.MethodPost, "http://engine/"+apiVersion+"/exec/"+execID+"/start", strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols && resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("start exec: %s (%d)", strings.TrimSpace(string(data)), resp.StatusCode)
+	}
+
+	// The connection now carries the raw stream in both directions.
+	go func() {
+		if stdin != nil {
+			_, _ = io.Copy(conn, stdin)
+		}
+		if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+			_ = cw.CloseWrite()
+		}
+	}()
+	return demux(br, stdout, stderr)
+}
+
+// demux copies a multiplexed stream, in which each frame starts with an
+// 8-byte header holding the stream type and the frame length, to stdout and
+// stderr.
+func demux(r io.Reader, stdout, stderr io.Writer) error {
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to read output: %w", err)
+		}
+		out := stdout
+		if header[0] == 2 {
+			out = stderr
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(out, r, size); err != nil {
+			return fmt.Errorf("failed to read output: %w", err)
+		}
+	}
+}
+
+// RunCommandWithOptions executes a command in a container, streaming its
+// stdout and stderr to the writers set with SetOutput.
+func (a *APIRuntime) RunCommandWithOptions(containerID string, command string, opts *ExecOptions) error {
+	a.logf("Running command in %s: %s\n", containerID, command)
+	if opts == nil {
+		opts = &ExecOptions{}
+	}
+	args, err := commandLine(command, opts.Shell, func(script string, data []byte) error {
+		return a.WriteFile(containerID, script, data)
+	})
+	if err != nil {
+		return err
+	}
+	stdout, stderr := a.output()
+	if err := a.Exec(containerID, args, opts, nil, stdout, stderr); err != nil {
+		return fmt.Errorf("failed to exec command in container %s: %w", containerID, err)
+	}
+	return nil
+}
+
+// CommandOutput executes args in a container and returns their stdout.
+func (a *APIRuntime) CommandOutput(containerID string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	if err := a.Exec(containerID, args, nil, nil, &stdout, &stderr); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// WriteFile writes data to the file at path inside the container, creating
+// its parent directories as needed.
+func (a *APIRuntime) WriteFile(containerID string, path string, data []byte) error {
+	var stderr bytes.Buffer
+	args := []string{"sh", "-c", `mkdir -p "$(dirname "$1")" && cat > "$1"`, "sh", path}
+	if err := a.Exec(containerID, args, nil, bytes.NewReader(data), io.Discard, &stderr); err != nil {
+		return fmt.Errorf("failed to write %s in container %s: %v %s", path, containerID, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// ReadFile returns the contents of the file at path inside the container.
+func (a *APIRuntime) ReadFile(containerID string, path string) ([]byte, error) {
+	out, err := a.CommandOutput(containerID, "cat", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s in container %s: %w", path, containerID, err)
+	}
+	return []byte(out), nil
+}
+
+// CopyToContainer copies the contents of the host directory src into the
+// directory dst inside the container, as a tar archive.
+func (a *APIRuntime) CopyToContainer(containerID string, src string, dst string) error {
+	a.logf("Copying %s to %s:%s\n", src, containerID, dst)
+	if _, err := a.CommandOutput(containerID, "mkdir", "-p", dst); err != nil {
+		return fmt.Errorf("failed to copy %s to container %s: %w", src, containerID, err)
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeTar(pw, src))
+	}()
+	header := http.Header{"Content-Type": {"application/x-tar"}}
+	resp, err := a.request(http.MethodPut, "/containers/"+containerID+"/archive", url.Values{"path": {dst}}, pr, header)
+	if err != nil {
+		pr.CloseWithError(err)
+		return fmt.Errorf("failed to copy %s to container %s: %w", src, containerID, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// writeTar writes the contents of the directory src as a tar archive.
+func writeTar(w io.Writer, src string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// CreateNetwork creates a network that containers can join to reach each
+// other by name.
+func (a *APIRuntime) CreateNetwork(name string) error {
+	body := map[string]interface{}{"Name": name, "CheckDuplicate": true}
+	if err := a.do(http.MethodPost, "/networks/create", nil, body, nil, nil); err != nil {
+		return fmt.Errorf("failed to create network %s: %w", name, err)
+	}
+	return nil
+}
+
+// RemoveNetwork removes a network.
+func (a *APIRuntime) RemoveNetwork(name string) error {
+	if err := a.do(http.MethodDelete, "/networks/"+name, nil, nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to remove network %s: %w", name, err)
+	}
+	return nil
+}
+
+// containerState is the part of a container inspect response ici uses.
+type containerState struct {
+	State struct {
+		Health *struct {
+			Status string `json:"Status"`
+		} `json:"Health"`
+	} `json:"State"`
+	NetworkSettings struct {
+		Ports map[string][]portBinding `json:"Ports"`
+	} `json:"NetworkSettings"`
+}
+
+func (a *APIRuntime) inspect(containerID string) (*containerState, error) {
+	var state containerState
+	if err := a.do(http.MethodGet, "/containers/"+containerID+"/json", nil, nil, nil, &state); err != nil {
+		return nil, fmt.Errorf("failed to inspect container %s: %w", containerID, err)
+	}
+	return &state, nil
+}
+
+// WaitHealthy waits until a container with a health check reports healthy.
+// Containers without a health check are considered healthy right away.
+func (a *APIRuntime) WaitHealthy(ctx context.Context, containerID string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		state, err := a.inspect(containerID)
+		if err != nil {
+			return err
+		}
+		status := ""
+		if state.State.Health != nil {
+			status = state.State.Health.Status
+		}
+		switch status {
+		case "", "healthy":
+			return nil
+		case "unhealthy":
+			return fmt.Errorf("container %s is unhealthy", containerID)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("container %s did not become healthy within %s", containerID, timeout)
+		}
+		// Podman may not run health checks on its own, see Manager.WaitHealthy;
+		// Docker does not serve this endpoint.
+		_ = a.do(http.MethodGet, "/libpod/containers/"+containerID+"/healthcheck", nil, nil, nil, nil)
+		if err := sleepContext(ctx, healthPollInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// PublishedPorts returns the host ports that the container's ports are
+// published on, by container port.
+func (a *APIRuntime) PublishedPorts(containerID string) (map[string]string, error) {
+	state, err := a.inspect(containerID)
+	if err != nil {
+		return nil, err
+	}
+	ports := make(map[string]string)
+	for port, bindings := range state.NetworkSettings.Ports {
+		if len(bindings) == 0 {
+			continue
+		}
+		port, _, _ = strings.Cut(port, "/")
+		ports[port] = bindings[0].HostPort
+	}
+	return ports, nil
+}
+
+// APIRuntime implements Runtime with the engine's API.
+var _ Runtime = (*APIRuntime)(nil)
@@ -0,0 +1,264 @@
+package container
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeEngine is a stand-in for the engine API. Execs run fakeExec, which
+// sees the command and stdin and returns the output frames and exit code.
+type fakeEngine struct {
+	mu       sync.Mutex
+	requests []string
+	pulled   []string
+	created  createRequest
+	execs    map[string]execConfig
+	exits    map[string]int
+	stdin    map[string]string
+	fakeExec func(cmd []string, stdin string) (stdout, stderr string, code int)
+}
+
+func newFakeEngine(t *testing.T) (*fakeEngine, *APIRuntime) {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "engine.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets are not available: %v", err)
+	}
+	f := &fakeEngine{execs: make(map[string]execConfig), exits: make(map[string]int), stdin: make(map[string]string)}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(f.serve))
+	srv.Listener = l
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	a := NewAPIRuntime(socket, false)
+	a.SetOutput(io.Discard, io.Discard)
+	return f, a
+}
+
+func (f *fakeEngine) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/"+apiVersion)
+	f.mu.Lock()
+	f.requests = append(f.requests, r.Method+" "+path)
+	f.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case path == "/images/create":
+		f.mu.Lock()
+		f.pulled = append(f.pulled, r.URL.Query().Get("fromImage"))
+		f.mu.Unlock()
+		if r.URL.Query().Get("fromImage") == "missing:latest" {
+			io.WriteString(w, `{"status":"Pulling"}{"error":"manifest unknown"}`)
+			return
+		}
+		io.WriteString(w, `{"status":"Downloaded"}`)
+	case path == "/containers/create":
+		json.NewDecoder(r.Body).Decode(&f.created)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"Id":"c1"}`)
+	case parts[0] == "containers" && len(parts) == 3 && parts[2] == "exec":
+		var cfg execConfig
+		json.NewDecoder(r.Body).Decode(&cfg)
+		f.mu.Lock()
+		id := "e" + string(rune('0'+len(f.execs)))
+		f.execs[id] = cfg
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"Id":"`+id+`"}`)
+	case parts[0] == "exec" && len(parts) == 3 && parts[2] == "start":
+		io.Copy(io.Discard, r.Body)
+		f.startExec(w, parts[1])
+	case parts[0] == "exec" && len(parts) == 3 && parts[2] == "json":
+		f.mu.Lock()
+		code := f.exits[parts[1]]
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"Running": false, "ExitCode": code})
+	case parts[0] == "containers" && len(parts) == 3 && parts[2] == "json":
+		io.WriteString(w, `{"State":{"Health":{"Status":"healthy"}},"NetworkSettings":{"Ports":{"5432/tcp":[{"HostIp":"0.0.0.0","HostPort":"40000"}],"6379/tcp":null}}}`)
+	case path == "/containers/nope/start":
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"message":"no such container"}`)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// startExec hijacks the connection like the engine does and writes the
+// command's output as multiplexed frames.
+func (f *fakeEngine) startExec(w http.ResponseWriter, id string) {
+	f.mu.Lock()
+	cfg := f.execs[id]
+	f.mu.Unlock()
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	buf.WriteString("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	buf.Flush()
+
+	var stdin []byte
+	if cfg.AttachStdin {
+		stdin, _ = io.ReadAll(bufio.NewReader(io.MultiReader(buf.Reader, conn)))
+	}
+	stdout, stderr, code := f.fakeExec(cfg.Cmd, string(stdin))
+	f.mu.Lock()
+	f.exits[id] = code
+	f.stdin[id] = string(stdin)
+	f.mu.Unlock()
+	writeFrame(conn, 1, stdout)
+	writeFrame(conn, 2, stderr)
+}
+
+func writeFrame(w io.Writer, stream byte, data string) {
+	if data == "" {
+		return
+	}
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+	w.Write(append(header, data...))
+}
+
+func TestAPIRuntime_ExecReturnsExitCodeAndSeparatesStreams(t *testing.T) {
+	f, a := newFakeEngine(t)
+	f.fakeExec = func(cmd []string, stdin string) (string, string, int) {
+		return "out\n", "err\n", 3
+	}
+
+	var stdout, stderr bytes.Buffer
+	err := a.Exec("c1", []string{"false"}, &ExecOptions{Env: []string{"A=1"}, WorkDir: "/w"}, nil, &stdout, &stderr)
+	var exit *ExitError
+	if !errors.As(err, &exit) || exit.Code != 3 {
+		t.Fatalf("expected exit code 3, got %v", err)
+	}
+	if stdout.String() != "out\n" || stderr.String() != "err\n" {
+		t.Fatalf("unexpected output: stdout %q, stderr %q", stdout.String(), stderr.String())
+	}
+	cfg := f.execs["e0"]
+	if cfg.WorkingDir != "/w" || cfg.Env[0] != "A=1" || cfg.AttachStdin {
+		t.Fatalf("unexpected exec config: %+v", cfg)
+	}
+}
+
+func TestAPIRuntime_RunCommandWritesScript(t *testing.T) {
+	f, a := newFakeEngine(t)
+	f.fakeExec = func(cmd []string, stdin string) (string, string, int) {
+		return "", "", 0
+	}
+	if err := a.RunCommandWithOptions("c1", "echo hi", &ExecOptions{Shell: "bash"}); err != nil {
+		t.Fatalf("RunCommandWithOptions failed: %v", err)
+	}
+
+	// The script is written with stdin attached, then run.
+	write, run := f.execs["e0"], f.execs["e1"]
+	if !write.AttachStdin || f.stdin["e0"] != "echo hi" {
+		t.Fatalf("expected the script to be written on stdin, got %+v with %q", write, f.stdin["e0"])
+	}
+	script := write.Cmd[len(write.Cmd)-1]
+	if got := strings.Join(run.Cmd, " "); !strings.HasPrefix(got, "bash ") || !strings.Contains(got, script) {
+		t.Fatalf("expected bash to run %s, got %q", script, got)
+	}
+}
+
+func TestAPIRuntime_CreateContainer(t *testing.T) {
+	f, a := newFakeEngine(t)
+	cfg := &ContainerConfig{
+		Env:            []string{"POSTGRES_PASSWORD=postgres"},
+		Ports:          []string{"5432", "127.0.0.1:8080:80/udp"},
+		Network:        "ici-test",
+		NetworkAliases: []string{"postgres"},
+		ImageCommand:   true,
+		Options:        "--health-cmd pg_isready --health-interval=10s --cpus 1.5",
+	}
+	id, err := a.CreateContainerWithConfig("postgres:16", "test-postgres", cfg)
+	if err != nil || id != "c1" {
+		t.Fatalf("CreateContainerWithConfig = %q, %v", id, err)
+	}
+	want := "POST /images/create|POST /containers/create|POST /containers/c1/start"
+	if got := strings.Join(f.requests, "|"); got != want {
+		t.Fatalf("unexpected requests:\n got %s\nwant %s", got, want)
+	}
+
+	c := f.created
+	if c.Image != "postgres:16" || c.Entrypoint != nil || c.HostConfig.NetworkMode != "ici-test" {
+		t.Fatalf("unexpected create request: %+v", c)
+	}
+	if b := c.HostConfig.PortBindings["80/udp"]; len(b) != 1 || b[0].HostIP != "127.0.0.1" || b[0].HostPort != "8080" {
+		t.Fatalf("unexpected port bindings: %+v", c.HostConfig.PortBindings)
+	}
+	if _, ok := c.ExposedPorts["5432/tcp"]; !ok {
+		t.Fatalf("expected 5432/tcp to be exposed: %+v", c.ExposedPorts)
+	}
+	if c.Healthcheck == nil || c.Healthcheck.Test[1] != "pg_isready" || c.Healthcheck.Interval.String() != "10s" {
+		t.Fatalf("unexpected health check: %+v", c.Healthcheck)
+	}
+	if c.HostConfig.NanoCPUs != 1.5e9 {
+		t.Fatalf("unexpected cpus: %d", c.HostConfig.NanoCPUs)
+	}
+	if aliases := c.NetworkingConfig.EndpointsConfig["ici-test"].Aliases; len(aliases) != 1 || aliases[0] != "postgres" {
+		t.Fatalf("unexpected network aliases: %v", aliases)
+	}
+
+	// Images without a tag or digest are pulled as latest, not every tag.
+	for _, image := range []string{"redis", "localhost:5000/team/node", "alpine@sha256:abc"} {
+		if _, err := a.CreateContainerWithConfig(image, "test-"+image, nil); err != nil {
+			t.Fatalf("CreateContainerWithConfig(%s) failed: %v", image, err)
+		}
+	}
+	want = "postgres:16|redis:latest|localhost:5000/team/node:latest|alpine@sha256:abc"
+	if got := strings.Join(f.pulled, "|"); got != want {
+		t.Fatalf("unexpected pulls:\n got %s\nwant %s", got, want)
+	}
+	if f.created.Image != "alpine@sha256:abc" {
+		t.Fatalf("expected the image to be created as given, got %s", f.created.Image)
+	}
+}
+
+func TestAPIRuntime_Errors(t *testing.T) {
+	_, a := newFakeEngine(t)
+	if err := a.PullImage("missing:latest"); err == nil || !strings.Contains(err.Error(), "manifest unknown") {
+		t.Fatalf("expected the pull error from the stream, got %v", err)
+	}
+	if err := a.StartContainer("nope"); err == nil || !strings.Contains(err.Error(), "no such container (404)") {
+		t.Fatalf("expected the API error message, got %v", err)
+	}
+	if _, err := buildCreateRequest("alpine", &ContainerConfig{Options: "--tmpfs /tmp"}); err == nil {
+		t.Fatalf("expected unsupported options to be rejected")
+	}
+}
+
+func TestAPIRuntime_HealthAndPorts(t *testing.T) {
+	_, a := newFakeEngine(t)
+	if err := a.WaitHealthy(context.Background(), "c1", 0); err != nil {
+		t.Fatalf("WaitHealthy failed: %v", err)
+	}
+	ports, err := a.PublishedPorts("c1")
+	if err != nil || len(ports) != 1 || ports["5432"] != "40000" {
+		t.Fatalf("PublishedPorts = %v, %v", ports, err)
+	}
+}
+
+func TestDefaultSocket_FromEnvironment(t *testing.T) {
+	t.Setenv("CONTAINER_HOST", "unix:///run/user/1000/podman/podman.sock")
+	if got, err := DefaultSocket(); err != nil || got != "/run/user/1000/podman/podman.sock" {
+		t.Fatalf("DefaultSocket = %q, %v", got, err)
+	}
+	t.Setenv("CONTAINER_HOST", "tcp://localhost:2375")
+	if _, err := DefaultSocket(); err == nil {
+		t.Fatalf("expected non-unix hosts to be rejected")
+	}
+}
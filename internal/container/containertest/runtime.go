@@ -0,0 +1,461 @@
+// Package containertest provides an in-memory container.Runtime for tests
+// of code that runs containers.
+package containertest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aykay76/ici/internal/container"
+)
+
+// Runtime is an in-memory container.Runtime. It records the containers and
+// networks it is asked to create, keeps the files written to containers and
+// hands the commands run in them to Exec. Commands fail in containers that
+// are not running.
+type Runtime struct {
+	// Exec handles a command run in a container, writing its output to
+	// stdout and stderr and returning an error when it fails. When nil,
+	// commands succeed without output.
+	Exec func(c *Container, command string, opts *container.ExecOptions, stdout, stderr io.Writer) error
+	// Create, when set, is called before each container is created; an
+	// error fails the create.
+	Create func(name string, image string, cfg *container.ContainerConfig) error
+	// Unhealthy lists images whose containers fail their health check.
+	Unhealthy map[string]bool
+	// Starting lists images whose containers never finish starting, so
+	// that their health check times out.
+	Starting map[string]bool
+
+	mu         sync.Mutex
+	stdout     io.Writer
+	stderr     io.Writer
+	containers map[string]*Container
+	order      []string
+	networks   map[string]bool
+	log        []string
+	nextPort   int
+}
+
+// Container is a container created by a Runtime.
+type Container struct {
+	ID     string
+	Name   string
+	Image  string
+	Config container.ContainerConfig
+	// Running reports whether the container is started and not stopped.
+	Running bool
+	// Removed reports whether the container was removed.
+	Removed bool
+	// Commands lists the commands run in the container, in order.
+	Commands []string
+	// Ports maps the container's published ports to host ports.
+	Ports map[string]string
+
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+// New returns an empty Runtime.
+func New() *Runtime {
+	return &Runtime{
+		containers: make(map[string]*Container),
+		networks:   make(map[string]bool),
+		nextPort:   32768,
+	}
+}
+
+// NewRuntime returns a view of r, so that r.NewRuntime can be used wherever
+// a runtime factory is expected. Views share r's containers and networks but
+// each has its own output, as the runtimes of concurrent jobs do.
+func (r *Runtime) NewRuntime(verbose bool) container.Runtime {
+	return &view{Runtime: r}
+}
+
+// view is a Runtime with output of its own.
+type view struct {
+	*Runtime
+
+	mu     sync.Mutex
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// SetOutput sets where the output of commands run through the view goes.
+func (v *view) SetOutput(stdout, stderr io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.stdout, v.stderr = stdout, stderr
+}
+
+// RunCommandWithOptions hands the command to Exec with the view's output.
+func (v *view) RunCommandWithOptions(containerID string, command string, opts *container.ExecOptions) error {
+	v.mu.Lock()
+	stdout, stderr := v.stdout, v.stderr
+	v.mu.Unlock()
+	return v.run(containerID, command, opts, stdout, stderr)
+}
+
+// Log returns the operations performed so far, e.g. "create build ubuntu:22.04".
+func (r *Runtime) Log() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.log...)
+}
+
+// Container returns the container with the given name or ID, or nil.
+func (r *Runtime) Container(name string) *Container {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.containers[name]; ok {
+		return c
+	}
+	for _, c := range r.containers {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Containers returns the containers created so far, in creation order.
+func (r *Runtime) Containers() []*Container {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]*Container, 0, len(r.order))
+	for _, id := range r.order {
+		out = append(out, r.containers[id])
+	}
+	return out
+}
+
+// Networks returns the networks that exist, sorted by name.
+func (r *Runtime) Networks() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []string
+	for n := range r.networks {
+		out = append(out, n)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (r *Runtime) record(format string, args ...interface{}) {
+	r.log = append(r.log, fmt.Sprintf(format, args...))
+}
+
+func (r *Runtime) lookup(containerID string) (*Container, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.containers[containerID]
+	if !ok || c.Removed {
+		return nil, fmt.Errorf("no such container: %s", containerID)
+	}
+	return c, nil
+}
+
+// SetOutput sets where the output of commands goes.
+func (r *Runtime) SetOutput(stdout, stderr io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stdout, r.stderr = stdout, stderr
+}
+
+// Login records a registry login.
+func (r *Runtime) Login(image string, username string, password string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.record("login %s %s", container.Registry(image), username)
+	return nil
+}
+
+// PullImage records an image pull.
+func (r *Runtime) PullImage(image string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.record("pull %s", image)
+	return nil
+}
+
+// CreateContainerWithConfig creates a running container.
+func (r *Runtime) CreateContainerWithConfig(image string, name string, cfg *container.ContainerConfig) (string, error) {
+	if cfg == nil {
+		cfg = &container.ContainerConfig{}
+	}
+	if r.Create != nil {
+		if err := r.Create(name, image, cfg); err != nil {
+			return "", err
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.containers {
+		if c.Name == name && !c.Removed {
+			return "", fmt.Errorf("container name %s is already in use", name)
+		}
+	}
+	if cfg.Network != "" && !r.networks[cfg.Network] {
+		return "", fmt.Errorf("no such network: %s", cfg.Network)
+	}
+
+	id := fmt.Sprintf("%s-%d", name, len(r.order)+1)
+	c := &Container{
+		ID:      id,
+		Name:    name,
+		Image:   image,
+		Config:  *cfg,
+		Running: true,
+		Ports:   make(map[string]string),
+		files:   make(map[string][]byte),
+	}
+	for _, p := range cfg.Ports {
+		p, _, _ = strings.Cut(p, "/")
+		host, port, ok := strings.Cut(p, ":")
+		if !ok {
+			port = host
+			host = fmt.Sprint(r.nextPort)
+			r.nextPort++
+		}
+		c.Ports[port] = host
+	}
+	r.containers[id] = c
+	r.order = append(r.order, id)
+	r.record("create %s %s", name, image)
+	return id, nil
+}
+
+// StartContainer starts a container.
+func (r *Runtime) StartContainer(containerID string) error {
+	c, err := r.lookup(containerID)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c.Running = true
+	r.record("start %s", c.Name)
+	return nil
+}
+
+// StopContainer stops a container.
+func (r *Runtime) StopContainer(containerID string) error {
+	c, err := r.lookup(containerID)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c.Running = false
+	r.record("stop %s", c.Name)
+	return nil
+}
+
+// RemoveContainer removes a container, which remains available to Container
+// for inspection.
+func (r *Runtime) RemoveContainer(containerID string) error {
+	c, err := r.lookup(containerID)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c.Running = false
+	c.Removed = true
+	r.record("rm %s", c.Name)
+	return nil
+}
+
+// RunCommandWithOptions hands the command to Exec.
+func (r *Runtime) RunCommandWithOptions(containerID string, command string, opts *container.ExecOptions) error {
+	r.mu.Lock()
+	stdout, stderr := r.stdout, r.stderr
+	r.mu.Unlock()
+	return r.run(containerID, command, opts, stdout, stderr)
+}
+
+// run hands the command to Exec, discarding output without a writer.
+func (r *Runtime) run(containerID string, command string, opts *container.ExecOptions, stdout, stderr io.Writer) error {
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	return r.exec(containerID, command, opts, stdout, stderr)
+}
+
+// CommandOutput hands the command to Exec and returns its stdout.
+func (r *Runtime) CommandOutput(containerID string, args ...string) (string, error) {
+	var out bytes.Buffer
+	err := r.exec(containerID, strings.Join(args, " "), nil, &out, io.Discard)
+	return out.String(), err
+}
+
+func (r *Runtime) exec(containerID string, command string, opts *container.ExecOptions, stdout, stderr io.Writer) error {
+	c, err := r.lookup(containerID)
+	if err != nil {
+		return err
+	}
+	if opts == nil {
+		opts = &container.ExecOptions{}
+	}
+	r.mu.Lock()
+	if !c.Running {
+		r.mu.Unlock()
+		return fmt.Errorf("container %s is not running", c.Name)
+	}
+	c.Commands = append(c.Commands, command)
+	r.record("exec %s %s", c.Name, command)
+	handler := r.Exec
+	r.mu.Unlock()
+	if handler == nil {
+		return nil
+	}
+	return handler(c, command, opts, stdout, stderr)
+}
+
+// WriteFile stores a file in the container.
+func (r *Runtime) WriteFile(containerID string, path string, data []byte) error {
+	c, err := r.lookup(containerID)
+	if err != nil {
+		return err
+	}
+	c.WriteFile(path, data)
+	return nil
+}
+
+// ReadFile returns a file stored in the container.
+func (r *Runtime) ReadFile(containerID string, path string) ([]byte, error) {
+	c, err := r.lookup(containerID)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := c.ReadFile(path)
+	if !ok {
+		return nil, fmt.Errorf("%s: no such file in container %s", path, containerID)
+	}
+	return data, nil
+}
+
+// CopyToContainer records a copy into the container.
+func (r *Runtime) CopyToContainer(containerID string, src string, dst string) error {
+	c, err := r.lookup(containerID)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.record("cp %s %s:%s", src, c.Name, dst)
+	return nil
+}
+
+// CreateNetwork creates a network.
+func (r *Runtime) CreateNetwork(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.networks[name] {
+		return fmt.Errorf("network %s already exists", name)
+	}
+	r.networks[name] = true
+	r.record("network create %s", name)
+	return nil
+}
+
+// RemoveNetwork removes a network that no running container uses.
+func (r *Runtime) RemoveNetwork(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.networks[name] {
+		return fmt.Errorf("no such network: %s", name)
+	}
+	for _, c := range r.containers {
+		if c.Config.Network == name && !c.Removed {
+			return fmt.Errorf("network %s is in use by %s", name, c.Name)
+		}
+	}
+	delete(r.networks, name)
+	r.record("network rm %s", name)
+	return nil
+}
+
+// WaitHealthy fails for containers of the images listed in Unhealthy, and
+// waits for the timeout or ctx for those listed in Starting.
+func (r *Runtime) WaitHealthy(ctx context.Context, containerID string, timeout time.Duration) error {
+	c, err := r.lookup(containerID)
+	if err != nil {
+		return err
+	}
+	if r.Unhealthy[c.Image] {
+		return fmt.Errorf("container %s is unhealthy", containerID)
+	}
+	if r.Starting[c.Image] {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			return fmt.Errorf("container %s did not become healthy within %s", containerID, timeout)
+		}
+	}
+	return nil
+}
+
+// PublishedPorts returns the host ports assigned to the container's
+// published ports.
+func (r *Runtime) PublishedPorts(containerID string) (map[string]string, error) {
+	c, err := r.lookup(containerID)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(c.Ports))
+	for k, v := range c.Ports {
+		out[k] = v
+	}
+	return out, nil
+}
+
+// WriteFile stores a file in the container.
+func (c *Container) WriteFile(path string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[path] = append([]byte(nil), data...)
+}
+
+// AppendFile appends to a file in the container, as `>>` does.
+func (c *Container) AppendFile(path string, data string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[path] = append(c.files[path], data...)
+}
+
+// ReadFile returns a file stored in the container.
+func (c *Container) ReadFile(path string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.files[path]
+	return data, ok
+}
+
+// Getenv returns the value of a variable for a command run with opts: the
+// command's env, else the container's.
+func (c *Container) Getenv(opts *container.ExecOptions, key string) string {
+	var env []string
+	if opts != nil {
+		env = append(env, opts.Env...)
+	}
+	env = append(env, c.Config.Env...)
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok && k == key {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// healthPollInterval is how often WaitHealthy checks a container's health.
+var healthPollInterval = time.Second
+
+// CreateNetwork creates a network that containers can join to reach each
+// other by name.
+func (m *Manager) CreateNetwork(name string) error {
+	if m.cli == "" {
+		return errors.New("no container CLI found: please install podman or docker")
+	}
+	if err := m.runCmdCapture(m.cli, "network", "create", name); err != nil {
+		return fmt.Errorf("failed to create network %s: %w", name, err)
+	}
+	return nil
+}
+
+// RemoveNetwork removes a network once its containers are removed.
+func (m *Manager) RemoveNetwork(name string) error {
+	if m.cli == "" {
+		return errors.New("no container CLI found: please install podman or docker")
+	}
+	if err := m.runCmdCapture(m.cli, "network", "rm", name); err != nil {
+		return fmt.Errorf("failed to remove network %s: %w", name, err)
+	}
+	return nil
+}
+
+// HealthStatus returns the health of a container: starting, healthy or
+// unhealthy, or an empty string when it has no health check.
+func (m *Manager) HealthStatus(containerID string) (string, error) {
+	if m.cli == "" {
+		return "", errors.New("no container CLI found: please install podman or docker")
+	}
+	out, err := m.runCmdOutput(m.cli, "inspect", "--format", "{{if .State.Health}}{{.State.Health.Status}}{{end}}", containerID)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container %s: %w", containerID, err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// WaitHealthy waits until a container with a health check reports healthy.
+// Containers without a health check are considered healthy right away.
+func (m *Manager) WaitHealthy(ctx context.Context, containerID string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		status, err := m.HealthStatus(containerID)
+		if err != nil {
+			return err
+		}
+		switch status {
+		case "", "healthy":
+			return nil
+		case "unhealthy":
+			return fmt.Errorf("container %s is unhealthy", containerID)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("container %s did not become healthy within %s", containerID, timeout)
+		}
+		// Podman runs health checks from systemd timers, which are not
+		// available everywhere, so the check is run explicitly.
+		if filepath.Base(m.cli) == "podman" {
+			_ = m.runCmdCapture(m.cli, "healthcheck", "run", containerID)
+		}
+		if err := sleepContext(ctx, healthPollInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// sleepContext pauses for d, returning ctx.Err() if ctx is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// PublishedPorts returns the host ports that the container's ports are
+// published on, by container port, e.g. "5432" -> "32768".
+func (m *Manager) PublishedPorts(containerID string) (map[string]string, error) {
+	if m.cli == "" {
+		return nil, errors.New("no container CLI found: please install podman or docker")
+	}
+	out, err := m.runCmdOutput(m.cli, "port", containerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ports of container %s: %w", containerID, err)
+	}
+	ports := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		// 5432/tcp -> 0.0.0.0:32768
+		port, host, ok := strings.Cut(strings.TrimSpace(line), " -> ")
+		if !ok {
+			continue
+		}
+		port, _, _ = strings.Cut(port, "/")
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[i+1:]
+		}
+		if _, seen := ports[port]; !seen {
+			ports[port] = host
+		}
+	}
+	return ports, nil
+}
@@ -0,0 +1,113 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateContainerWithConfig_ServiceOnNetwork(t *testing.T) {
+	old := execCommand
+	defer func() { execCommand = old }()
+	var create string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		full := strings.Join(append([]string{name}, args...), " ")
+		if strings.Contains(full, " create ") {
+			create = full
+		}
+		return fakeExecConfig(name, args...)
+	}
+
+	m := NewManager(false)
+	m.cli = "podman"
+	cfg := &ContainerConfig{
+		Env:            []string{"POSTGRES_PASSWORD=postgres"},
+		Ports:          []string{"5432"},
+		Network:        "ici-test",
+		NetworkAliases: []string{"postgres"},
+		ImageCommand:   true,
+		Options:        "--health-cmd pg_isready",
+	}
+	if _, err := m.CreateContainerWithConfig("postgres:16", "test-postgres", cfg); err != nil {
+		t.Fatalf("CreateContainerWithConfig failed: %v", err)
+	}
+	want := "podman create --name test-postgres --env POSTGRES_PASSWORD=postgres -p 5432 --network ici-test --network-alias postgres --health-cmd pg_isready postgres:16"
+	if create != want {
+		t.Fatalf("unexpected create command:\n got %q\nwant %q", create, want)
+	}
+}
+
+func TestWaitHealthy(t *testing.T) {
+	old, oldInterval := execCommand, healthPollInterval
+	defer func() { execCommand, healthPollInterval = old, oldInterval }()
+	healthPollInterval = time.Millisecond
+
+	statuses := []string{"starting", "starting", "healthy"}
+	var healthchecks int
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		switch args[0] {
+		case "inspect":
+			status := statuses[0]
+			if len(statuses) > 1 {
+				statuses = statuses[1:]
+			}
+			return exec.Command("echo", status)
+		case "healthcheck":
+			healthchecks++
+		}
+		return exec.Command("true")
+	}
+
+	m := NewManager(false)
+	m.cli = "podman"
+	if err := m.WaitHealthy(context.Background(), "svc", time.Minute); err != nil {
+		t.Fatalf("WaitHealthy failed: %v", err)
+	}
+	if healthchecks != 2 {
+		t.Fatalf("expected podman health checks to be run while starting, got %d", healthchecks)
+	}
+
+	statuses = []string{"unhealthy"}
+	if err := m.WaitHealthy(context.Background(), "svc", time.Minute); err == nil || !strings.Contains(err.Error(), "unhealthy") {
+		t.Fatalf("expected an unhealthy error, got %v", err)
+	}
+	statuses = []string{"starting"}
+	if err := m.WaitHealthy(context.Background(), "svc", 0); err == nil || !strings.Contains(err.Error(), "did not become healthy") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	statuses = []string{""}
+	if err := m.WaitHealthy(context.Background(), "svc", 0); err != nil {
+		t.Fatalf("expected a container without health check to be ready: %v", err)
+	}
+
+	// cancelling stops the wait long before the timeout
+	statuses = []string{"starting"}
+	healthPollInterval = time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	if err := m.WaitHealthy(ctx, "svc", time.Hour); !errors.Is(err, context.Canceled) || time.Since(start) > 10*time.Second {
+		t.Fatalf("expected the wait to be cancelled, got %v after %s", err, time.Since(start))
+	}
+}
+
+func TestPublishedPorts(t *testing.T) {
+	old := execCommand
+	defer func() { execCommand = old }()
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		return exec.Command("printf", "5432/tcp -> 0.0.0.0:32768\n5432/tcp -> [::]:32768\n6379/tcp -> 127.0.0.1:32769\n")
+	}
+
+	m := NewManager(false)
+	m.cli = "podman"
+	ports, err := m.PublishedPorts("svc")
+	if err != nil {
+		t.Fatalf("PublishedPorts failed: %v", err)
+	}
+	if len(ports) != 2 || ports["5432"] != "32768" || ports["6379"] != "32769" {
+		t.Fatalf("unexpected ports: %v", ports)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
@@ -16,6 +17,8 @@ var execCommand = exec.Command
 type Manager struct {
 	verbose bool
 	cli     string // detected CLI: podman or docker
+	stdout  io.Writer
+	stderr  io.Writer
 }
 
 // NewManager creates a new container manager
@@ -34,21 +37,27 @@ func NewManager(verbose bool) *Manager {
 	return m
 }
 
-// MapRunsOn converts GitHub Actions runs-on to container images
-func (m *Manager) MapRunsOn(runsOn string) (string, error) {
-	// Map GitHub runners to container images
-	imageMap := map[string]string{
-		"ubuntu-latest": "ubuntu:22.04",
-		"ubuntu-22.04":  "ubuntu:22.04",
-		"ubuntu-20.04":  "ubuntu:20.04",
-		// TODO: Add more mappings
-	}
+// SetOutput sets where RunCommand streams the stdout and stderr of commands
+// executed in containers, and where verbose output goes. By default they go
+// to os.Stdout and os.Stderr.
+func (m *Manager) SetOutput(stdout, stderr io.Writer) {
+	m.stdout = stdout
+	m.stderr = stderr
+}
 
-	if image, ok := imageMap[runsOn]; ok {
-		return image, nil
+// logf writes verbose progress output to the configured stdout, so that it
+// is prefixed and masked like the output of the commands it describes.
+func (m *Manager) logf(format string, args ...interface{}) {
+	var out io.Writer = os.Stdout
+	if m.stdout != nil {
+		out = m.stdout
 	}
+	fmt.Fprintf(out, format, args...)
+}
 
-	return "", fmt.Errorf("unsupported runs-on: %s", runsOn)
+// MapRunsOn converts GitHub Actions runs-on to container images
+func (m *Manager) MapRunsOn(runsOn string) (string, error) {
+	return MapRunsOn(runsOn)
 }
 
 // PullImage pulls the given image using the detected container CLI (podman or docker).
@@ -56,7 +65,7 @@ func (m *Manager) MapRunsOn(runsOn string) (string, error) {
 // before creating containers.
 func (m *Manager) PullImage(image string) error {
 	if m.verbose {
-		fmt.Printf("Pulling image: %s\n", image)
+		m.logf("Pulling image: %s\n", image)
 	}
 	if m.cli == "" {
 		return errors.New("no container CLI found: please install podman or docker")
@@ -73,7 +82,7 @@ func (m *Manager) PullImage(image string) error {
 // It pulls the image, creates the container (keeps it running) and starts it.
 func (m *Manager) CreateContainer(image string, name string) (string, error) {
 	if m.verbose {
-		fmt.Printf("Creating container: %s (image: %s)\n", name, image)
+		m.logf("Creating container: %s (image: %s)\n", name, image)
 	}
 	if m.cli == "" {
 		return "", errors.New("no container CLI found: please install podman or docker")
@@ -103,7 +112,7 @@ func (m *Manager) CreateContainer(image string, name string) (string, error) {
 	}
 
 	if m.verbose {
-		fmt.Printf("Container %s started (via %s)\n", containerID, m.cli)
+		m.logf("Container %s started (via %s)\n", containerID, m.cli)
 	}
 
 	return containerID, nil
@@ -121,6 +130,17 @@ type ContainerConfig struct {
 	WorkDir string
 	// User sets the user inside the container (--user)
 	User string
+	// Ports publishes container ports in the form [host:]container[/proto] (-p)
+	Ports []string
+	// Options holds additional create flags, e.g. "--cpus 1 --hostname build"
+	Options string
+	// Network connects the container to a network (--network)
+	Network string
+	// NetworkAliases are the DNS names of the container on Network (--network-alias)
+	NetworkAliases []string
+	// ImageCommand runs the image's own entrypoint and command, as service
+	// containers do, instead of keeping the container idle for exec.
+	ImageCommand bool
 }
 
 // CreateContainerWithConfig creates and starts a container using the provided
@@ -128,7 +148,7 @@ type ContainerConfig struct {
 // behavior intact; callers that don't need a config may continue using it.
 func (m *Manager) CreateContainerWithConfig(image string, name string, cfg *ContainerConfig) (string, error) {
 	if m.verbose {
-		fmt.Printf("Creating container with config: %s (image: %s)\n", name, image)
+		m.logf("Creating container with config: %s (image: %s)\n", name, image)
 	}
 	if m.cli == "" {
 		return "", errors.New("no container CLI found: please install podman or docker")
@@ -156,10 +176,31 @@ func (m *Manager) CreateContainerWithConfig(image string, name string, cfg *Cont
 		if cfg.User != "" {
 			args = append(args, "--user", cfg.User)
 		}
+		for _, p := range cfg.Ports {
+			args = append(args, "-p", p)
+		}
+		if cfg.Network != "" {
+			args = append(args, "--network", cfg.Network)
+			for _, alias := range cfg.NetworkAliases {
+				args = append(args, "--network-alias", alias)
+			}
+		}
+		if cfg.Options != "" {
+			opts, err := splitCommandLine(cfg.Options)
+			if err != nil {
+				return "", fmt.Errorf("invalid container options %q: %w", cfg.Options, err)
+			}
+			args = append(args, opts...)
+		}
 	}
 
-	// Keep the container running by default
-	args = append(args, image, "tail", "-f", "/dev/null")
+	if cfg != nil && cfg.ImageCommand {
+		args = append(args, image)
+	} else {
+		// Keep the container running by default, replacing the image's
+		// entrypoint so that images built to run a program also stay up
+		args = append(args, "--entrypoint", "tail", image, "-f", "/dev/null")
+	}
 
 	out, err := m.runCmdOutput(m.cli, args...)
 	if err != nil {
@@ -177,16 +218,82 @@ func (m *Manager) CreateContainerWithConfig(image string, name string, cfg *Cont
 	}
 
 	if m.verbose {
-		fmt.Printf("Container %s started (via %s) with config\n", containerID, m.cli)
+		m.logf("Container %s started (via %s) with config\n", containerID, m.cli)
 	}
 
 	return containerID, nil
 }
 
+// Login authenticates to the registry of image, so that private images can
+// be pulled. The password is passed on stdin.
+func (m *Manager) Login(image string, username string, password string) error {
+	if m.cli == "" {
+		return errors.New("no container CLI found: please install podman or docker")
+	}
+	registry := Registry(image)
+	if m.verbose {
+		m.logf("Logging in to %s as %s\n", registry, username)
+	}
+	cmd := execCommand(m.cli, "login", "--username", username, "--password-stdin", registry)
+	cmd.Stdin = strings.NewReader(password)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(out.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("failed to log in to %s: %s", registry, msg)
+	}
+	return nil
+}
+
+// Registry returns the registry an image is pulled from, docker.io for
+// images without a registry host.
+func Registry(image string) string {
+	host, _, ok := strings.Cut(image, "/")
+	if !ok || (!strings.ContainsAny(host, ".:") && host != "localhost") {
+		return "docker.io"
+	}
+	return host
+}
+
+// redactArgs returns args with the values of environment variables passed
+// with --env or -e replaced by ***, for logs and error messages, which would
+// otherwise show secrets passed through env.
+func redactArgs(args []string) []string {
+	out := make([]string, len(args))
+	redactNext := false
+	for i, a := range args {
+		switch {
+		case redactNext:
+			a = redactEnv(a)
+			redactNext = false
+		case a == "--env" || a == "-e":
+			redactNext = true
+		case strings.HasPrefix(a, "--env="):
+			a = "--env=" + redactEnv(strings.TrimPrefix(a, "--env="))
+		case strings.HasPrefix(a, "-e="):
+			a = "-e=" + redactEnv(strings.TrimPrefix(a, "-e="))
+		}
+		out[i] = a
+	}
+	return out
+}
+
+// redactEnv replaces the value of a KEY=VALUE variable by ***.
+func redactEnv(kv string) string {
+	if key, _, ok := strings.Cut(kv, "="); ok {
+		return key + "=***"
+	}
+	return kv
+}
+
 // runCmdCapture runs a command and returns error with stderr/stdout combined on failure.
 func (m *Manager) runCmdCapture(name string, args ...string) error {
 	if m.verbose {
-		fmt.Printf("exec: %s %s\n", name, strings.Join(args, " "))
+		m.logf("exec: %s %s\n", name, strings.Join(redactArgs(args), " "))
 	}
 	cmd := execCommand(name, args...)
 	var out bytes.Buffer
@@ -198,7 +305,7 @@ func (m *Manager) runCmdCapture(name string, args ...string) error {
 		if msg == "" {
 			msg = err.Error()
 		}
-		return fmt.Errorf("command failed: %s %v: %s", name, args, msg)
+		return fmt.Errorf("command failed: %s %v: %s", name, redactArgs(args), msg)
 	}
 	return nil
 }
@@ -206,7 +313,7 @@ func (m *Manager) runCmdCapture(name string, args ...string) error {
 // runCmdOutput runs a command and returns its stdout (trimmed). On error, stderr/stdout are included in the error.
 func (m *Manager) runCmdOutput(name string, args ...string) (string, error) {
 	if m.verbose {
-		fmt.Printf("exec: %s %s\n", name, strings.Join(args, " "))
+		m.logf("exec: %s %s\n", name, strings.Join(redactArgs(args), " "))
 	}
 	cmd := execCommand(name, args...)
 	var out bytes.Buffer
@@ -218,43 +325,149 @@ func (m *Manager) runCmdOutput(name string, args ...string) (string, error) {
 		if msg == "" {
 			msg = err.Error()
 		}
-		return "", fmt.Errorf("command failed: %s %v: %s", name, args, msg)
+		return "", fmt.Errorf("command failed: %s %v: %s", name, redactArgs(args), msg)
 	}
 	return out.String(), nil
 }
 
 // RunCommand executes a command in a container
 func (m *Manager) RunCommand(containerID string, command string) error {
+	return m.RunCommandWithOptions(containerID, command, nil)
+}
+
+// ExecOptions configures how RunCommandWithOptions runs a command.
+type ExecOptions struct {
+	// Env holds additional KEY=VALUE variables for the command (--env).
+	Env []string
+	// WorkDir sets the directory the command runs in (-w).
+	WorkDir string
+	// Shell is the command line that runs the command, with {0} standing
+	// for the path of a script file holding it, e.g. "bash -e {0}". When
+	// empty the command runs with sh -lc.
+	Shell string
+}
+
+// RunCommandWithOptions executes a command in a container using the
+// provided options. Its stdout and stderr are streamed to the writers set
+// with SetOutput.
+func (m *Manager) RunCommandWithOptions(containerID string, command string, opts *ExecOptions) error {
 	if m.verbose {
-		fmt.Printf("Running command in %s: %s\n", containerID, command)
+		m.logf("Running command in %s: %s\n", containerID, command)
 	}
 	if m.cli == "" {
 		return errors.New("no container CLI found: please install podman or docker")
 	}
+	if opts == nil {
+		opts = &ExecOptions{}
+	}
 
-	// Use `exec` to run the command inside the container. Use sh -lc to support complex commands.
-	// Stream stdout/stderr to the current process so callers see realtime output.
-	args := []string{"exec", "-i", containerID, "sh", "-lc", command}
-	if m.verbose {
-		fmt.Printf("exec: %s %s\n", m.cli, strings.Join(args, " "))
+	args := []string{"exec", "-i"}
+	for _, e := range opts.Env {
+		args = append(args, "--env", e)
+	}
+	if opts.WorkDir != "" {
+		args = append(args, "-w", opts.WorkDir)
 	}
+	args = append(args, containerID)
 
+	argv, err := commandLine(command, opts.Shell, func(script string, data []byte) error {
+		return m.WriteFile(containerID, script, data)
+	})
+	if err != nil {
+		return err
+	}
+	args = append(args, argv...)
+
+	// Stream stdout/stderr to the configured writers so callers see realtime output.
+	if m.verbose {
+		m.logf("exec: %s %s\n", m.cli, strings.Join(redactArgs(args), " "))
+	}
 	cmd := execCommand(m.cli, args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	// No stdin wiring for now; could be added if needed
+	if m.stdout != nil {
+		cmd.Stdout = m.stdout
+	}
+	if m.stderr != nil {
+		cmd.Stderr = m.stderr
+	}
 
 	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			err = &ExitError{Code: exitErr.ExitCode()}
+		}
 		return fmt.Errorf("failed to exec command in container %s: %w", containerID, err)
 	}
 
 	return nil
 }
 
+// WriteFile writes data to the file at path inside the container, creating
+// its parent directories as needed.
+func (m *Manager) WriteFile(containerID string, path string, data []byte) error {
+	if m.cli == "" {
+		return errors.New("no container CLI found: please install podman or docker")
+	}
+	args := []string{"exec", "-i", containerID, "sh", "-c", `mkdir -p "$(dirname "$1")" && cat > "$1"`, "sh", path}
+	if m.verbose {
+		m.logf("exec: %s %s\n", m.cli, strings.Join(redactArgs(args), " "))
+	}
+	cmd := execCommand(m.cli, args...)
+	cmd.Stdin = bytes.NewReader(data)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(out.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("failed to write %s in container %s: %s", path, containerID, msg)
+	}
+	return nil
+}
+
+// CommandOutput executes args in a container and returns their stdout.
+func (m *Manager) CommandOutput(containerID string, args ...string) (string, error) {
+	if m.cli == "" {
+		return "", errors.New("no container CLI found: please install podman or docker")
+	}
+	return m.runCmdOutput(m.cli, append([]string{"exec", containerID}, args...)...)
+}
+
+// ReadFile returns the contents of the file at path inside the container.
+func (m *Manager) ReadFile(containerID string, path string) ([]byte, error) {
+	out, err := m.CommandOutput(containerID, "cat", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s in container %s: %w", path, containerID, err)
+	}
+	return []byte(out), nil
+}
+
+// CopyToContainer copies the contents of the host directory src into the
+// directory dst inside the container.
+func (m *Manager) CopyToContainer(containerID string, src string, dst string) error {
+	if m.verbose {
+		m.logf("Copying %s to %s:%s\n", src, containerID, dst)
+	}
+	if m.cli == "" {
+		return errors.New("no container CLI found: please install podman or docker")
+	}
+
+	// A trailing "/." copies the directory contents rather than the directory itself
+	source := strings.TrimSuffix(src, "/") + "/."
+	if err := m.runCmdCapture(m.cli, "cp", source, containerID+":"+dst); err != nil {
+		return fmt.Errorf("failed to copy %s to container %s: %w", src, containerID, err)
+	}
+
+	return nil
+}
+
 // RemoveContainer removes a Podman container
 func (m *Manager) RemoveContainer(containerID string) error {
 	if m.verbose {
-		fmt.Printf("Removing container: %s\n", containerID)
+		m.logf("Removing container: %s\n", containerID)
 	}
 	if m.cli == "" {
 		return errors.New("no container CLI found: please install podman or docker")
@@ -269,7 +482,7 @@ func (m *Manager) RemoveContainer(containerID string) error {
 	}
 
 	if m.verbose {
-		fmt.Printf("Container %s removed\n", containerID)
+		m.logf("Container %s removed\n", containerID)
 	}
 
 	return nil
@@ -278,7 +491,7 @@ func (m *Manager) RemoveContainer(containerID string) error {
 // StartContainer starts an existing container by ID or name.
 func (m *Manager) StartContainer(containerID string) error {
 	if m.verbose {
-		fmt.Printf("Starting container: %s\n", containerID)
+		m.logf("Starting container: %s\n", containerID)
 	}
 	if m.cli == "" {
 		return errors.New("no container CLI found: please install podman or docker")
@@ -294,7 +507,7 @@ func (m *Manager) StartContainer(containerID string) error {
 // StopContainer stops a running container by ID or name.
 func (m *Manager) StopContainer(containerID string) error {
 	if m.verbose {
-		fmt.Printf("Stopping container: %s\n", containerID)
+		m.logf("Stopping container: %s\n", containerID)
 	}
 	if m.cli == "" {
 		return errors.New("no container CLI found: please install podman or docker")
@@ -42,3 +42,58 @@ func TestCreateContainerWithConfig_ReturnsID(t *testing.T) {
 		t.Fatalf("expected fake-id-123, got %q", id)
 	}
 }
+
+func TestCreateContainerWithConfig_Flags(t *testing.T) {
+	old := execCommand
+	defer func() { execCommand = old }()
+	var create string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		full := strings.Join(append([]string{name}, args...), " ")
+		if strings.Contains(full, " create ") {
+			create = full
+		}
+		return fakeExecConfig(name, args...)
+	}
+
+	m := NewManager(false)
+	m.cli = "podman"
+	cfg := &ContainerConfig{
+		Volumes: []string{"/src:/github/workspace"},
+		Ports:   []string{"8080:80"},
+		Options: `--cpus 1 --label "team=build tools"`,
+	}
+	if _, err := m.CreateContainerWithConfig("golang:1.25", "build", cfg); err != nil {
+		t.Fatalf("CreateContainerWithConfig failed: %v", err)
+	}
+	want := "podman create --name build -v /src:/github/workspace -p 8080:80 --cpus 1 --label team=build tools --entrypoint tail golang:1.25 -f /dev/null"
+	if create != want {
+		t.Fatalf("unexpected create command:\n got %q\nwant %q", create, want)
+	}
+}
+
+func TestCreateContainerWithConfig_ErrorRedactsEnv(t *testing.T) {
+	old := execCommand
+	defer func() { execCommand = old }()
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		if args[0] == "create" {
+			return exec.Command("sh", "-c", "echo 'no such image' >&2; exit 125")
+		}
+		return exec.Command("true")
+	}
+
+	m := NewManager(false)
+	m.cli = "podman"
+	cfg := &ContainerConfig{Env: []string{"TOKEN=s3cret"}, Options: "-e PASSWORD=hunter2 --env=KEY=v4lue"}
+	_, err := m.CreateContainerWithConfig("alpine", "test", cfg)
+	if err == nil || !strings.Contains(err.Error(), "no such image") {
+		t.Fatalf("expected the create to fail, got %v", err)
+	}
+	for _, secret := range []string{"s3cret", "hunter2", "v4lue"} {
+		if strings.Contains(err.Error(), secret) {
+			t.Errorf("error shows the value of an env variable: %v", err)
+		}
+	}
+	if !strings.Contains(err.Error(), "TOKEN=***") {
+		t.Errorf("expected the variable name with its value redacted, got %v", err)
+	}
+}
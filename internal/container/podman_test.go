@@ -142,3 +142,155 @@ func TestPullImage_Failure(t *testing.T) {
 		t.Fatalf("expected PullImage to fail for nonexistent image")
 	}
 }
+
+func TestRunCommand_WritesToConfiguredOutput(t *testing.T) {
+	old := execCommand
+	defer func() { execCommand = old }()
+	execCommand = fakeExec
+
+	m := NewManager(false)
+	m.cli = "podman"
+
+	var stdout, stderr bytes.Buffer
+	m.SetOutput(&stdout, &stderr)
+
+	if err := m.RunCommand("fake-id", "echo hello"); err != nil {
+		t.Fatalf("RunCommand failed: %v", err)
+	}
+	if got := strings.TrimSpace(stdout.String()); got != "hello" {
+		t.Fatalf("expected output 'hello', got %q", got)
+	}
+}
+
+func TestCopyToContainer_CopiesDirectoryContents(t *testing.T) {
+	old := execCommand
+	defer func() { execCommand = old }()
+	var got string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		got = strings.Join(append([]string{name}, args...), " ")
+		return exec.Command("sh", "-c", "exit 0")
+	}
+
+	m := NewManager(false)
+	m.cli = "podman"
+
+	if err := m.CopyToContainer("fake-id", "/src/repo/", "/github/workspace"); err != nil {
+		t.Fatalf("CopyToContainer failed: %v", err)
+	}
+	if got != "podman cp /src/repo/. fake-id:/github/workspace" {
+		t.Fatalf("unexpected command: %q", got)
+	}
+}
+
+func TestRunCommandWithOptions_RunsScriptWithShell(t *testing.T) {
+	old := execCommand
+	defer func() { execCommand = old }()
+	var calls []string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		calls = append(calls, strings.Join(append([]string{name}, args...), " "))
+		return exec.Command("sh", "-c", "cat > /dev/null")
+	}
+
+	m := NewManager(false)
+	m.cli = "podman"
+
+	opts := &ExecOptions{
+		Env:     []string{"FOO=bar"},
+		WorkDir: "/github/workspace/src",
+		Shell:   `pwsh -command ". '{0}'"`,
+	}
+	if err := m.RunCommandWithOptions("fake-id", "Write-Output hi", opts); err != nil {
+		t.Fatalf("RunCommandWithOptions failed: %v", err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 commands, got %d: %v", len(calls), calls)
+	}
+	if !strings.HasPrefix(calls[0], "podman exec -i fake-id sh -c ") || !strings.HasSuffix(calls[0], ".ps1") {
+		t.Fatalf("unexpected write command: %q", calls[0])
+	}
+	script := calls[0][strings.LastIndex(calls[0], " ")+1:]
+	want := "podman exec -i --env FOO=bar -w /github/workspace/src fake-id pwsh -command . '" + script + "'"
+	if calls[1] != want {
+		t.Fatalf("unexpected exec command:\n got %q\nwant %q", calls[1], want)
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	got, err := splitCommandLine(`bash --noprofile -eo pipefail {0}`)
+	if err != nil {
+		t.Fatalf("splitCommandLine failed: %v", err)
+	}
+	if strings.Join(got, "|") != "bash|--noprofile|-eo|pipefail|{0}" {
+		t.Fatalf("unexpected args: %q", got)
+	}
+	got, _ = splitCommandLine(`pwsh -command ". '{0}'"`)
+	if len(got) != 3 || got[2] != ". '{0}'" {
+		t.Fatalf("unexpected args: %q", got)
+	}
+	if _, err := splitCommandLine(`sh -c 'echo`); err == nil {
+		t.Fatalf("expected an error for an unterminated quote")
+	}
+}
+
+func TestReadFile_ReturnsContents(t *testing.T) {
+	old := execCommand
+	defer func() { execCommand = old }()
+	var got string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		got = strings.Join(append([]string{name}, args...), " ")
+		return exec.Command("sh", "-c", "printf 'version=1.2.3\\n'")
+	}
+
+	m := NewManager(false)
+	m.cli = "podman"
+
+	data, err := m.ReadFile("fake-id", "/tmp/out")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if got != "podman exec fake-id cat /tmp/out" {
+		t.Fatalf("unexpected command: %q", got)
+	}
+	if string(data) != "version=1.2.3\n" {
+		t.Fatalf("unexpected contents: %q", data)
+	}
+}
+
+func TestLogin_PassesPasswordOnStdin(t *testing.T) {
+	old := execCommand
+	defer func() { execCommand = old }()
+	var got string
+	out := t.TempDir() + "/stdin"
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		got = strings.Join(append([]string{name}, args...), " ")
+		return exec.Command("sh", "-c", "cat > "+out)
+	}
+
+	m := NewManager(false)
+	m.cli = "podman"
+	if err := m.Login("ghcr.io/octo/build:1", "octo", "t0ken"); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if got != "podman login --username octo --password-stdin ghcr.io" {
+		t.Fatalf("unexpected command: %q", got)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "t0ken" {
+		t.Fatalf("expected the password on stdin, got %q", data)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	tests := map[string]string{
+		"ubuntu:22.04":             "docker.io",
+		"library/golang:1.25":      "docker.io",
+		"ghcr.io/octo/build:1":     "ghcr.io",
+		"localhost:5000/app":       "localhost:5000",
+		"localhost/app":            "localhost",
+		"registry.example.com/app": "registry.example.com",
+	}
+	for image, want := range tests {
+		if got := Registry(image); got != want {
+			t.Errorf("Registry(%q) = %q, want %q", image, got, want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Runtime creates and runs the containers of a workflow run. Manager,
+// which drives the podman or docker CLI, is the default implementation.
+type Runtime interface {
+	// SetOutput sets where the output of commands executed in containers
+	// goes, along with verbose progress output.
+	SetOutput(stdout, stderr io.Writer)
+
+	// Login authenticates to the registry of image.
+	Login(image string, username string, password string) error
+	// PullImage makes image available locally.
+	PullImage(image string) error
+
+	// CreateContainerWithConfig pulls image, creates a container from it
+	// and starts it, returning its ID.
+	CreateContainerWithConfig(image string, name string, cfg *ContainerConfig) (string, error)
+	StartContainer(containerID string) error
+	StopContainer(containerID string) error
+	RemoveContainer(containerID string) error
+
+	// RunCommandWithOptions executes a command in a container, streaming
+	// its output to the writers set with SetOutput.
+	RunCommandWithOptions(containerID string, command string, opts *ExecOptions) error
+	// CommandOutput executes args in a container and returns their stdout.
+	CommandOutput(containerID string, args ...string) (string, error)
+
+	WriteFile(containerID string, path string, data []byte) error
+	ReadFile(containerID string, path string) ([]byte, error)
+	// CopyToContainer copies the contents of the host directory src into
+	// the directory dst inside the container.
+	CopyToContainer(containerID string, src string, dst string) error
+
+	CreateNetwork(name string) error
+	RemoveNetwork(name string) error
+
+	// WaitHealthy waits until a container's health check passes. It
+	// returns ctx.Err() when ctx is done first.
+	WaitHealthy(ctx context.Context, containerID string, timeout time.Duration) error
+	// PublishedPorts returns the host ports of the container's published
+	// ports, by container port.
+	PublishedPorts(containerID string) (map[string]string, error)
+}
+
+// ExitError reports a command run in a container that exited with a
+// non-zero code.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// Manager implements Runtime with the podman or docker CLI.
+var _ Runtime = (*Manager)(nil)
+
+// NewRuntime returns the default runtime, which uses the podman or docker
+// CLI, whichever is installed.
+func NewRuntime(verbose bool) Runtime {
+	return NewManager(verbose)
+}
+
+// runnerImages maps GitHub runner labels to container images.
+var runnerImages = map[string]string{
+	"ubuntu-latest": "ubuntu:22.04",
+	"ubuntu-22.04":  "ubuntu:22.04",
+	"ubuntu-20.04":  "ubuntu:20.04",
+	// TODO: Add more mappings
+}
+
+// MapRunsOn converts a GitHub Actions runs-on label to a container image.
+func MapRunsOn(runsOn string) (string, error) {
+	if image, ok := runnerImages[runsOn]; ok {
+		return image, nil
+	}
+	return "", fmt.Errorf("unsupported runs-on: %s", runsOn)
+}
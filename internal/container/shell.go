@@ -0,0 +1,98 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync/atomic"
+)
+
+// scriptDir is where RunCommandWithOptions writes the scripts it runs.
+const scriptDir = "/tmp/ici"
+
+// scriptExtension returns the file extension a script run by program needs.
+// Some interpreters, notably PowerShell, refuse scripts without one.
+func scriptExtension(program string) string {
+	switch strings.TrimSuffix(path.Base(program), ".exe") {
+	case "pwsh", "powershell":
+		return ".ps1"
+	case "python", "python3":
+		return ".py"
+	default:
+		return ".sh"
+	}
+}
+
+// scriptCounter numbers the script files written for commands run with a
+// shell.
+var scriptCounter atomic.Int64
+
+// commandLine returns the arguments that run command in a container. With
+// an empty shell the command runs with sh -lc. Otherwise the command is
+// written to a script file with write and shell, a command line in which
+// {0} stands for the script path, runs it.
+func commandLine(command string, shell string, write func(script string, data []byte) error) ([]string, error) {
+	if shell == "" {
+		// Use sh -lc to support complex commands.
+		return []string{"sh", "-lc", command}, nil
+	}
+	argv, err := splitCommandLine(shell)
+	if err != nil {
+		return nil, err
+	}
+	if len(argv) == 0 {
+		return nil, errors.New("empty shell command")
+	}
+	script := fmt.Sprintf("%s/step-%d%s", scriptDir, scriptCounter.Add(1), scriptExtension(argv[0]))
+	if err := write(script, []byte(command)); err != nil {
+		return nil, err
+	}
+	if !strings.Contains(shell, "{0}") {
+		argv = append(argv, "{0}")
+	}
+	args := make([]string, 0, len(argv))
+	for _, a := range argv {
+		args = append(args, strings.ReplaceAll(a, "{0}", script))
+	}
+	return args, nil
+}
+
+// splitCommandLine splits a shell command line into its arguments. Single
+// and double quotes group words the way a POSIX shell does; no expansion is
+// performed.
+func splitCommandLine(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inWord := false
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				args = append(args, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inWord {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,483 @@
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Func is a function callable from expressions. Arguments are already
+// evaluated.
+type Func func(args []interface{}) (interface{}, error)
+
+// Context holds the data and functions available to expressions.
+type Context struct {
+	// Values maps context names (github, env, matrix, ...) to their data.
+	// Values may be nil, bool, numbers, strings, maps and slices.
+	Values map[string]interface{}
+	// Funcs holds functions in addition to the built-in ones, such as the
+	// job status functions success() and failure().
+	Funcs map[string]Func
+	// WorkDir is the directory hashFiles resolves its patterns against.
+	WorkDir string
+}
+
+// filtered is the result of an object filter (`.*` or `[*]`). Further
+// dereferences apply to each of its elements.
+type filtered []interface{}
+
+// Evaluate parses and evaluates a single expression (without the surrounding
+// ${{ }}) and returns its value.
+func Evaluate(expression string, ctx *Context) (interface{}, error) {
+	n, err := parse(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", expression, err)
+	}
+	if ctx == nil {
+		ctx = &Context{}
+	}
+	v, err := ctx.eval(n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate %q: %w", expression, err)
+	}
+	return unfilter(v), nil
+}
+
+// CallsFunction reports whether an expression (without the surrounding
+// ${{ }}) calls any of the named functions. Names match case-insensitively,
+// as in calls, and text inside string literals is not a call.
+func CallsFunction(expression string, names ...string) (bool, error) {
+	n, err := parse(expression)
+	if err != nil {
+		return false, fmt.Errorf("invalid expression %q: %w", expression, err)
+	}
+	return callsFunction(n, names), nil
+}
+
+func callsFunction(n node, names []string) bool {
+	switch n := n.(type) {
+	case *propertyNode:
+		return callsFunction(n.target, names)
+	case *indexNode:
+		return callsFunction(n.target, names) || (n.index != nil && callsFunction(n.index, names))
+	case *callNode:
+		for _, name := range names {
+			if strings.EqualFold(n.name, name) {
+				return true
+			}
+		}
+		for _, arg := range n.args {
+			if callsFunction(arg, names) {
+				return true
+			}
+		}
+	case *unaryNode:
+		return callsFunction(n.operand, names)
+	case *binaryNode:
+		return callsFunction(n.left, names) || callsFunction(n.right, names)
+	}
+	return false
+}
+
+func (c *Context) eval(n node) (interface{}, error) {
+	switch n := n.(type) {
+	case *literalNode:
+		return n.value, nil
+
+	case *contextNode:
+		v, ok := lookup(c.Values, n.name)
+		if !ok {
+			return nil, fmt.Errorf("unrecognized named-value: '%s'", n.name)
+		}
+		return normalize(v), nil
+
+	case *propertyNode:
+		target, err := c.eval(n.target)
+		if err != nil {
+			return nil, err
+		}
+		if n.name == "*" {
+			return filter(target), nil
+		}
+		return property(target, n.name), nil
+
+	case *indexNode:
+		target, err := c.eval(n.target)
+		if err != nil {
+			return nil, err
+		}
+		if n.index == nil {
+			return filter(target), nil
+		}
+		index, err := c.eval(n.index)
+		if err != nil {
+			return nil, err
+		}
+		return indexValue(target, unfilter(index)), nil
+
+	case *callNode:
+		args := make([]interface{}, len(n.args))
+		for i, a := range n.args {
+			v, err := c.eval(a)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = unfilter(v)
+		}
+		return c.call(n.name, args)
+
+	case *unaryNode:
+		v, err := c.eval(n.operand)
+		if err != nil {
+			return nil, err
+		}
+		return !IsTruthy(v), nil
+
+	case *binaryNode:
+		left, err := c.eval(n.left)
+		if err != nil {
+			return nil, err
+		}
+		switch n.op {
+		case "&&":
+			if !IsTruthy(left) {
+				return unfilter(left), nil
+			}
+			right, err := c.eval(n.right)
+			return unfilter(right), err
+		case "||":
+			if IsTruthy(left) {
+				return unfilter(left), nil
+			}
+			right, err := c.eval(n.right)
+			return unfilter(right), err
+		}
+		right, err := c.eval(n.right)
+		if err != nil {
+			return nil, err
+		}
+		left, right = unfilter(left), unfilter(right)
+		switch n.op {
+		case "==":
+			return looseEqual(left, right), nil
+		case "!=":
+			return !looseEqual(left, right), nil
+		default:
+			return compare(n.op, left, right), nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported expression node %T", n)
+}
+
+func (c *Context) call(name string, args []interface{}) (interface{}, error) {
+	for fname, fn := range c.Funcs {
+		if strings.EqualFold(fname, name) {
+			return fn(args)
+		}
+	}
+	for fname, fn := range builtins {
+		if strings.EqualFold(fname, name) {
+			return fn(c, args)
+		}
+	}
+	return nil, fmt.Errorf("unrecognized function: '%s'", name)
+}
+
+// lookup finds a key in a map, falling back to a case-insensitive match as
+// GitHub contexts are case-insensitive.
+func lookup(m map[string]interface{}, key string) (interface{}, bool) {
+	if v, ok := m[key]; ok {
+		return v, true
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func property(target interface{}, name string) interface{} {
+	switch t := target.(type) {
+	case map[string]interface{}:
+		v, _ := lookup(t, name)
+		return normalize(v)
+	case filtered:
+		out := filtered{}
+		for _, item := range t {
+			if m, ok := item.(map[string]interface{}); ok {
+				if v, ok := lookup(m, name); ok {
+					out = append(out, normalize(v))
+				}
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func indexValue(target interface{}, index interface{}) interface{} {
+	switch t := target.(type) {
+	case map[string]interface{}:
+		return property(t, ToString(index))
+	case []interface{}:
+		f := ToNumber(index)
+		if math.IsNaN(f) || f < 0 || f != math.Trunc(f) || int(f) >= len(t) {
+			return nil
+		}
+		return normalize(t[int(f)])
+	case filtered:
+		out := filtered{}
+		for _, item := range t {
+			if v := indexValue(item, index); v != nil {
+				out = append(out, v)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// filter applies an object filter, returning every element of an array or
+// every value of an object.
+func filter(target interface{}) filtered {
+	out := filtered{}
+	switch t := target.(type) {
+	case []interface{}:
+		for _, v := range t {
+			out = append(out, normalize(v))
+		}
+	case map[string]interface{}:
+		for _, v := range t {
+			out = append(out, normalize(v))
+		}
+	case filtered:
+		for _, v := range t {
+			out = append(out, filter(v)...)
+		}
+	}
+	return out
+}
+
+func unfilter(v interface{}) interface{} {
+	if f, ok := v.(filtered); ok {
+		return []interface{}(f)
+	}
+	return v
+}
+
+// normalize converts Go values supplied by callers into the value types used
+// by the evaluator: nil, bool, float64, string, map[string]interface{} and
+// []interface{}.
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case nil, bool, float64, string, map[string]interface{}, []interface{}, filtered:
+		return t
+	case int:
+		return float64(t)
+	case int64:
+		return float64(t)
+	case float32:
+		return float64(t)
+	case map[string]string:
+		m := make(map[string]interface{}, len(t))
+		for k, s := range t {
+			m[k] = s
+		}
+		return m
+	case []string:
+		a := make([]interface{}, len(t))
+		for i, s := range t {
+			a[i] = s
+		}
+		return a
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String {
+			m := make(map[string]interface{}, rv.Len())
+			iter := rv.MapRange()
+			for iter.Next() {
+				m[iter.Key().String()] = iter.Value().Interface()
+			}
+			return m
+		}
+	case reflect.Slice, reflect.Array:
+		a := make([]interface{}, rv.Len())
+		for i := range a {
+			a[i] = rv.Index(i).Interface()
+		}
+		return a
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	}
+	return fmt.Sprint(v)
+}
+
+// IsTruthy reports whether a value is truthy. false, 0, -0, NaN, "" and
+// null are falsy; everything else is truthy.
+func IsTruthy(v interface{}) bool {
+	switch t := normalize(v).(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case float64:
+		return t != 0 && !math.IsNaN(t)
+	case string:
+		return t != ""
+	}
+	return true
+}
+
+// ToNumber converts a value to a number using GitHub's coercion rules.
+func ToNumber(v interface{}) float64 {
+	switch t := normalize(v).(type) {
+	case nil:
+		return 0
+	case bool:
+		if t {
+			return 1
+		}
+		return 0
+	case float64:
+		return t
+	case string:
+		s := strings.TrimSpace(t)
+		if s == "" {
+			return 0
+		}
+		if n, err := parseNumber(s); err == nil {
+			return n
+		}
+		return math.NaN()
+	}
+	return math.NaN()
+}
+
+// ToString converts a value to the string form used when interpolating it.
+// Objects and arrays are rendered as JSON.
+func ToString(v interface{}) string {
+	switch t := normalize(v).(type) {
+	case nil:
+		return ""
+	case bool:
+		if t {
+			return "true"
+		}
+		return "false"
+	case float64:
+		return formatNumber(t)
+	case string:
+		return t
+	default:
+		out, err := json.MarshalIndent(unfilter(t), "", "  ")
+		if err != nil {
+			return ""
+		}
+		return string(out)
+	}
+}
+
+func formatNumber(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// looseEqual implements the == operator. Values of different primitive types
+// are compared as numbers, strings compare case-insensitively and objects or
+// arrays are only equal to themselves.
+func looseEqual(a, b interface{}) bool {
+	a, b = normalize(a), normalize(b)
+	switch at := a.(type) {
+	case nil:
+		if b == nil {
+			return true
+		}
+	case string:
+		if bs, ok := b.(string); ok {
+			return strings.EqualFold(at, bs)
+		}
+	case bool:
+		if bb, ok := b.(bool); ok {
+			return at == bb
+		}
+	case float64:
+		if bf, ok := b.(float64); ok {
+			return at == bf
+		}
+	case map[string]interface{}, []interface{}:
+		return sameReference(a, b)
+	}
+	if isComposite(b) {
+		return false
+	}
+	return ToNumber(a) == ToNumber(b)
+}
+
+func compare(op string, a, b interface{}) bool {
+	a, b = normalize(a), normalize(b)
+	if isComposite(a) || isComposite(b) {
+		return false
+	}
+	if as, ok := a.(string); ok {
+		if bs, ok := b.(string); ok {
+			cmp := strings.Compare(strings.ToLower(as), strings.ToLower(bs))
+			switch op {
+			case "<":
+				return cmp < 0
+			case "<=":
+				return cmp <= 0
+			case ">":
+				return cmp > 0
+			case ">=":
+				return cmp >= 0
+			}
+		}
+	}
+	af, bf := ToNumber(a), ToNumber(b)
+	switch op {
+	case "<":
+		return af < bf
+	case "<=":
+		return af <= bf
+	case ">":
+		return af > bf
+	case ">=":
+		return af >= bf
+	}
+	return false
+}
+
+func isComposite(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
+
+func sameReference(a, b interface{}) bool {
+	if !isComposite(b) {
+		return false
+	}
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
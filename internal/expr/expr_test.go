@@ -0,0 +1,263 @@
+package expr
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testContext() *Context {
+	return &Context{
+		Values: map[string]interface{}{
+			"github": map[string]interface{}{
+				"event_name": "push",
+				"ref":        "refs/heads/main",
+				"event": map[string]interface{}{
+					"commits": []interface{}{
+						map[string]interface{}{"message": "first"},
+						map[string]interface{}{"message": "second"},
+					},
+				},
+			},
+			"matrix": map[string]interface{}{
+				"go": "1.25",
+				"os": []string{"ubuntu-latest", "ubuntu-22.04"},
+			},
+			"env": map[string]string{"NAME": "world"},
+			"steps": map[string]interface{}{
+				"build-step": map[string]interface{}{
+					"outputs": map[string]interface{}{"version": "1.2.3"},
+				},
+			},
+		},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		expr string
+		want interface{}
+	}{
+		{"true", true},
+		{"null", nil},
+		{"42", 42.0},
+		{"-1.5", -1.5},
+		{"0xff", 255.0},
+		{"'it''s'", "it's"},
+		{"github.event_name", "push"},
+		{"GITHUB.Event_Name", "push"},
+		{"github['ref']", "refs/heads/main"},
+		{"github.missing", nil},
+		{"github.missing.deeper", nil},
+		{"steps.build-step.outputs.version", "1.2.3"},
+		{"matrix.os[1]", "ubuntu-22.04"},
+		{"matrix.os[5]", nil},
+		{"github.event.commits.*.message", []interface{}{"first", "second"}},
+		{"github.event.commits[*].message", []interface{}{"first", "second"}},
+		{"github.event_name == 'PUSH'", true},
+		{"github.event_name != 'push'", false},
+		{"1 == '1'", true},
+		{"true == 1", true},
+		{"null == 0", true},
+		{"'abc' == 'abd'", false},
+		{"1 < 2", true},
+		{"'b' > 'A'", true},
+		{"!false", true},
+		{"!''", true},
+		{"github.missing || 'default'", "default"},
+		{"'a' && 'b'", "b"},
+		{"'' && 'b'", ""},
+		{"(1 < 2) && (2 < 3)", true},
+		{"github.event_name == 'push' && startsWith(github.ref, 'refs/heads/')", true},
+	}
+
+	for _, tt := range tests {
+		got, err := Evaluate(tt.expr, testContext())
+		if err != nil {
+			t.Fatalf("Evaluate(%q) failed: %v", tt.expr, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Evaluate(%q) = %#v, want %#v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluate_Errors(t *testing.T) {
+	for _, e := range []string{
+		"unknown.value",
+		"github.",
+		"'unterminated",
+		"(1 == 1",
+		"nosuchfunction()",
+		"1 ==",
+		"format('{0}')",
+	} {
+		if _, err := Evaluate(e, testContext()); err == nil {
+			t.Errorf("expected Evaluate(%q) to fail", e)
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		expr string
+		want interface{}
+	}{
+		{"contains('Hello World', 'world')", true},
+		{"contains(matrix.os, 'ubuntu-latest')", true},
+		{"contains(matrix.os, 'windows-latest')", false},
+		{"startsWith('refs/tags/v1', 'refs/tags/')", true},
+		{"endsWith('file.go', '.GO')", true},
+		{"format('Hello {0} {1}!', 'big', env.NAME)", "Hello big world!"},
+		{"format('{{0}} is {0}', 'literal')", "{0} is literal"},
+		{"join(matrix.os, ', ')", "ubuntu-latest, ubuntu-22.04"},
+		{"join(matrix.os)", "ubuntu-latest,ubuntu-22.04"},
+		{"toJSON(matrix.go)", `"1.25"`},
+		{"fromJSON('{\"a\": [1, 2]}').a[1]", 2.0},
+		{"fromJSON('true')", true},
+	}
+
+	for _, tt := range tests {
+		got, err := Evaluate(tt.expr, testContext())
+		if err != nil {
+			t.Fatalf("Evaluate(%q) failed: %v", tt.expr, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Evaluate(%q) = %#v, want %#v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestHashFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"go.sum":     "a",
+		"sub/go.sum": "b",
+		"README.md":  "c",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := &Context{WorkDir: dir}
+	all, err := Evaluate("hashFiles('**/go.sum')", ctx)
+	if err != nil {
+		t.Fatalf("hashFiles failed: %v", err)
+	}
+	if s, ok := all.(string); !ok || len(s) != 64 {
+		t.Fatalf("expected a sha256 hex digest, got %#v", all)
+	}
+
+	top, err := Evaluate("hashFiles('**/go.sum', '!sub/**')", ctx)
+	if err != nil {
+		t.Fatalf("hashFiles failed: %v", err)
+	}
+	if top == all {
+		t.Fatalf("expected exclusion to change the hash")
+	}
+
+	none, err := Evaluate("hashFiles('*.lock')", ctx)
+	if err != nil {
+		t.Fatalf("hashFiles failed: %v", err)
+	}
+	if none != "" {
+		t.Fatalf("expected empty hash for no matches, got %#v", none)
+	}
+}
+
+func TestCustomFuncs(t *testing.T) {
+	ctx := testContext()
+	ctx.Funcs = map[string]Func{
+		"success": func(args []interface{}) (interface{}, error) { return false, nil },
+	}
+	got, err := Evaluate("success() || github.event_name", ctx)
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	if got != "push" {
+		t.Fatalf("expected push, got %#v", got)
+	}
+}
+
+func TestCallsFunction(t *testing.T) {
+	tests := map[string]bool{
+		"success()":                         true,
+		"Always()":                          true,
+		"!cancelled() && github.ref":        true,
+		"format('{0}', failure())":          true,
+		"github.event.inputs[failure()]":    true,
+		"github.ref != 'failure()'":         false,
+		"contains(github.ref, 'success()')": false,
+		"github.failure":                    false,
+	}
+	for expression, want := range tests {
+		got, err := CallsFunction(expression, "success", "failure", "always", "cancelled")
+		if err != nil || got != want {
+			t.Errorf("CallsFunction(%q) = %v, %v, want %v", expression, got, err, want)
+		}
+	}
+	if _, err := CallsFunction("success(", "success"); err == nil {
+		t.Errorf("expected an invalid expression to fail")
+	}
+}
+
+func TestTemplateExpression(t *testing.T) {
+	tests := map[string]string{
+		"github.ref == 'main'":                "github.ref == 'main'",
+		"${{ github.ref == 'main' }}":         "github.ref == 'main'",
+		"${{ format('{0}}}', 'x') }}":         "format('{0}}}', 'x')",
+		"${{ env.NAME }} == ${{ matrix.go }}": "format('{0} == {1}', env.NAME, matrix.go)",
+		"it's {${{ env.NAME }}}":              "format('it''s {{{0}}}', env.NAME)",
+		"${{ env.NAME }}${{ matrix.go }}":     "format('{0}{1}', env.NAME, matrix.go)",
+		"${{ env.NAME }} ":                    "format('{0} ', env.NAME)",
+	}
+	for s, want := range tests {
+		got, err := TemplateExpression(s)
+		if err != nil || got != want {
+			t.Errorf("TemplateExpression(%q) = %q, %v, want %q", s, got, err, want)
+		}
+	}
+
+	// the format() call interpolates like Interpolate
+	s := "it's {${{ env.NAME }}} on ${{ matrix.go }}"
+	e, err := TemplateExpression(s)
+	if err != nil {
+		t.Fatalf("TemplateExpression failed: %v", err)
+	}
+	got, err := Evaluate(e, testContext())
+	if err != nil {
+		t.Fatalf("Evaluate(%q) failed: %v", e, err)
+	}
+	if want, _ := Interpolate(s, testContext()); got != want {
+		t.Fatalf("Evaluate(%q) = %q, want %q", e, got, want)
+	}
+
+	if _, err := TemplateExpression("${{ env.NAME"); err == nil {
+		t.Fatalf("expected an unclosed expression to fail")
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	got, err := Interpolate("echo ${{ env.NAME }} on ${{ matrix.go }}-${{ format('{0}}}', 'x') }}", testContext())
+	if err != nil {
+		t.Fatalf("Interpolate failed: %v", err)
+	}
+	if got != "echo world on 1.25-x}" {
+		t.Fatalf("unexpected interpolation: %q", got)
+	}
+
+	plain, err := Interpolate("no expressions here", nil)
+	if err != nil || plain != "no expressions here" {
+		t.Fatalf("expected plain string to pass through, got %q, %v", plain, err)
+	}
+
+	if _, err := Interpolate("echo ${{ env.NAME", testContext()); err == nil || !strings.Contains(err.Error(), "unclosed") {
+		t.Fatalf("expected unclosed expression error, got %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+package expr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aykay76/ici/internal/glob"
+)
+
+// builtin is a built-in function. Unlike Func it has access to the context,
+// which hashFiles needs for its working directory.
+type builtin func(c *Context, args []interface{}) (interface{}, error)
+
+var builtins map[string]builtin
+
+func init() {
+	builtins = map[string]builtin{
+		"contains":   fnContains,
+		"startsWith": fnStartsWith,
+		"endsWith":   fnEndsWith,
+		"format":     fnFormat,
+		"join":       fnJoin,
+		"toJSON":     fnToJSON,
+		"fromJSON":   fnFromJSON,
+		"hashFiles":  fnHashFiles,
+	}
+}
+
+func expectArgs(name string, args []interface{}, min, max int) error {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		if min == max {
+			return fmt.Errorf("%s() expects %d argument(s), got %d", name, min, len(args))
+		}
+		return fmt.Errorf("%s() expects between %d and %d arguments, got %d", name, min, max, len(args))
+	}
+	return nil
+}
+
+// fnContains implements contains(search, item). When search is an array it
+// reports whether any element equals item; otherwise it performs a
+// case-insensitive substring match.
+func fnContains(_ *Context, args []interface{}) (interface{}, error) {
+	if err := expectArgs("contains", args, 2, 2); err != nil {
+		return nil, err
+	}
+	if arr, ok := normalize(args[0]).([]interface{}); ok {
+		for _, v := range arr {
+			if looseEqual(v, args[1]) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return strings.Contains(strings.ToLower(ToString(args[0])), strings.ToLower(ToString(args[1]))), nil
+}
+
+func fnStartsWith(_ *Context, args []interface{}) (interface{}, error) {
+	if err := expectArgs("startsWith", args, 2, 2); err != nil {
+		return nil, err
+	}
+	return strings.HasPrefix(strings.ToLower(ToString(args[0])), strings.ToLower(ToString(args[1]))), nil
+}
+
+func fnEndsWith(_ *Context, args []interface{}) (interface{}, error) {
+	if err := expectArgs("endsWith", args, 2, 2); err != nil {
+		return nil, err
+	}
+	return strings.HasSuffix(strings.ToLower(ToString(args[0])), strings.ToLower(ToString(args[1]))), nil
+}
+
+// fnFormat implements format(string, replaceValue0, ...). {N} is replaced
+// by the Nth value and {{ / }} escape literal braces.
+func fnFormat(_ *Context, args []interface{}) (interface{}, error) {
+	if err := expectArgs("format", args, 1, -1); err != nil {
+		return nil, err
+	}
+	format := ToString(args[0])
+	values := args[1:]
+
+	var sb strings.Builder
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		switch {
+		case c == '{' && i+1 < len(format) && format[i+1] == '{':
+			sb.WriteByte('{')
+			i++
+		case c == '}' && i+1 < len(format) && format[i+1] == '}':
+			sb.WriteByte('}')
+			i++
+		case c == '{':
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("format(): unclosed '{' in %q", format)
+			}
+			var idx int
+			if _, err := fmt.Sscanf(format[i+1:i+end], "%d", &idx); err != nil || fmt.Sprint(idx) != format[i+1:i+end] {
+				return nil, fmt.Errorf("format(): invalid placeholder %q", format[i:i+end+1])
+			}
+			if idx < 0 || idx >= len(values) {
+				return nil, fmt.Errorf("format(): placeholder {%d} has no matching argument", idx)
+			}
+			sb.WriteString(ToString(values[idx]))
+			i += end
+		case c == '}':
+			return nil, fmt.Errorf("format(): unexpected '}' in %q", format)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), nil
+}
+
+// fnJoin implements join(array, optionalSeparator). The default separator
+// is a comma.
+func fnJoin(_ *Context, args []interface{}) (interface{}, error) {
+	if err := expectArgs("join", args, 1, 2); err != nil {
+		return nil, err
+	}
+	sep := ","
+	if len(args) == 2 {
+		sep = ToString(args[1])
+	}
+	arr, ok := normalize(args[0]).([]interface{})
+	if !ok {
+		return ToString(args[0]), nil
+	}
+	parts := make([]string, len(arr))
+	for i, v := range arr {
+		parts[i] = ToString(v)
+	}
+	return strings.Join(parts, sep), nil
+}
+
+func fnToJSON(_ *Context, args []interface{}) (interface{}, error) {
+	if err := expectArgs("toJSON", args, 1, 1); err != nil {
+		return nil, err
+	}
+	out, err := json.MarshalIndent(normalizeDeep(args[0]), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("toJSON(): %w", err)
+	}
+	return string(out), nil
+}
+
+func fnFromJSON(_ *Context, args []interface{}) (interface{}, error) {
+	if err := expectArgs("fromJSON", args, 1, 1); err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(ToString(args[0])), &v); err != nil {
+		return nil, fmt.Errorf("fromJSON(): %w", err)
+	}
+	return v, nil
+}
+
+// fnHashFiles implements hashFiles(path, ...). It returns the SHA-256 of the
+// individual SHA-256 hashes of every matching file, or an empty string when
+// no file matches. Patterns starting with ! exclude files.
+func fnHashFiles(c *Context, args []interface{}) (interface{}, error) {
+	if err := expectArgs("hashFiles", args, 1, -1); err != nil {
+		return nil, err
+	}
+	root := c.WorkDir
+	if root == "" {
+		root = "."
+	}
+
+	var include, exclude []string
+	for _, a := range args {
+		pattern := strings.TrimPrefix(filepath.ToSlash(ToString(a)), "./")
+		if strings.HasPrefix(pattern, "!") {
+			exclude = append(exclude, strings.TrimPrefix(pattern[1:], "./"))
+			continue
+		}
+		include = append(include, pattern)
+	}
+
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if glob.MatchAny(include, rel) && !glob.MatchAny(exclude, rel) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("hashFiles(): %w", err)
+	}
+	if len(files) == 0 {
+		return "", nil
+	}
+	sort.Strings(files)
+
+	total := sha256.New()
+	for _, f := range files {
+		h := sha256.New()
+		file, err := os.Open(f)
+		if err != nil {
+			return nil, fmt.Errorf("hashFiles(): %w", err)
+		}
+		_, err = io.Copy(h, file)
+		_ = file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("hashFiles(): %w", err)
+		}
+		total.Write(h.Sum(nil))
+	}
+	return hex.EncodeToString(total.Sum(nil)), nil
+}
+
+// normalizeDeep normalizes a value and all nested values so they can be
+// marshalled to JSON.
+func normalizeDeep(v interface{}) interface{} {
+	switch t := normalize(v).(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, item := range t {
+			m[k] = normalizeDeep(item)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(t))
+		for i, item := range t {
+			a[i] = normalizeDeep(item)
+		}
+		return a
+	case filtered:
+		return normalizeDeep([]interface{}(t))
+	default:
+		return t
+	}
+}
@@ -0,0 +1,116 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ContainsExpression reports whether s contains a ${{ }} expression.
+func ContainsExpression(s string) bool {
+	return strings.Contains(s, "${{")
+}
+
+// Interpolate replaces every ${{ <expression> }} in s with the string form of
+// its value. Strings without expressions are returned unchanged.
+func Interpolate(s string, ctx *Context) (string, error) {
+	if !ContainsExpression(s) {
+		return s, nil
+	}
+
+	var sb strings.Builder
+	rest := s
+	for {
+		start := strings.Index(rest, "${{")
+		if start < 0 {
+			sb.WriteString(rest)
+			break
+		}
+		sb.WriteString(rest[:start])
+
+		body := rest[start+3:]
+		end := closingBraces(body)
+		if end < 0 {
+			return "", fmt.Errorf("unclosed expression in %q", s)
+		}
+		v, err := Evaluate(strings.TrimSpace(body[:end]), ctx)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(ToString(v))
+		rest = body[end+2:]
+	}
+	return sb.String(), nil
+}
+
+// TemplateExpression returns a single expression equivalent to s, for
+// fields such as `if:` that hold an expression with or without ${{ }}. A
+// string that is one ${{ <expression> }} yields the expression, and one that
+// mixes text and expressions a format() call producing the interpolated
+// text, as GitHub evaluates it. Strings without ${{ are returned unchanged.
+func TemplateExpression(s string) (string, error) {
+	if !ContainsExpression(s) {
+		return s, nil
+	}
+
+	var format strings.Builder
+	var args []string
+	rest := s
+	for {
+		start := strings.Index(rest, "${{")
+		if start < 0 {
+			format.WriteString(escapeFormat(rest))
+			break
+		}
+		format.WriteString(escapeFormat(rest[:start]))
+
+		body := rest[start+3:]
+		end := closingBraces(body)
+		if end < 0 {
+			return "", fmt.Errorf("unclosed expression in %q", s)
+		}
+		if start == 0 && len(args) == 0 && end == len(body)-2 {
+			return strings.TrimSpace(body[:end]), nil
+		}
+		fmt.Fprintf(&format, "{%d}", len(args))
+		args = append(args, strings.TrimSpace(body[:end]))
+		rest = body[end+2:]
+	}
+	quoted := "'" + strings.ReplaceAll(format.String(), "'", "''") + "'"
+	return "format(" + quoted + ", " + strings.Join(args, ", ") + ")", nil
+}
+
+// escapeFormat escapes the braces of text for use in a format() string.
+func escapeFormat(text string) string {
+	return strings.NewReplacer("{", "{{", "}", "}}").Replace(text)
+}
+
+// InterpolateMap interpolates every value of m, returning a new map.
+func InterpolateMap(m map[string]string, ctx *Context) (map[string]string, error) {
+	if m == nil {
+		return nil, nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		iv, err := Interpolate(v, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		out[k] = iv
+	}
+	return out, nil
+}
+
+// closingBraces returns the index of the "}}" that closes an expression body,
+// ignoring braces inside string literals, or -1 if there is none.
+func closingBraces(body string) int {
+	inString := false
+	for i := 0; i < len(body); i++ {
+		switch {
+		case body[i] == '\'':
+			inString = !inString
+		case !inString && strings.HasPrefix(body[i:], "}}"):
+			return i
+		}
+	}
+	return -1
+}
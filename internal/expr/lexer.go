@@ -0,0 +1,174 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tokenKind identifies the type of a lexical token.
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenTrue
+	tokenFalse
+	tokenNull
+	tokenOperator
+)
+
+// token is a single lexical element of an expression.
+type token struct {
+	kind  tokenKind
+	text  string
+	value interface{} // parsed literal value for numbers and strings
+	pos   int
+}
+
+// operators lists every operator and punctuation token, longest first so the
+// lexer always prefers the longer match.
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "(", ")", "[", "]", ".", ",", "!", "<", ">", "*"}
+
+// lex splits an expression into tokens.
+func lex(input string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(input) {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '\'':
+			start := i
+			var sb strings.Builder
+			i++
+			for {
+				if i >= len(input) {
+					return nil, fmt.Errorf("unterminated string starting at position %d", start)
+				}
+				if input[i] == '\'' {
+					// '' is an escaped single quote
+					if i+1 < len(input) && input[i+1] == '\'' {
+						sb.WriteByte('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				sb.WriteByte(input[i])
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenString, text: input[start:i], value: sb.String(), pos: start})
+
+		case isDigit(c) || (c == '-' && i+1 < len(input) && (isDigit(input[i+1]) || input[i+1] == '.')) ||
+			(c == '.' && i+1 < len(input) && isDigit(input[i+1]) && !followsOperand(tokens)):
+			start := i
+			i++
+			for i < len(input) && (isIdentChar(input[i]) || input[i] == '.' ||
+				((input[i] == '+' || input[i] == '-') && (input[i-1] == 'e' || input[i-1] == 'E'))) {
+				i++
+			}
+			text := input[start:i]
+			n, err := parseNumber(text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", text, start)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: text, value: n, pos: start})
+
+		case isIdentStart(c):
+			start := i
+			for i < len(input) && isIdentChar(input[i]) {
+				i++
+			}
+			text := input[start:i]
+			kind := tokenIdent
+			switch text {
+			case "true":
+				kind = tokenTrue
+			case "false":
+				kind = tokenFalse
+			case "null":
+				kind = tokenNull
+			}
+			tokens = append(tokens, token{kind: kind, text: text, pos: start})
+
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(input[i:], op) {
+					tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+		}
+	}
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(input)})
+	return tokens, nil
+}
+
+// followsOperand reports whether the previous token ends an operand, in which
+// case a following '.' is a property dereference rather than a number.
+func followsOperand(tokens []token) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	prev := tokens[len(tokens)-1]
+	if prev.kind != tokenOperator {
+		return true
+	}
+	return prev.text == ")" || prev.text == "]" || prev.text == "*"
+}
+
+// parseNumber parses decimal, exponent, hexadecimal (0x) and octal (0o)
+// number literals.
+func parseNumber(text string) (float64, error) {
+	neg := strings.HasPrefix(text, "-")
+	body := strings.TrimPrefix(text, "-")
+	var n float64
+	switch {
+	case strings.HasPrefix(body, "0x") || strings.HasPrefix(body, "0X"):
+		v, err := strconv.ParseInt(body[2:], 16, 64)
+		if err != nil {
+			return 0, err
+		}
+		n = float64(v)
+	case strings.HasPrefix(body, "0o") || strings.HasPrefix(body, "0O"):
+		v, err := strconv.ParseInt(body[2:], 8, 64)
+		if err != nil {
+			return 0, err
+		}
+		n = float64(v)
+	default:
+		v, err := strconv.ParseFloat(body, 64)
+		if err != nil {
+			return 0, err
+		}
+		n = v
+	}
+	if neg {
+		n = -n
+	}
+	return n, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '-'
+}
@@ -0,0 +1,286 @@
+package expr
+
+import (
+	"fmt"
+)
+
+// node is an element of a parsed expression tree.
+type node interface{}
+
+// literalNode is a null, boolean, number or string literal.
+type literalNode struct {
+	value interface{}
+}
+
+// contextNode is a top-level named context such as github or matrix.
+type contextNode struct {
+	name string
+}
+
+// propertyNode dereferences a property of an object. A name of "*" is an
+// object filter that selects every element.
+type propertyNode struct {
+	target node
+	name   string
+}
+
+// indexNode indexes into an array or object. A nil index is an object filter
+// written as [*].
+type indexNode struct {
+	target node
+	index  node
+}
+
+// callNode is a function call.
+type callNode struct {
+	name string
+	args []node
+}
+
+// unaryNode is a logical not.
+type unaryNode struct {
+	operand node
+}
+
+// binaryNode is a comparison or logical operator.
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+// parser is a recursive descent parser over the lexed tokens. Operator
+// precedence from lowest to highest is ||, &&, equality, comparison, !.
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parse parses a complete expression.
+func parse(input string) (node, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected token %q at position %d", tok.text, tok.pos)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is the given operator.
+func (p *parser) accept(op string) bool {
+	if tok := p.peek(); tok.kind == tokenOperator && tok.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		tok := p.peek()
+		if tok.kind == tokenEOF {
+			return fmt.Errorf("expected %q but reached end of expression", op)
+		}
+		return fmt.Errorf("expected %q but found %q at position %d", op, tok.text, tok.pos)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseEquality()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseEquality()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseEquality() (node, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op string
+		switch {
+		case p.accept("=="):
+			op = "=="
+		case p.accept("!="):
+			op = "!="
+		default:
+			return left, nil
+		}
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op string
+		switch {
+		case p.accept("<="):
+			op = "<="
+		case p.accept(">="):
+			op = ">="
+		case p.accept("<"):
+			op = "<"
+		case p.accept(">"):
+			op = ">"
+		default:
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.accept("!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{operand: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept("."):
+			if p.accept("*") {
+				n = &propertyNode{target: n, name: "*"}
+				continue
+			}
+			tok := p.next()
+			switch tok.kind {
+			case tokenIdent, tokenTrue, tokenFalse, tokenNull:
+				n = &propertyNode{target: n, name: tok.text}
+			default:
+				return nil, fmt.Errorf("expected property name after '.' at position %d", tok.pos)
+			}
+		case p.accept("["):
+			if p.accept("*") {
+				if err := p.expect("]"); err != nil {
+					return nil, err
+				}
+				n = &indexNode{target: n}
+				continue
+			}
+			index, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			n = &indexNode{target: n, index: index}
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber, tokenString:
+		return &literalNode{value: tok.value}, nil
+	case tokenTrue:
+		return &literalNode{value: true}, nil
+	case tokenFalse:
+		return &literalNode{value: false}, nil
+	case tokenNull:
+		return &literalNode{value: nil}, nil
+	case tokenIdent:
+		if p.accept("(") {
+			var args []node
+			if !p.accept(")") {
+				for {
+					arg, err := p.parseOr()
+					if err != nil {
+						return nil, err
+					}
+					args = append(args, arg)
+					if p.accept(")") {
+						break
+					}
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+			}
+			return &callNode{name: tok.text, args: args}, nil
+		}
+		return &contextNode{name: tok.text}, nil
+	case tokenOperator:
+		if tok.text == "(" {
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return n, nil
+		}
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	default:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+}
@@ -0,0 +1,284 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// execCommand is a package-level variable so tests can override command execution.
+var execCommand = exec.Command
+
+// run executes git in dir and returns its trimmed stdout. On error, stderr is
+// included in the returned error.
+func run(dir string, args ...string) (string, error) {
+	cmd := execCommand("git", append([]string{"-C", dir}, args...)...)
+	var out bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), msg)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// TopLevel returns the root directory of the git repository containing dir.
+func TopLevel(dir string) (string, error) {
+	return run(dir, "rev-parse", "--show-toplevel")
+}
+
+// ResolveCommit returns the full SHA of the commit ref names in the
+// repository containing dir. ref may be a branch, a tag or a commit SHA,
+// abbreviated or not.
+func ResolveCommit(dir string, ref string) (string, error) {
+	sha, err := run(dir, "rev-parse", "--verify", "--end-of-options", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", ref, err)
+	}
+	return sha, nil
+}
+
+// IsShallow reports whether the repository containing dir has a shallow
+// history.
+func IsShallow(dir string) bool {
+	out, err := run(dir, "rev-parse", "--is-shallow-repository")
+	return err == nil && out == "true"
+}
+
+// UninitializedSubmodules returns the paths of the submodules of the
+// repository containing dir that are not checked out, including nested
+// ones when recursive is set.
+func UninitializedSubmodules(dir string, recursive bool) ([]string, error) {
+	args := []string{"submodule", "status"}
+	if recursive {
+		args = append(args, "--recursive")
+	}
+	out, err := run(dir, args...)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, line := range strings.Split(out, "\n") {
+		// uninitialized submodules are listed as -<sha> <path>
+		if fields := strings.Fields(line); len(fields) >= 2 && strings.HasPrefix(fields[0], "-") {
+			paths = append(paths, fields[1])
+		}
+	}
+	return paths, nil
+}
+
+// CloneOptions controls how CloneLocal materializes a repository.
+type CloneOptions struct {
+	// Ref is the branch, tag or commit to check out. Defaults to HEAD.
+	Ref string
+	// Depth limits the fetched history to the given number of commits.
+	// Zero fetches the full history along with all branches and tags.
+	Depth int
+	// Sparse restricts the working tree to the given directories.
+	Sparse []string
+	// Submodules checks out submodules from their local clones.
+	Submodules bool
+	// Recursive also checks out nested submodules.
+	Recursive bool
+}
+
+// CloneLocal creates a fresh clone of the local repository repo in dst,
+// without accessing the network. dst must not exist or be empty.
+func CloneLocal(repo string, dst string, opts *CloneOptions) error {
+	if opts == nil {
+		opts = &CloneOptions{}
+	}
+	ref := opts.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	// The commit is fetched by its full SHA, which also covers branches,
+	// tags and abbreviated SHAs.
+	sha, err := ResolveCommit(repo, ref)
+	if err != nil {
+		return err
+	}
+
+	if _, err := run(".", "init", "-q", dst); err != nil {
+		return err
+	}
+
+	// allowAnySHA1InWant lets the local upload-pack serve commits that are
+	// not at the tip of a branch, so any commit can be checked out.
+	fetch := []string{"-c", "uploadpack.allowAnySHA1InWant=true", "fetch", "-q", "--no-recurse-submodules"}
+	if opts.Depth > 0 {
+		fetch = append(fetch, "--depth", fmt.Sprint(opts.Depth))
+	}
+	fetch = append(fetch, "file://"+repo, sha)
+	if _, err := run(dst, fetch...); err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", ref, err)
+	}
+	if opts.Depth == 0 {
+		if _, err := run(dst, "fetch", "-q", "--tags", "file://"+repo, "+refs/heads/*:refs/remotes/origin/*"); err != nil {
+			return fmt.Errorf("failed to fetch branches and tags: %w", err)
+		}
+	}
+
+	if len(opts.Sparse) > 0 {
+		args := append([]string{"sparse-checkout", "set"}, opts.Sparse...)
+		if _, err := run(dst, args...); err != nil {
+			return fmt.Errorf("failed to configure sparse checkout: %w", err)
+		}
+	}
+
+	if _, err := run(dst, "checkout", "-q", "--detach", sha); err != nil {
+		return fmt.Errorf("failed to check out %s: %w", ref, err)
+	}
+
+	if opts.Submodules {
+		if err := updateSubmodules(repo, dst, opts.Recursive); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateSubmodules points each submodule of dst at the matching submodule
+// clone inside repo and checks it out.
+func updateSubmodules(repo string, dst string, recursive bool) error {
+	out, err := run(dst, "config", "-f", ".gitmodules", "--get-regexp", `^submodule\..*\.path$`)
+	if err != nil {
+		// no .gitmodules or no submodules
+		return nil
+	}
+	for _, line := range strings.Split(out, "\n") {
+		key, path, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(key, "submodule."), ".path")
+		local := repo + "/" + path
+		if _, err := run(dst, "config", "submodule."+name+".url", local); err != nil {
+			return fmt.Errorf("failed to configure submodule %s: %w", name, err)
+		}
+	}
+
+	args := []string{"-c", "protocol.file.allow=always", "submodule", "update", "--init"}
+	if recursive {
+		args = append(args, "--recursive")
+	}
+	if _, err := run(dst, args...); err != nil {
+		return fmt.Errorf("failed to update submodules: %w", err)
+	}
+	return nil
+}
+
+// RepoInfo describes the state of a local repository the way GitHub
+// describes the repository a workflow runs for.
+type RepoInfo struct {
+	// SHA is the commit HEAD points at.
+	SHA string
+	// Ref is the fully qualified ref of HEAD, e.g. refs/heads/main or
+	// refs/tags/v1.0.0. It is empty when HEAD is detached and not tagged.
+	Ref string
+	// Repository is the owner/name of the origin remote, or empty when
+	// there is no origin remote.
+	Repository string
+}
+
+// Inspect returns the commit, ref and repository of the repository
+// containing dir.
+func Inspect(dir string) (*RepoInfo, error) {
+	sha, err := run(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	info := &RepoInfo{SHA: sha}
+	if ref, err := run(dir, "symbolic-ref", "-q", "HEAD"); err == nil {
+		info.Ref = ref
+	} else if tag, err := run(dir, "describe", "--tags", "--exact-match", "HEAD"); err == nil {
+		info.Ref = "refs/tags/" + tag
+	}
+	if url, err := run(dir, "remote", "get-url", "origin"); err == nil {
+		info.Repository = repositoryFromURL(url)
+	}
+	return info, nil
+}
+
+// repositoryFromURL extracts owner/name from a remote URL such as
+// https://github.com/owner/name.git or git@github.com:owner/name.git.
+func repositoryFromURL(url string) string {
+	url = strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+	url = strings.ReplaceAll(url, ":", "/")
+	parts := strings.Split(url, "/")
+	if len(parts) < 2 || parts[len(parts)-2] == "" || parts[len(parts)-1] == "" {
+		return ""
+	}
+	return parts[len(parts)-2] + "/" + parts[len(parts)-1]
+}
+
+// Upstream returns the remote-tracking branch the current branch pushes
+// to, e.g. origin/feature.
+func Upstream(dir string) (string, error) {
+	return run(dir, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")
+}
+
+// DefaultBranch returns the name of the repository's default branch: the
+// branch origin/HEAD points at, else main or master if they exist.
+func DefaultBranch(dir string) string {
+	if ref, err := run(dir, "symbolic-ref", "--short", "refs/remotes/origin/HEAD"); err == nil {
+		return strings.TrimPrefix(ref, "origin/")
+	}
+	for _, branch := range []string{"main", "master"} {
+		if _, err := run(dir, "rev-parse", "--verify", "-q", "refs/heads/"+branch); err == nil {
+			return branch
+		}
+	}
+	return "main"
+}
+
+// ChangedFiles returns the files that differ between the working tree,
+// including untracked files, and the merge base of HEAD and base. Paths
+// are relative to the repository root.
+func ChangedFiles(dir string, base string) ([]string, error) {
+	mergeBase, err := run(dir, "merge-base", "HEAD", base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find merge base with %s: %w", base, err)
+	}
+	diff, err := run(dir, "diff", "--name-only", "--no-renames", mergeBase)
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := run(dir, "ls-files", "--others", "--exclude-standard", "--full-name", "--", ":/")
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var files []string
+	for _, line := range strings.Split(diff+"\n"+untracked, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !seen[line] {
+			seen[line] = true
+			files = append(files, line)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// FetchRef checks out ref, a branch, tag or commit, of the remote
+// repository at url into dst, fetching only that commit.
+func FetchRef(url string, ref string, dst string) error {
+	if _, err := run(".", "init", "-q", dst); err != nil {
+		return err
+	}
+	if _, err := run(dst, "fetch", "-q", "--depth", "1", url, ref); err != nil {
+		return fmt.Errorf("failed to fetch %s from %s: %w", ref, url, err)
+	}
+	if _, err := run(dst, "checkout", "-q", "--detach", "FETCH_HEAD"); err != nil {
+		return fmt.Errorf("failed to check out %s: %w", ref, err)
+	}
+	return nil
+}
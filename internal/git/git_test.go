@@ -0,0 +1,260 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// initRepo creates a git repository with a single commit in a temporary
+// directory and returns its path.
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "test"},
+	} {
+		if _, err := run(dir, args...); err != nil {
+			t.Fatalf("failed to set up repository: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"add", "README.md"},
+		{"commit", "-q", "-m", "initial"},
+	} {
+		if _, err := run(dir, args...); err != nil {
+			t.Fatalf("failed to set up repository: %v", err)
+		}
+	}
+	// Resolve symlinks (e.g. /tmp on macOS) so paths compare equal to git's output
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resolved
+}
+
+func TestTopLevel(t *testing.T) {
+	repo := initRepo(t)
+	sub := filepath.Join(repo, "sub")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := TopLevel(sub)
+	if err != nil {
+		t.Fatalf("TopLevel failed: %v", err)
+	}
+	if got != repo {
+		t.Fatalf("expected %s, got %s", repo, got)
+	}
+
+	if _, err := TopLevel(t.TempDir()); err == nil {
+		t.Fatalf("expected TopLevel to fail outside a repository")
+	}
+}
+
+func TestCloneLocal(t *testing.T) {
+	repo := initRepo(t)
+	first, err := run(repo, "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(repo, "docs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "README.md"), []byte("updated\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "docs", "guide.md"), []byte("guide\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"add", "."}, {"commit", "-q", "-m", "second"}} {
+		if _, err := run(repo, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An older commit with a shallow history
+	dst := filepath.Join(t.TempDir(), "old")
+	if err := CloneLocal(repo, dst, &CloneOptions{Ref: first, Depth: 1}); err != nil {
+		t.Fatalf("CloneLocal failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dst, "README.md"))
+	if err != nil || string(data) != "hello\n" {
+		t.Fatalf("expected README from first commit, got %q (%v)", data, err)
+	}
+
+	// The branch tip with a sparse working tree
+	dst = filepath.Join(t.TempDir(), "sparse")
+	if err := CloneLocal(repo, dst, &CloneOptions{Ref: "main", Sparse: []string{"docs"}}); err != nil {
+		t.Fatalf("CloneLocal failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "docs", "guide.md")); err != nil {
+		t.Fatalf("expected docs/guide.md to be checked out: %v", err)
+	}
+	if head, err := run(dst, "rev-parse", "HEAD"); err != nil || head == first {
+		t.Fatalf("expected the branch tip to be checked out, got %s (%v)", head, err)
+	}
+
+	// An abbreviated SHA
+	dst = filepath.Join(t.TempDir(), "short")
+	if err := CloneLocal(repo, dst, &CloneOptions{Ref: first[:7], Depth: 1}); err != nil {
+		t.Fatalf("CloneLocal failed: %v", err)
+	}
+	if head, err := run(dst, "rev-parse", "HEAD"); err != nil || head != first {
+		t.Fatalf("expected %s to be checked out, got %s (%v)", first, head, err)
+	}
+	if !IsShallow(dst) || IsShallow(repo) {
+		t.Fatalf("expected only the clone to be shallow")
+	}
+
+	if err := CloneLocal(repo, filepath.Join(t.TempDir(), "missing"), &CloneOptions{Ref: "no-such-branch"}); err == nil {
+		t.Fatalf("expected an unknown ref to fail")
+	}
+}
+
+func TestUninitializedSubmodules(t *testing.T) {
+	lib := initRepo(t)
+	repo := initRepo(t)
+	for _, args := range [][]string{
+		{"-c", "protocol.file.allow=always", "submodule", "add", "-q", lib, "lib"},
+		{"commit", "-q", "-m", "add lib"},
+	} {
+		if _, err := run(repo, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if paths, err := UninitializedSubmodules(repo, true); err != nil || len(paths) != 0 {
+		t.Fatalf("expected the submodule to be checked out, got %v (%v)", paths, err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "clone")
+	if err := CloneLocal(repo, dst, nil); err != nil {
+		t.Fatalf("CloneLocal failed: %v", err)
+	}
+	if paths, err := UninitializedSubmodules(dst, false); err != nil || strings.Join(paths, ",") != "lib" {
+		t.Fatalf("expected lib to be uninitialized, got %v (%v)", paths, err)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	repo := initRepo(t)
+	sha, err := run(repo, "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(repo, "remote", "add", "origin", "git@github.com:octo/hello.git"); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := Inspect(repo)
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if info.SHA != sha || info.Ref != "refs/heads/main" || info.Repository != "octo/hello" {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+
+	for _, args := range [][]string{
+		{"tag", "v1.0.0"},
+		{"checkout", "-q", "--detach"},
+	} {
+		if _, err := run(repo, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	info, err = Inspect(repo)
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if info.Ref != "refs/tags/v1.0.0" {
+		t.Fatalf("expected the tag ref, got %q", info.Ref)
+	}
+}
+
+func TestRepositoryFromURL(t *testing.T) {
+	for url, want := range map[string]string{
+		"https://github.com/octo/hello.git": "octo/hello",
+		"https://github.com/octo/hello":     "octo/hello",
+		"git@github.com:octo/hello.git":     "octo/hello",
+		"ssh://git@host:22/octo/hello.git":  "octo/hello",
+		"hello":                             "",
+	} {
+		if got := repositoryFromURL(url); got != want {
+			t.Fatalf("repositoryFromURL(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+func TestChangedFiles(t *testing.T) {
+	repo := initRepo(t)
+	if got := DefaultBranch(repo); got != "main" {
+		t.Fatalf("expected default branch main, got %q", got)
+	}
+	if _, err := Upstream(repo); err == nil {
+		t.Fatalf("expected no upstream for a local branch")
+	}
+
+	if _, err := run(repo, "checkout", "-q", "-b", "feature"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(repo, "src"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "src", "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"add", "."}, {"commit", "-q", "-m", "add main"}} {
+		if _, err := run(repo, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(repo, "README.md"), []byte("changed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "notes.txt"), []byte("new\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ChangedFiles(filepath.Join(repo, "src"), "main")
+	if err != nil {
+		t.Fatalf("ChangedFiles failed: %v", err)
+	}
+	want := []string{"README.md", "notes.txt", "src/main.go"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	if _, err := ChangedFiles(repo, "missing"); err == nil {
+		t.Fatalf("expected an unknown base to fail")
+	}
+}
+
+func TestFetchRef(t *testing.T) {
+	repo := initRepo(t)
+	if _, err := run(repo, "tag", "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "checkout")
+	if err := FetchRef("file://"+repo, "v1", dst); err != nil {
+		t.Fatalf("FetchRef failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "README.md")); err != nil {
+		t.Fatalf("expected README.md to be checked out: %v", err)
+	}
+	if err := FetchRef("file://"+repo, "missing", filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatalf("expected fetching a missing ref to fail")
+	}
+}
@@ -0,0 +1,186 @@
+// Package glob matches slash-separated paths and refs against the glob
+// patterns used by GitHub Actions in hashFiles() and trigger filters. The
+// two differ: in filter patterns '?' and '+' repeat the preceding
+// character.
+package glob
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Match reports whether a slash-separated name matches a hashFiles() glob
+// pattern.
+// '*' matches any sequence of characters except '/', '**' matches any
+// sequence including '/', '?' matches a single character other than '/'
+// and '[...]' matches one character of a set such as [a-z0-9].
+func Match(pattern, name string) bool {
+	if pattern == "" {
+		return name == ""
+	}
+	switch pattern[0] {
+	case '*':
+		if strings.HasPrefix(pattern, "**") {
+			rest := pattern[2:]
+			if strings.HasPrefix(rest, "/") {
+				// '**/' matches zero or more leading directories
+				rest = rest[1:]
+				if Match(rest, name) {
+					return true
+				}
+				for i := 0; i < len(name); i++ {
+					if name[i] == '/' && Match(rest, name[i+1:]) {
+						return true
+					}
+				}
+				return false
+			}
+			for i := 0; i <= len(name); i++ {
+				if Match(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		for i := 0; i <= len(name); i++ {
+			if Match(pattern[1:], name[i:]) {
+				return true
+			}
+			if i < len(name) && name[i] == '/' {
+				break
+			}
+		}
+		return false
+	case '?':
+		return name != "" && name[0] != '/' && Match(pattern[1:], name[1:])
+	case '[':
+		end := strings.IndexByte(pattern[1:], ']')
+		if end < 0 {
+			break
+		}
+		return name != "" && inClass(pattern[1:end+1], name[0]) && Match(pattern[end+2:], name[1:])
+	}
+	return name != "" && name[0] == pattern[0] && Match(pattern[1:], name[1:])
+}
+
+// inClass reports whether c is in a character class such as a-z0-9_.
+func inClass(class string, c byte) bool {
+	for i := 0; i < len(class); i++ {
+		if i+2 < len(class) && class[i+1] == '-' {
+			if class[i] <= c && c <= class[i+2] {
+				return true
+			}
+			i += 2
+			continue
+		}
+		if class[i] == c {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchAny reports whether name matches any of the patterns.
+func MatchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if Match(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchPattern reports whether a branch, tag or path matches a trigger
+// filter pattern. '*' matches any sequence of characters except '/', '**'
+// matches any sequence including '/', '?' matches zero or one of the
+// preceding character, '+' matches one or more of it, '[...]' matches one
+// character of a set such as [a-z0-9] and '\' escapes the character after
+// it. An invalid pattern, see CheckPattern, matches nothing.
+func MatchPattern(pattern, name string) bool {
+	re, err := filterRegexp(pattern)
+	return err == nil && re.MatchString(name)
+}
+
+// CheckPattern returns an error if pattern is not a valid filter pattern,
+// e.g. because a set has a range such as [z-a].
+func CheckPattern(pattern string) error {
+	if _, err := filterRegexp(pattern); err != nil {
+		return fmt.Errorf("invalid filter pattern %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// filterRegexp translates a filter pattern into a regular expression.
+func filterRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	p := []rune(pattern)
+	// repeatable reports whether the last token is a single character or
+	// set, which '?' and '+' apply to; otherwise they are literals.
+	repeatable := false
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; {
+		case c == '*' && i+1 < len(p) && p[i+1] == '*':
+			i++
+			if i+1 < len(p) && p[i+1] == '/' {
+				// '**/' matches zero or more leading directories
+				i++
+				b.WriteString("(?:.*/)?")
+			} else {
+				b.WriteString(".*")
+			}
+			repeatable = false
+		case c == '*':
+			b.WriteString("[^/]*")
+			repeatable = false
+		case (c == '?' || c == '+') && repeatable:
+			b.WriteRune(c)
+			repeatable = false
+		case c == '[' && closingBracket(p, i) > i+1:
+			end := closingBracket(p, i)
+			b.WriteString("[" + regexp.QuoteMeta(string(p[i+1:end])) + "]")
+			i = end
+			repeatable = true
+		case c == '\\' && i+1 < len(p):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(p[i])))
+			repeatable = true
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+			repeatable = true
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// closingBracket returns the index of the ']' closing the set opened at
+// p[open], or -1.
+func closingBracket(p []rune, open int) int {
+	for i := open + 1; i < len(p); i++ {
+		if p[i] == ']' {
+			return i
+		}
+	}
+	return -1
+}
+
+// MatchFilter evaluates a GitHub filter list against name. Patterns are
+// applied in order: a pattern includes the names it matches and a pattern
+// starting with '!' excludes them again, so the last matching pattern wins.
+func MatchFilter(patterns []string, name string) bool {
+	matched := false
+	for _, p := range patterns {
+		if strings.HasPrefix(p, "!") {
+			if matched && MatchPattern(p[1:], name) {
+				matched = false
+			}
+			continue
+		}
+		if !matched && MatchPattern(p, name) {
+			matched = true
+		}
+	}
+	return matched
+}
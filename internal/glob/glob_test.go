@@ -0,0 +1,100 @@
+package glob
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "cmd/ici/main.go", true},
+		{"cmd/**", "cmd/ici/main.go", true},
+		{"?.txt", "a.txt", true},
+		{"?.txt", "ab.txt", false},
+		{"release/*", "release/v1", true},
+		{"release/*", "release/v1/hotfix", false},
+		{"release/**", "release/v1/hotfix", true},
+		{"v[0-9].*", "v1.0", true},
+		{"v[0-9].*", "vx.0", false},
+		{"[abc]", "b", true},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchFilter(t *testing.T) {
+	patterns := []string{"releases/**", "!releases/**-alpha", "releases/keep-alpha"}
+	tests := map[string]bool{
+		"releases/v1":         true,
+		"releases/v1-alpha":   false,
+		"releases/keep-alpha": true,
+		"main":                false,
+	}
+	for name, want := range tests {
+		if got := MatchFilter(patterns, name); got != want {
+			t.Errorf("MatchFilter(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"feature/*", "feature/login", true},
+		{"feature/*", "feature/a/b", false},
+		{"feature/**", "feature/a/b", true},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "cmd/ici/main.go", true},
+		// '+' repeats the preceding character or set
+		{"v[0-9]+", "v12", true},
+		{"v[0-9]+", "v", false},
+		{"v[0-9]+", "v1a", false},
+		{"ab+c", "abbbc", true},
+		{"ab+c", "ac", false},
+		// '?' makes the preceding character optional
+		{"v1.?", "v1.", true},
+		{"v1.?", "v1", true},
+		{"v1.?", "v1.0", false},
+		{"docs?/*.md", "doc/a.md", true},
+		{"docs?/*.md", "docs/a.md", true},
+		{"*.jsx?", "app.js", true},
+		{"*.jsx?", "app.jsx", true},
+		// characters that are special in regular expressions are literals
+		{"release.(v1)", "release.(v1)", true},
+		{"release.(v1)", "releaseX(v1)", false},
+		{"\\*", "*", true},
+		{"\\*", "a", false},
+		{"[abc]", "b", true},
+		{"[]", "[]", true},
+		{"*+", "x+", true},
+	}
+	for _, tt := range tests {
+		if got := MatchPattern(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("MatchPattern(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+
+	// invalid patterns match nothing rather than panicking
+	if err := CheckPattern("[z-a]"); err == nil {
+		t.Errorf("expected [z-a] to be an invalid pattern")
+	}
+	if MatchPattern("[z-a]", "main") || MatchFilter([]string{"[z-a]"}, "main") {
+		t.Errorf("expected an invalid pattern to match nothing")
+	}
+	if err := CheckPattern("releases/**-v[0-9]+"); err != nil {
+		t.Errorf("CheckPattern: %v", err)
+	}
+
+	// hashFiles() globs keep '?' as any single character
+	if !Match("?.txt", "a.txt") || MatchPattern("?.txt", "a.txt") {
+		t.Errorf("expected '?' to differ between hashFiles() and filter patterns")
+	}
+}
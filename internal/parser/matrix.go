@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Matrix represents strategy.matrix. Dimensions keep the order they were
+// declared in so that job instances are named the same way GitHub names them.
+type Matrix struct {
+	// Dimensions holds the matrix variables and their values.
+	Dimensions []MatrixDimension
+	// Include holds extra combinations, or extra values for existing ones.
+	Include []map[string]interface{}
+	// Exclude holds (partial) combinations to remove.
+	Exclude []map[string]interface{}
+	// Expression holds the whole matrix when it is given as a single
+	// ${{ }} expression, e.g. ${{ fromJSON(needs.setup.outputs.matrix) }}.
+	Expression string
+}
+
+// MatrixDimension is a single matrix variable.
+type MatrixDimension struct {
+	Key    string
+	Values []interface{}
+	// Expression holds the values when they are given as a single ${{ }}
+	// expression rather than a list.
+	Expression string
+}
+
+// UnmarshalYAML decodes a matrix, preserving the order of its dimensions.
+func (m *Matrix) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		m.Expression = node.Value
+		return nil
+	case yaml.MappingNode:
+	default:
+		return fmt.Errorf("line %d: matrix must be a mapping or an expression", node.Line)
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		switch key {
+		case "include":
+			if err := decodeCombinations(value, &m.Include); err != nil {
+				return err
+			}
+		case "exclude":
+			if err := decodeCombinations(value, &m.Exclude); err != nil {
+				return err
+			}
+		default:
+			dim := MatrixDimension{Key: key}
+			switch value.Kind {
+			case yaml.ScalarNode:
+				dim.Expression = value.Value
+			case yaml.SequenceNode:
+				if err := value.Decode(&dim.Values); err != nil {
+					return fmt.Errorf("line %d: invalid values for matrix key %s: %w", value.Line, key, err)
+				}
+			default:
+				return fmt.Errorf("line %d: matrix key %s must be a list", value.Line, key)
+			}
+			m.Dimensions = append(m.Dimensions, dim)
+		}
+	}
+	return nil
+}
+
+func decodeCombinations(node *yaml.Node, out *[]map[string]interface{}) error {
+	if node.Kind == yaml.ScalarNode {
+		return fmt.Errorf("line %d: expressions in matrix include/exclude are not supported", node.Line)
+	}
+	if err := node.Decode(out); err != nil {
+		return fmt.Errorf("line %d: invalid matrix combinations: %w", node.Line, err)
+	}
+	return nil
+}
+
+// MarshalYAML encodes the matrix back into its workflow form.
+func (m Matrix) MarshalYAML() (interface{}, error) {
+	if m.Expression != "" {
+		return m.Expression, nil
+	}
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	add := func(key string, value interface{}) error {
+		var v yaml.Node
+		if err := v.Encode(value); err != nil {
+			return err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &v)
+		return nil
+	}
+	for _, dim := range m.Dimensions {
+		var value interface{} = dim.Values
+		if dim.Expression != "" {
+			value = dim.Expression
+		}
+		if err := add(dim.Key, value); err != nil {
+			return nil, err
+		}
+	}
+	if len(m.Include) > 0 {
+		if err := add("include", m.Include); err != nil {
+			return nil, err
+		}
+	}
+	if len(m.Exclude) > 0 {
+		if err := add("exclude", m.Exclude); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+// MarshalJSON encodes the matrix as a JSON object.
+func (m Matrix) MarshalJSON() ([]byte, error) {
+	if m.Expression != "" {
+		return json.Marshal(m.Expression)
+	}
+	out := make(map[string]interface{}, len(m.Dimensions)+2)
+	for _, dim := range m.Dimensions {
+		if dim.Expression != "" {
+			out[dim.Key] = dim.Expression
+		} else {
+			out[dim.Key] = dim.Values
+		}
+	}
+	if len(m.Include) > 0 {
+		out["include"] = m.Include
+	}
+	if len(m.Exclude) > 0 {
+		out["exclude"] = m.Exclude
+	}
+	return json.Marshal(out)
+}
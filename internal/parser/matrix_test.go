@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMatrix_UnmarshalPreservesOrder(t *testing.T) {
+	src := `
+strategy:
+  matrix:
+    os: [ubuntu-latest, ubuntu-22.04]
+    go: ['1.24', '1.25']
+    include:
+      - go: '1.25'
+        experimental: true
+    exclude:
+      - os: ubuntu-22.04
+        go: '1.24'
+`
+	var job Job
+	if err := yaml.Unmarshal([]byte(src), &job); err != nil {
+		t.Fatalf("failed to unmarshal job: %v", err)
+	}
+
+	m := job.Strategy.Matrix
+	if len(m.Dimensions) != 2 || m.Dimensions[0].Key != "os" || m.Dimensions[1].Key != "go" {
+		t.Fatalf("expected dimensions os, go in order, got %+v", m.Dimensions)
+	}
+	if len(m.Dimensions[1].Values) != 2 || m.Dimensions[1].Values[1] != "1.25" {
+		t.Fatalf("unexpected go values: %v", m.Dimensions[1].Values)
+	}
+	if len(m.Include) != 1 || m.Include[0]["experimental"] != true {
+		t.Fatalf("unexpected include: %v", m.Include)
+	}
+	if len(m.Exclude) != 1 || m.Exclude[0]["os"] != "ubuntu-22.04" {
+		t.Fatalf("unexpected exclude: %v", m.Exclude)
+	}
+
+	out, err := yaml.Marshal(m)
+	if err != nil {
+		t.Fatalf("failed to marshal matrix: %v", err)
+	}
+	var roundTrip Matrix
+	if err := yaml.Unmarshal(out, &roundTrip); err != nil {
+		t.Fatalf("failed to unmarshal marshalled matrix: %v", err)
+	}
+	if len(roundTrip.Dimensions) != 2 || roundTrip.Dimensions[0].Key != "os" {
+		t.Fatalf("round trip lost dimensions: %+v", roundTrip.Dimensions)
+	}
+}
+
+func TestMatrix_UnmarshalExpression(t *testing.T) {
+	var m Matrix
+	if err := yaml.Unmarshal([]byte("${{ fromJSON(needs.setup.outputs.matrix) }}"), &m); err != nil {
+		t.Fatalf("failed to unmarshal matrix: %v", err)
+	}
+	if m.Expression != "${{ fromJSON(needs.setup.outputs.matrix) }}" {
+		t.Fatalf("unexpected expression %q", m.Expression)
+	}
+}
+
+func TestStrategy_GetFailFast(t *testing.T) {
+	var job Job
+	if err := yaml.Unmarshal([]byte("strategy:\n  max-parallel: 2\n"), &job); err != nil {
+		t.Fatalf("failed to unmarshal job: %v", err)
+	}
+	if !job.Strategy.GetFailFast() || job.Strategy.MaxParallel != 2 {
+		t.Fatalf("expected fail-fast to default to true, got %+v", job.Strategy)
+	}
+
+	if err := yaml.Unmarshal([]byte("strategy:\n  fail-fast: false\n"), &job); err != nil {
+		t.Fatalf("failed to unmarshal job: %v", err)
+	}
+	if job.Strategy.GetFailFast() {
+		t.Fatalf("expected fail-fast to be false")
+	}
+
+	var none *Strategy
+	if !none.GetFailFast() {
+		t.Fatalf("expected fail-fast to default to true without a strategy")
+	}
+}
@@ -0,0 +1,293 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aykay76/ici/internal/glob"
+	"gopkg.in/yaml.v3"
+)
+
+// Triggers represents the `on:` section of a workflow, which may be an event
+// name, a list of event names or a mapping of events to their configuration.
+type Triggers struct {
+	// Events lists the triggering events in the order they are declared.
+	Events []string
+	// Filters holds the configuration of the events that declare activity
+	// types or branch, tag and path filters, by event name.
+	Filters map[string]*EventFilter
+	// Schedule holds the cron expressions of the schedule event.
+	Schedule []string
+	// WorkflowDispatch holds the configuration of manual runs.
+	WorkflowDispatch *WorkflowDispatch
+	// WorkflowCall holds the interface of a reusable workflow.
+	WorkflowCall *WorkflowCall
+
+	// node keeps the original YAML so the workflow can be re-encoded as is.
+	node *yaml.Node
+}
+
+// EventFilter holds the activity types and filters of an event.
+type EventFilter struct {
+	Types          []string
+	Branches       []string
+	BranchesIgnore []string
+	Tags           []string
+	TagsIgnore     []string
+	Paths          []string
+	PathsIgnore    []string
+}
+
+// WorkflowDispatch represents on.workflow_dispatch
+type WorkflowDispatch struct {
+	Inputs map[string]Input `yaml:"inputs,omitempty"`
+}
+
+// WorkflowCall represents on.workflow_call, the inputs, secrets and outputs
+// of a reusable workflow
+type WorkflowCall struct {
+	Inputs  map[string]Input      `yaml:"inputs,omitempty"`
+	Secrets map[string]CallSecret `yaml:"secrets,omitempty"`
+	Outputs map[string]CallOutput `yaml:"outputs,omitempty"`
+}
+
+// CallSecret represents a secret of a reusable workflow
+type CallSecret struct {
+	Description string `yaml:"description,omitempty"`
+	Required    bool   `yaml:"required,omitempty"`
+}
+
+// CallOutput represents an output of a reusable workflow, whose value
+// usually refers to the outputs of its jobs
+type CallOutput struct {
+	Description string `yaml:"description,omitempty"`
+	Value       string `yaml:"value"`
+}
+
+// Input represents an input of a manually run or reusable workflow
+type Input struct {
+	Description string   `yaml:"description,omitempty"`
+	Required    bool     `yaml:"required,omitempty"`
+	Default     string   `yaml:"default,omitempty"`
+	Type        string   `yaml:"type,omitempty"` // string (default), boolean, number, choice or environment
+	Options     []string `yaml:"options,omitempty"`
+}
+
+// UnmarshalYAML decodes `on:` in any of its forms.
+func (t *Triggers) UnmarshalYAML(node *yaml.Node) error {
+	t.node = node
+	switch node.Kind {
+	case yaml.ScalarNode:
+		t.Events = []string{node.Value}
+	case yaml.SequenceNode:
+		if err := node.Decode(&t.Events); err != nil {
+			return fmt.Errorf("line %d: invalid event list: %w", node.Line, err)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			event, value := node.Content[i].Value, node.Content[i+1]
+			t.Events = append(t.Events, event)
+			if err := t.decodeEvent(event, value); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("line %d: on must be an event, a list of events or a mapping", node.Line)
+	}
+	return nil
+}
+
+func (t *Triggers) decodeEvent(event string, value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode && (value.Tag == "!!null" || value.Value == "") {
+		return nil
+	}
+
+	if event == "schedule" {
+		var entries []struct {
+			Cron string `yaml:"cron"`
+		}
+		if err := value.Decode(&entries); err != nil {
+			return fmt.Errorf("line %d: invalid schedule: %w", value.Line, err)
+		}
+		for _, e := range entries {
+			t.Schedule = append(t.Schedule, e.Cron)
+		}
+		return nil
+	}
+
+	if value.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: configuration of %s must be a mapping", value.Line, event)
+	}
+	switch event {
+	case "workflow_dispatch":
+		t.WorkflowDispatch = &WorkflowDispatch{}
+		if err := value.Decode(t.WorkflowDispatch); err != nil {
+			return fmt.Errorf("line %d: invalid workflow_dispatch: %w", value.Line, err)
+		}
+	case "workflow_call":
+		t.WorkflowCall = &WorkflowCall{}
+		if err := value.Decode(t.WorkflowCall); err != nil {
+			return fmt.Errorf("line %d: invalid workflow_call: %w", value.Line, err)
+		}
+	}
+	f := &EventFilter{}
+	lists := map[string]*[]string{
+		"types":           &f.Types,
+		"branches":        &f.Branches,
+		"branches-ignore": &f.BranchesIgnore,
+		"tags":            &f.Tags,
+		"tags-ignore":     &f.TagsIgnore,
+		"paths":           &f.Paths,
+		"paths-ignore":    &f.PathsIgnore,
+	}
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		key, v := value.Content[i].Value, value.Content[i+1]
+		list, ok := lists[key]
+		if !ok {
+			// other settings, e.g. inputs, are not filters
+			continue
+		}
+		if v.Kind == yaml.ScalarNode {
+			*list = []string{v.Value}
+			continue
+		}
+		if err := v.Decode(list); err != nil {
+			return fmt.Errorf("line %d: invalid %s for %s: %w", v.Line, key, event, err)
+		}
+	}
+	for _, list := range [][]string{f.Branches, f.BranchesIgnore, f.Tags, f.TagsIgnore, f.Paths, f.PathsIgnore} {
+		for _, p := range list {
+			if err := glob.CheckPattern(strings.TrimPrefix(p, "!")); err != nil {
+				return fmt.Errorf("line %d: %s: %w", value.Line, event, err)
+			}
+		}
+	}
+	if (len(f.Branches) > 0 && len(f.BranchesIgnore) > 0) ||
+		(len(f.Tags) > 0 && len(f.TagsIgnore) > 0) ||
+		(len(f.Paths) > 0 && len(f.PathsIgnore) > 0) {
+		return fmt.Errorf("line %d: %s cannot use a filter and its -ignore variant together", value.Line, event)
+	}
+	if t.Filters == nil {
+		t.Filters = make(map[string]*EventFilter)
+	}
+	t.Filters[event] = f
+	return nil
+}
+
+// MarshalYAML encodes `on:` in the form it was written in.
+func (t Triggers) MarshalYAML() (interface{}, error) {
+	if t.node != nil {
+		return t.node, nil
+	}
+	if len(t.Events) == 1 {
+		return t.Events[0], nil
+	}
+	return t.Events, nil
+}
+
+// MarshalJSON encodes `on:` as JSON in the form it was written in.
+func (t Triggers) MarshalJSON() ([]byte, error) {
+	if t.node == nil {
+		return json.Marshal(t.Events)
+	}
+	var v interface{}
+	if err := t.node.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// Has reports whether the named event triggers the workflow.
+func (t *Triggers) Has(event string) bool {
+	for _, e := range t.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// TriggerState describes the push or pull request a workflow's filters are
+// evaluated against.
+type TriggerState struct {
+	// Ref is the fully qualified ref that is pushed, or the ref of the base
+	// branch of a pull request.
+	Ref string
+	// ChangedFiles lists the paths, relative to the repository root, that
+	// the push or pull request changes.
+	ChangedFiles []string
+}
+
+// Match reports whether the workflow runs for event given its branch, tag
+// and path filters. When it does not, the reason is returned.
+func (t *Triggers) Match(event string, state TriggerState) (bool, string) {
+	if !t.Has(event) {
+		return false, fmt.Sprintf("does not run on %s", event)
+	}
+	f := t.Filters[event]
+	if f == nil {
+		return true, ""
+	}
+	switch event {
+	case "push", "pull_request", "pull_request_target":
+	default:
+		return true, ""
+	}
+
+	isTag := strings.HasPrefix(state.Ref, "refs/tags/")
+	name := strings.TrimPrefix(strings.TrimPrefix(state.Ref, "refs/heads/"), "refs/tags/")
+	hasBranchFilter := len(f.Branches) > 0 || len(f.BranchesIgnore) > 0
+	hasTagFilter := len(f.Tags) > 0 || len(f.TagsIgnore) > 0
+
+	if isTag && event == "push" {
+		// a push filtered by branches only does not run for tags
+		if hasBranchFilter && !hasTagFilter {
+			return false, fmt.Sprintf("tag %s is not a branch", name)
+		}
+		if len(f.Tags) > 0 && !glob.MatchFilter(f.Tags, name) {
+			return false, fmt.Sprintf("tag %s does not match tags", name)
+		}
+		if len(f.TagsIgnore) > 0 && glob.MatchFilter(f.TagsIgnore, name) {
+			return false, fmt.Sprintf("tag %s matches tags-ignore", name)
+		}
+		// path filters are not evaluated for tags
+		return true, ""
+	}
+
+	if hasTagFilter && !hasBranchFilter && event == "push" {
+		return false, fmt.Sprintf("branch %s is not a tag", name)
+	}
+	if len(f.Branches) > 0 && !glob.MatchFilter(f.Branches, name) {
+		return false, fmt.Sprintf("branch %s does not match branches", name)
+	}
+	if len(f.BranchesIgnore) > 0 && glob.MatchFilter(f.BranchesIgnore, name) {
+		return false, fmt.Sprintf("branch %s matches branches-ignore", name)
+	}
+
+	if len(f.Paths) > 0 {
+		matched := false
+		for _, file := range state.ChangedFiles {
+			if glob.MatchFilter(f.Paths, file) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, "no changed file matches paths"
+		}
+	}
+	if len(f.PathsIgnore) > 0 && len(state.ChangedFiles) > 0 {
+		ignored := true
+		for _, file := range state.ChangedFiles {
+			if !glob.MatchFilter(f.PathsIgnore, file) {
+				ignored = false
+				break
+			}
+		}
+		if ignored {
+			return false, "every changed file matches paths-ignore"
+		}
+	}
+	return true, ""
+}
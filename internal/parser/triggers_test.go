@@ -0,0 +1,191 @@
+package parser
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const triggerWorkflow = `
+on:
+  push:
+    branches: [main, 'releases/**', '!releases/**-alpha']
+    tags: ['v*']
+    paths-ignore: ['docs/**', '**.md']
+  pull_request:
+    branches: main
+    paths: ['src/**']
+  schedule:
+    - cron: '0 3 * * *'
+  workflow_dispatch:
+`
+
+func parseTriggers(t *testing.T, src string) *Workflow {
+	t.Helper()
+	var wf Workflow
+	if err := yaml.Unmarshal([]byte(src), &wf); err != nil {
+		t.Fatalf("failed to unmarshal workflow: %v", err)
+	}
+	return &wf
+}
+
+func TestTriggers_Unmarshal(t *testing.T) {
+	wf := parseTriggers(t, triggerWorkflow)
+	on := wf.On
+	if strings.Join(on.Events, ",") != "push,pull_request,schedule,workflow_dispatch" {
+		t.Fatalf("unexpected events: %v", on.Events)
+	}
+	if len(on.Filters["push"].Branches) != 3 || on.Filters["push"].Tags[0] != "v*" {
+		t.Fatalf("unexpected push filter: %+v", on.Filters["push"])
+	}
+	if on.Filters["pull_request"].Branches[0] != "main" {
+		t.Fatalf("expected a scalar filter to become a list: %+v", on.Filters["pull_request"])
+	}
+	if len(on.Schedule) != 1 || on.Schedule[0] != "0 3 * * *" {
+		t.Fatalf("unexpected schedule: %v", on.Schedule)
+	}
+
+	if err := yaml.Unmarshal([]byte("on:\n  push:\n    branches: [a]\n    branches-ignore: [b]\n"), &Workflow{}); err == nil {
+		t.Fatalf("expected branches and branches-ignore together to fail")
+	}
+	err := yaml.Unmarshal([]byte("on:\n  push:\n    branches: ['!releases/[z-a]']\n"), &Workflow{})
+	if err == nil || !strings.Contains(err.Error(), "invalid filter pattern") {
+		t.Fatalf("expected an invalid filter pattern to fail, got %v", err)
+	}
+}
+
+func TestTriggers_WorkflowDispatchInputs(t *testing.T) {
+	wf := parseTriggers(t, `
+on:
+  workflow_dispatch:
+    inputs:
+      version:
+        description: Version to release
+        required: true
+      channel:
+        type: choice
+        options: [stable, beta]
+        default: stable
+      dry-run:
+        type: boolean
+        default: false
+`)
+	d := wf.On.WorkflowDispatch
+	if d == nil || len(d.Inputs) != 3 {
+		t.Fatalf("unexpected workflow_dispatch: %+v", d)
+	}
+	if !d.Inputs["version"].Required || d.Inputs["version"].Description != "Version to release" {
+		t.Fatalf("unexpected version input: %+v", d.Inputs["version"])
+	}
+	if d.Inputs["channel"].Type != "choice" || len(d.Inputs["channel"].Options) != 2 {
+		t.Fatalf("unexpected channel input: %+v", d.Inputs["channel"])
+	}
+	if d.Inputs["dry-run"].Default != "false" {
+		t.Fatalf("expected a boolean default to decode as a string: %+v", d.Inputs["dry-run"])
+	}
+}
+
+func TestTriggers_WorkflowCall(t *testing.T) {
+	src := `
+on:
+  workflow_call:
+    inputs:
+      version:
+        type: string
+        required: true
+    secrets:
+      token:
+        required: true
+    outputs:
+      image:
+        description: Built image
+        value: ${{ jobs.build.outputs.image }}
+`
+	var wf Workflow
+	if err := yaml.Unmarshal([]byte(src), &wf); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	call := wf.On.WorkflowCall
+	if call == nil || !call.Inputs["version"].Required || !call.Secrets["token"].Required {
+		t.Fatalf("unexpected workflow_call: %+v", call)
+	}
+	if call.Outputs["image"].Value != "${{ jobs.build.outputs.image }}" {
+		t.Fatalf("unexpected outputs: %+v", call.Outputs)
+	}
+}
+
+func TestTriggers_MarshalKeepsForm(t *testing.T) {
+	wf := parseTriggers(t, "on: [push, pull_request]\n")
+	data, err := json.Marshal(wf.On)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `["push","pull_request"]` {
+		t.Fatalf("unexpected JSON: %s", data)
+	}
+
+	wf = parseTriggers(t, triggerWorkflow)
+	out, err := yaml.Marshal(wf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "paths-ignore") {
+		t.Fatalf("expected filters to be re-encoded:\n%s", out)
+	}
+}
+
+func TestTriggers_Match(t *testing.T) {
+	on := parseTriggers(t, triggerWorkflow).On
+	code := []string{"src/main.go", "README.md"}
+	docs := []string{"docs/guide.md", "README.md"}
+
+	tests := []struct {
+		event string
+		state TriggerState
+		want  bool
+	}{
+		{"push", TriggerState{Ref: "refs/heads/main", ChangedFiles: code}, true},
+		{"push", TriggerState{Ref: "refs/heads/main", ChangedFiles: docs}, false},
+		{"push", TriggerState{Ref: "refs/heads/feature", ChangedFiles: code}, false},
+		{"push", TriggerState{Ref: "refs/heads/releases/v2", ChangedFiles: code}, true},
+		{"push", TriggerState{Ref: "refs/heads/releases/v2-alpha", ChangedFiles: code}, false},
+		{"push", TriggerState{Ref: "refs/tags/v1.0.0", ChangedFiles: docs}, true},
+		{"push", TriggerState{Ref: "refs/tags/nightly"}, false},
+		{"pull_request", TriggerState{Ref: "refs/heads/main", ChangedFiles: code}, true},
+		{"pull_request", TriggerState{Ref: "refs/heads/main", ChangedFiles: docs}, false},
+		{"workflow_dispatch", TriggerState{}, true},
+		{"release", TriggerState{}, false},
+	}
+	for _, tt := range tests {
+		got, reason := on.Match(tt.event, tt.state)
+		if got != tt.want {
+			t.Errorf("Match(%s, %+v) = %v (%s), want %v", tt.event, tt.state, got, reason, tt.want)
+		}
+		if !got && reason == "" {
+			t.Errorf("Match(%s, %+v) did not explain why it does not run", tt.event, tt.state)
+		}
+	}
+
+	branchesOnly := parseTriggers(t, "on:\n  push:\n    branches: [main]\n").On
+	if ok, _ := branchesOnly.Match("push", TriggerState{Ref: "refs/tags/v1"}); ok {
+		t.Fatalf("expected a branch-filtered push not to run for tags")
+	}
+
+	// '+' and '?' repeat the preceding character, as on GitHub
+	repeats := parseTriggers(t, "on:\n  push:\n    branches: ['v[0-9]+']\n    tags: ['v1.?']\n").On
+	for ref, want := range map[string]bool{
+		"refs/heads/v12":  true,
+		"refs/heads/v":    false,
+		"refs/tags/v1":    true,
+		"refs/tags/v1.":   true,
+		"refs/tags/v1.0":  false,
+		"refs/tags/v1x":   false,
+		"refs/heads/main": false,
+	} {
+		if got, _ := repeats.Match("push", TriggerState{Ref: ref}); got != want {
+			t.Errorf("Match(push, %s) = %v, want %v", ref, got, want)
+		}
+	}
+}
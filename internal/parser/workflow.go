@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 
@@ -9,31 +10,138 @@ import (
 
 // Workflow represents a GitHub Actions workflow
 type Workflow struct {
-	Name string            `yaml:"name"`
-	On   interface{}       `yaml:"on"` // Can be string, array, or map
-	Jobs map[string]Job    `yaml:"jobs"`
-	Env  map[string]string `yaml:"env,omitempty"`
+	Name     string            `yaml:"name"`
+	On       Triggers          `yaml:"on"`
+	Jobs     map[string]Job    `yaml:"jobs"`
+	Env      map[string]string `yaml:"env,omitempty"`
+	Defaults *Defaults         `yaml:"defaults,omitempty"`
 }
 
 // Job represents a single job in a workflow
 type Job struct {
-	Name    string            `yaml:"name,omitempty"`
-	RunsOn  interface{}       `yaml:"runs-on"` // Can be string or array
-	Steps   []Step            `yaml:"steps"`
-	Env     map[string]string `yaml:"env,omitempty"`
-	Needs   interface{}       `yaml:"needs,omitempty"` // Can be string or array
-	If      string            `yaml:"if,omitempty"`
-	Timeout int               `yaml:"timeout-minutes,omitempty"`
+	Name     string            `yaml:"name,omitempty"`
+	RunsOn   interface{}       `yaml:"runs-on,omitempty"` // Can be string or array
+	Steps    []Step            `yaml:"steps,omitempty"`
+	Env      map[string]string `yaml:"env,omitempty"`
+	Needs    interface{}       `yaml:"needs,omitempty"` // Can be string or array
+	If       string            `yaml:"if,omitempty"`
+	Timeout  int               `yaml:"timeout-minutes,omitempty"`
+	Strategy *Strategy         `yaml:"strategy,omitempty"`
+	Defaults *Defaults         `yaml:"defaults,omitempty"`
+	Outputs  map[string]string `yaml:"outputs,omitempty"`
+	// Container runs the job's steps in the given container rather than
+	// the runner image.
+	Container *Container `yaml:"container,omitempty"`
+	// Services are containers started next to the job, e.g. databases,
+	// reachable by their key.
+	Services map[string]Container `yaml:"services,omitempty"`
+
+	// Uses, With and Secrets call a reusable workflow instead of running
+	// steps.
+	Uses    string            `yaml:"uses,omitempty"`
+	With    map[string]string `yaml:"with,omitempty"`
+	Secrets *JobSecrets       `yaml:"secrets,omitempty"`
+}
+
+// Container represents the container a job runs in, or a service container
+type Container struct {
+	Image       string            `yaml:"image"`
+	Credentials *Credentials      `yaml:"credentials,omitempty"`
+	Env         map[string]string `yaml:"env,omitempty"`
+	Ports       []string          `yaml:"ports,omitempty"`
+	Volumes     []string          `yaml:"volumes,omitempty"`
+	Options     string            `yaml:"options,omitempty"`
+}
+
+// Credentials represents the registry credentials of a container image
+type Credentials struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// UnmarshalYAML decodes a container given as an image name or a mapping.
+func (c *Container) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		c.Image = node.Value
+		return nil
+	}
+	type plain Container
+	return node.Decode((*plain)(c))
+}
+
+// JobSecrets represents the secrets a job passes to a reusable workflow:
+// either `inherit` or a mapping of secret names to values.
+type JobSecrets struct {
+	Inherit bool
+	Values  map[string]string
+}
+
+// UnmarshalYAML decodes `secrets: inherit` or a mapping of secrets.
+func (s *JobSecrets) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		if node.Value != "inherit" {
+			return fmt.Errorf("line %d: secrets must be inherit or a mapping", node.Line)
+		}
+		s.Inherit = true
+		return nil
+	}
+	return node.Decode(&s.Values)
+}
+
+// MarshalYAML encodes the secrets in the form they are written in.
+func (s JobSecrets) MarshalYAML() (interface{}, error) {
+	if s.Inherit {
+		return "inherit", nil
+	}
+	return s.Values, nil
+}
+
+// MarshalJSON encodes the secrets in the form they are written in.
+func (s JobSecrets) MarshalJSON() ([]byte, error) {
+	if s.Inherit {
+		return json.Marshal("inherit")
+	}
+	return json.Marshal(s.Values)
+}
+
+// Defaults represents the defaults of a workflow or job
+type Defaults struct {
+	Run *RunDefaults `yaml:"run,omitempty"`
+}
+
+// RunDefaults represents defaults.run, applied to every run step
+type RunDefaults struct {
+	Shell            string `yaml:"shell,omitempty"`
+	WorkingDirectory string `yaml:"working-directory,omitempty"`
+}
+
+// Strategy represents a job's strategy
+type Strategy struct {
+	Matrix      *Matrix `yaml:"matrix,omitempty"`
+	FailFast    *bool   `yaml:"fail-fast,omitempty"` // Defaults to true
+	MaxParallel int     `yaml:"max-parallel,omitempty"`
+}
+
+// GetFailFast reports whether the remaining matrix instances are cancelled
+// when one of them fails.
+func (s *Strategy) GetFailFast() bool {
+	if s == nil || s.FailFast == nil {
+		return true
+	}
+	return *s.FailFast
 }
 
 // Step represents a single step in a job
 type Step struct {
-	Name string            `yaml:"name,omitempty"`
-	Uses string            `yaml:"uses,omitempty"`
-	Run  string            `yaml:"run,omitempty"`
-	With map[string]string `yaml:"with,omitempty"`
-	Env  map[string]string `yaml:"env,omitempty"`
-	If   string            `yaml:"if,omitempty"`
+	ID               string            `yaml:"id,omitempty"`
+	Name             string            `yaml:"name,omitempty"`
+	Uses             string            `yaml:"uses,omitempty"`
+	Run              string            `yaml:"run,omitempty"`
+	With             map[string]string `yaml:"with,omitempty"`
+	Env              map[string]string `yaml:"env,omitempty"`
+	If               string            `yaml:"if,omitempty"`
+	Shell            string            `yaml:"shell,omitempty"`
+	WorkingDirectory string            `yaml:"working-directory,omitempty"`
 }
 
 // ParseWorkflow reads and parses a GitHub Actions workflow file
@@ -82,3 +190,13 @@ func (j *Job) GetNeeds() []string {
 	}
 	return nil
 }
+
+// Events returns the names of the events that trigger the workflow
+func (w *Workflow) Events() []string {
+	return w.On.Events
+}
+
+// TriggeredBy reports whether the workflow runs for the named event.
+func (w *Workflow) TriggeredBy(event string) bool {
+	return w.On.Has(event)
+}
@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestWorkflow_Events(t *testing.T) {
+	tests := map[string][]string{
+		"on: push":                 {"push"},
+		"on: [push, pull_request]": {"push", "pull_request"},
+		"on:\n  workflow_dispatch:\n  push:\n    branches: [main]": {"workflow_dispatch", "push"},
+		"name: no triggers": nil,
+	}
+	for src, want := range tests {
+		var wf Workflow
+		if err := yaml.Unmarshal([]byte(src), &wf); err != nil {
+			t.Fatalf("failed to unmarshal %q: %v", src, err)
+		}
+		if got := wf.Events(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("Events() for %q = %v, want %v", src, got, want)
+		}
+	}
+
+	wf := Workflow{On: Triggers{Events: []string{"push"}}}
+	if !wf.TriggeredBy("push") || wf.TriggeredBy("release") {
+		t.Fatalf("unexpected TriggeredBy result")
+	}
+}
+
+func TestJob_ReusableWorkflowCall(t *testing.T) {
+	src := `
+jobs:
+  build:
+    uses: ./.github/workflows/build.yml
+    with:
+      version: 1.2
+      publish: true
+    secrets: inherit
+  deploy:
+    uses: octo/pipelines/.github/workflows/deploy.yml@v1
+    secrets:
+      token: ${{ secrets.DEPLOY_TOKEN }}
+`
+	var wf Workflow
+	if err := yaml.Unmarshal([]byte(src), &wf); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	build, deploy := wf.Jobs["build"], wf.Jobs["deploy"]
+	if build.Uses != "./.github/workflows/build.yml" || build.With["version"] != "1.2" || build.With["publish"] != "true" {
+		t.Fatalf("unexpected build job: %+v", build)
+	}
+	if build.Secrets == nil || !build.Secrets.Inherit {
+		t.Fatalf("expected secrets: inherit, got %+v", build.Secrets)
+	}
+	if deploy.Secrets == nil || deploy.Secrets.Inherit || deploy.Secrets.Values["token"] != "${{ secrets.DEPLOY_TOKEN }}" {
+		t.Fatalf("unexpected deploy secrets: %+v", deploy.Secrets)
+	}
+
+	out, err := yaml.Marshal(&wf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again Workflow
+	if err := yaml.Unmarshal(out, &again); err != nil || !again.Jobs["build"].Secrets.Inherit {
+		t.Fatalf("expected secrets to round-trip, got %v:\n%s", err, out)
+	}
+
+	if err := yaml.Unmarshal([]byte("jobs:\n  x:\n    secrets: all\n"), &Workflow{}); err == nil {
+		t.Fatalf("expected an invalid secrets value to fail")
+	}
+}
+
+func TestJob_ContainerAndServices(t *testing.T) {
+	src := `
+jobs:
+  short:
+    container: golang:1.25
+    services:
+      redis:
+        image: redis:7
+        ports: [6379]
+        options: --health-cmd "redis-cli ping"
+  full:
+    container:
+      image: ghcr.io/octo/build:1
+      credentials:
+        username: octo
+        password: ${{ secrets.TOKEN }}
+      env:
+        CGO_ENABLED: 0
+      ports: [8080, "9090:90"]
+      volumes: [/tmp/cache:/cache]
+      options: --cpus 1
+`
+	var wf Workflow
+	if err := yaml.Unmarshal([]byte(src), &wf); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if c := wf.Jobs["short"].Container; c == nil || c.Image != "golang:1.25" {
+		t.Fatalf("unexpected short container: %+v", c)
+	}
+	if svc := wf.Jobs["short"].Services["redis"]; svc.Image != "redis:7" || svc.Ports[0] != "6379" || svc.Options != `--health-cmd "redis-cli ping"` {
+		t.Fatalf("unexpected redis service: %+v", svc)
+	}
+	c := wf.Jobs["full"].Container
+	if c == nil || c.Image != "ghcr.io/octo/build:1" || c.Credentials.Username != "octo" || c.Env["CGO_ENABLED"] != "0" {
+		t.Fatalf("unexpected full container: %+v", c)
+	}
+	if !reflect.DeepEqual(c.Ports, []string{"8080", "9090:90"}) || c.Volumes[0] != "/tmp/cache:/cache" || c.Options != "--cpus 1" {
+		t.Fatalf("unexpected container settings: %+v", c)
+	}
+}
@@ -0,0 +1,277 @@
+// Package report writes the results of workflow runs in formats that other
+// tools understand: JSON, and JUnit XML for IDEs and CI dashboards.
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aykay76/ici/internal/runner"
+)
+
+// Report formats.
+const (
+	FormatJSON  = "json"
+	FormatJUnit = "junit"
+)
+
+// Formats lists the supported report formats.
+var Formats = []string{FormatJSON, FormatJUnit}
+
+// ValidFormat reports whether format is a supported report format.
+func ValidFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteFile writes the results of runs to path in the given format,
+// creating the file's directory as needed.
+func WriteFile(format string, path string, runs []*runner.RunResult) error {
+	if !ValidFormat(format) {
+		return fmt.Errorf("unsupported report format %q (use %s)", format, strings.Join(Formats, " or "))
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create report directory: %w", err)
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+	switch format {
+	case FormatJSON:
+		err = WriteJSON(f, runs)
+	case FormatJUnit:
+		err = WriteJUnit(f, runs)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s report %s: %w", format, path, err)
+	}
+	return nil
+}
+
+// WriteJSON writes the results of runs as a JSON document of the form
+// {"runs": [...]}. Durations are in nanoseconds.
+func WriteJSON(w io.Writer, runs []*runner.RunResult) error {
+	if runs == nil {
+		runs = []*runner.RunResult{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Runs []*runner.RunResult `json:"runs"`
+	}{runs})
+}
+
+// junitTestSuites is the root element of a JUnit XML report.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr,omitempty"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results of runs as JUnit XML: a test suite per job,
+// or per matrix instance, with a test case per step. Jobs of called
+// reusable workflows get suites of their own.
+func WriteJUnit(w io.Writer, runs []*runner.RunResult) error {
+	root := junitTestSuites{}
+	var total time.Duration
+	for _, run := range runs {
+		if len(runs) == 1 {
+			root.Name = run.Workflow
+		}
+		total += run.Duration
+		for _, job := range flattenJobs(run.Jobs) {
+			suite := jobSuite(run.Workflow, job)
+			if suite.Tests == 0 {
+				// a job that called a reusable workflow is reported
+				// through the suites of the called jobs
+				continue
+			}
+			root.Tests += suite.Tests
+			root.Failures += suite.Failures
+			root.Errors += suite.Errors
+			root.Skipped += suite.Skipped
+			root.Suites = append(root.Suites, suite)
+		}
+	}
+	root.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// flattenJobs returns jobs with the jobs of called reusable workflows
+// following the job that called them.
+func flattenJobs(jobs []*runner.JobResult) []*runner.JobResult {
+	var out []*runner.JobResult
+	for _, job := range jobs {
+		out = append(out, job)
+		out = append(out, flattenJobs(job.Jobs)...)
+	}
+	return out
+}
+
+// jobSuite returns the test suite of a job instance.
+func jobSuite(workflow string, job *runner.JobResult) junitTestSuite {
+	name := job.Name
+	if workflow != "" {
+		name = workflow + " / " + job.Name
+	}
+	suite := junitTestSuite{Name: name, Time: seconds(job.Duration)}
+	if !job.Started.IsZero() {
+		suite.Timestamp = job.Started.Format("2006-01-02T15:04:05")
+	}
+	suite.Properties = append(suite.Properties, junitProperty{Name: "result", Value: job.Result})
+	var keys []string
+	for k := range job.Matrix {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		suite.Properties = append(suite.Properties, junitProperty{Name: "matrix." + k, Value: fmt.Sprint(job.Matrix[k])})
+	}
+
+	stepFailed := false
+	for _, step := range job.Steps {
+		tc := junitTestCase{
+			Name:      fmt.Sprintf("%d. %s", step.Number, step.Name),
+			Classname: name,
+			Time:      seconds(step.Duration),
+			SystemOut: step.Stdout,
+			SystemErr: step.Stderr,
+		}
+		switch step.Outcome {
+		case "failure":
+			stepFailed = true
+			typ := "error"
+			if step.ExitCode != 0 {
+				typ = fmt.Sprintf("exit code %d", step.ExitCode)
+			}
+			tc.Failure = &junitMessage{Message: step.Error, Type: typ, Text: annotationText(step.Annotations)}
+		case "skipped", "cancelled":
+			tc.Skipped = &junitMessage{Message: step.SkipReason}
+		default:
+			if text := annotationText(step.Annotations); text != "" {
+				tc.SystemOut += text
+			}
+		}
+		suite.addCase(tc)
+	}
+
+	// Jobs that did not run, and failures outside the steps, such as a
+	// container that failed to start, are reported as a test case of the
+	// job itself, named after the phases GitHub shows.
+	switch {
+	case len(job.Jobs) > 0:
+	case job.Result == "skipped" || (job.Result == "cancelled" && len(job.Steps) == 0):
+		reason := job.SkipReason
+		if reason == "" {
+			reason = "the job was " + job.Result
+		}
+		suite.addCase(junitTestCase{Name: job.Name, Classname: name, Time: seconds(0), Skipped: &junitMessage{Message: reason}})
+	case job.Result == "failure" && !stepFailed:
+		phase := "Complete job"
+		if len(job.Steps) == 0 {
+			phase = "Set up job"
+		}
+		suite.addCase(junitTestCase{
+			Name:      phase,
+			Classname: name,
+			Time:      seconds(0),
+			Error:     &junitMessage{Message: job.Error, Text: annotationText(job.Annotations)},
+		})
+	}
+	return suite
+}
+
+// addCase adds a test case to the suite and counts it.
+func (s *junitTestSuite) addCase(tc junitTestCase) {
+	s.Tests++
+	switch {
+	case tc.Failure != nil:
+		s.Failures++
+	case tc.Error != nil:
+		s.Errors++
+	case tc.Skipped != nil:
+		s.Skipped++
+	}
+	s.TestCases = append(s.TestCases, tc)
+}
+
+// annotationText formats annotations one per line, as the log shows them.
+func annotationText(annotations []runner.Annotation) string {
+	var b strings.Builder
+	for _, a := range annotations {
+		b.WriteString(a.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// seconds formats a duration in seconds, as JUnit reports expect.
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
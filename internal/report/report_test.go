@@ -0,0 +1,127 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aykay76/ici/internal/runner"
+)
+
+func sampleRun() *runner.RunResult {
+	warning := runner.Annotation{Level: "warning", Message: "deprecated flag", File: "main.go", Line: 3}
+	failure := runner.Annotation{Level: "error", Message: "TestAdd failed"}
+	return &runner.RunResult{
+		Workflow: "CI",
+		Event:    "push",
+		Result:   "failure",
+		Duration: 3 * time.Second,
+		Jobs: []*runner.JobResult{
+			{
+				ID: "test", Name: "test (go=1.25)", Matrix: map[string]interface{}{"go": "1.25"},
+				Result: "failure", Duration: 2 * time.Second,
+				Steps: []*runner.StepResult{
+					{Number: 1, Name: "Build", Outcome: "success", Duration: 1500 * time.Millisecond, Stdout: "ok\n", Annotations: []runner.Annotation{warning}},
+					{Number: 2, Name: "Test", Outcome: "failure", ExitCode: 1, Error: "exit status 1", Stderr: "FAIL\n", Annotations: []runner.Annotation{failure}},
+					{Number: 3, Name: "Upload", Outcome: "skipped", SkipReason: "a previous step failed"},
+				},
+			},
+			{ID: "deploy", Name: "deploy", Result: "skipped", SkipReason: "needed job(s) did not succeed: test (failure)"},
+			{ID: "lint", Name: "lint", Result: "failure", Error: "failed to create container for job lint: no such image"},
+			{
+				ID: "call", Name: "call", Result: "success",
+				Jobs: []*runner.JobResult{
+					{ID: "build", Name: "call / build", Result: "success", Steps: []*runner.StepResult{{Number: 1, Name: "Make", Outcome: "success"}}},
+				},
+			},
+		},
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, []*runner.RunResult{sampleRun()}); err != nil {
+		t.Fatalf("WriteJUnit failed: %v", err)
+	}
+
+	var got junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, buf.String())
+	}
+	if got.Name != "CI" || got.Tests != 6 || got.Failures != 1 || got.Errors != 1 || got.Skipped != 2 || got.Time != "3.000" {
+		t.Fatalf("unexpected totals: %+v", got)
+	}
+
+	var names []string
+	for _, s := range got.Suites {
+		names = append(names, s.Name)
+	}
+	if want := "CI / test (go=1.25)|CI / deploy|CI / lint|CI / call / build"; strings.Join(names, "|") != want {
+		t.Fatalf("unexpected suites: %q", names)
+	}
+
+	test := got.Suites[0]
+	if test.Properties[1] != (junitProperty{Name: "matrix.go", Value: "1.25"}) {
+		t.Fatalf("expected matrix properties, got %+v", test.Properties)
+	}
+	build, failed, skipped := test.TestCases[0], test.TestCases[1], test.TestCases[2]
+	if build.Name != "1. Build" || build.Time != "1.500" || build.SystemOut != "ok\nWarning: main.go:3: deprecated flag\n" {
+		t.Fatalf("unexpected build case: %+v", build)
+	}
+	if failed.Failure == nil || failed.Failure.Type != "exit code 1" || failed.Failure.Message != "exit status 1" ||
+		failed.Failure.Text != "Error: TestAdd failed\n" || failed.SystemErr != "FAIL\n" {
+		t.Fatalf("unexpected failed case: %+v", failed)
+	}
+	if skipped.Skipped == nil || skipped.Skipped.Message != "a previous step failed" {
+		t.Fatalf("unexpected skipped case: %+v", skipped)
+	}
+
+	if c := got.Suites[1].TestCases[0]; c.Skipped == nil || !strings.Contains(c.Skipped.Message, "needed job") {
+		t.Fatalf("expected the skipped job as a skipped case, got %+v", c)
+	}
+	if c := got.Suites[2].TestCases[0]; c.Name != "Set up job" || c.Error == nil || !strings.Contains(c.Error.Message, "no such image") {
+		t.Fatalf("expected the job error as a Set up job case, got %+v", c)
+	}
+	if len(got.Suites[3].TestCases) != 1 {
+		t.Fatalf("expected the called workflow's steps in its own suite, got %+v", got.Suites[3])
+	}
+}
+
+func TestWriteFile_JSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reports", "run.json")
+	if err := WriteFile(FormatJSON, path, []*runner.RunResult{sampleRun()}); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Runs []struct {
+			Workflow string `json:"workflow"`
+			Jobs     []struct {
+				Name  string `json:"name"`
+				Steps []struct {
+					Outcome  string `json:"outcome"`
+					ExitCode int    `json:"exitCode"`
+				} `json:"steps"`
+			} `json:"jobs"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, data)
+	}
+	if len(got.Runs) != 1 || got.Runs[0].Workflow != "CI" || got.Runs[0].Jobs[0].Steps[1].ExitCode != 1 {
+		t.Fatalf("unexpected report: %s", data)
+	}
+
+	if err := WriteFile("html", path, nil); err == nil {
+		t.Fatalf("expected an unsupported format to fail")
+	}
+}
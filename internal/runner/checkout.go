@@ -0,0 +1,183 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aykay76/ici/internal/git"
+)
+
+// isCheckoutAction reports whether a `uses:` reference is actions/checkout.
+func isCheckoutAction(uses string) bool {
+	return strings.HasPrefix(strings.ToLower(uses), "actions/checkout@")
+}
+
+// checkoutInputs holds the actions/checkout inputs ici supports.
+type checkoutInputs struct {
+	ref        string
+	path       string
+	fetchDepth int
+	submodules bool
+	recursive  bool
+	sparse     []string
+	clean      bool
+}
+
+// parseCheckoutInputs reads the `with:` inputs of an actions/checkout step,
+// applying the action's defaults.
+func parseCheckoutInputs(with map[string]string) (*checkoutInputs, error) {
+	in := &checkoutInputs{
+		ref:        strings.TrimSpace(with["ref"]),
+		fetchDepth: 1,
+		clean:      true,
+	}
+
+	if repo := strings.TrimSpace(with["repository"]); repo != "" {
+		return nil, fmt.Errorf("checking out another repository (%s) is not supported; only the local repository can be checked out", repo)
+	}
+
+	if p := strings.TrimSpace(with["path"]); p != "" {
+		cleaned := path.Clean(filepath.ToSlash(p))
+		if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") || strings.Contains(cleaned, "'") {
+			return nil, fmt.Errorf("path %q must be inside the workspace", p)
+		}
+		if cleaned != "." {
+			in.path = cleaned
+		}
+	}
+
+	if v := strings.TrimSpace(with["fetch-depth"]); v != "" {
+		depth, err := strconv.Atoi(v)
+		if err != nil || depth < 0 {
+			return nil, fmt.Errorf("invalid fetch-depth %q", v)
+		}
+		in.fetchDepth = depth
+	}
+
+	switch v := strings.ToLower(strings.TrimSpace(with["submodules"])); v {
+	case "", "false":
+	case "true":
+		in.submodules = true
+	case "recursive":
+		in.submodules = true
+		in.recursive = true
+	default:
+		return nil, fmt.Errorf("invalid submodules %q (use true, false or recursive)", v)
+	}
+
+	for _, line := range strings.Split(with["sparse-checkout"], "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			in.sparse = append(in.sparse, line)
+		}
+	}
+
+	if v := strings.TrimSpace(with["clean"]); v != "" {
+		clean, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid clean %q", v)
+		}
+		in.clean = clean
+	}
+
+	return in, nil
+}
+
+// differences lists the inputs that the local workspace, which is already
+// mounted or copied into the container, does not satisfy, so that a fresh
+// clone is needed. A ref naming the commit the workspace has checked out is
+// satisfied, and so is any fetch-depth unless the workspace is shallow.
+func (in *checkoutInputs) differences(workspace string) ([]string, error) {
+	var diffs []string
+	if in.ref != "" {
+		want, err := git.ResolveCommit(workspace, in.ref)
+		if err != nil {
+			return nil, err
+		}
+		if head, err := git.ResolveCommit(workspace, "HEAD"); err != nil || head != want {
+			diffs = append(diffs, "ref "+in.ref)
+		}
+	}
+	if in.path != "" {
+		diffs = append(diffs, "path "+in.path)
+	}
+	if len(in.sparse) > 0 {
+		diffs = append(diffs, "sparse-checkout")
+	}
+	if in.fetchDepth == 0 && git.IsShallow(workspace) {
+		diffs = append(diffs, "fetch-depth 0 (the workspace is shallow)")
+	}
+	if in.submodules {
+		if paths, err := git.UninitializedSubmodules(workspace, in.recursive); err == nil && len(paths) > 0 {
+			diffs = append(diffs, "submodules (not checked out: "+strings.Join(paths, ", ")+")")
+		}
+	}
+	return diffs, nil
+}
+
+// runCheckout implements actions/checkout from the local repository. When
+// the workspace the container already has satisfies the inputs it is used
+// as is, so uncommitted changes are part of the run and clean does not
+// apply. Otherwise a fresh clone of the requested ref is made from the local
+// repository and copied into the container, which the bind-mounted
+// workspace does not allow.
+func (e *Executor) runCheckout(x *jobExecution, with map[string]string) error {
+	in, err := parseCheckoutInputs(with)
+	if err != nil {
+		return fmt.Errorf("actions/checkout: %w", err)
+	}
+
+	diffs, err := in.differences(e.workspace)
+	if err != nil {
+		return fmt.Errorf("actions/checkout: %w", err)
+	}
+	if len(diffs) == 0 {
+		fmt.Fprintf(x.stdout, "Using local workspace %s (%s)\n", e.workspace, e.workspaceMode)
+		return nil
+	}
+	if e.workspaceMode == WorkspaceBind {
+		return fmt.Errorf("actions/checkout: %s would modify the bind-mounted repository; use --workspace-mode %s", strings.Join(diffs, ", "), WorkspaceCopy)
+	}
+
+	tmp, err := os.MkdirTemp("", "ici-checkout-")
+	if err != nil {
+		return fmt.Errorf("actions/checkout: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "repo")
+	ref := in.ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	fmt.Fprintf(x.stdout, "Checking out %s from %s\n", ref, e.workspace)
+	err = git.CloneLocal(e.workspace, src, &git.CloneOptions{
+		Ref:        in.ref,
+		Depth:      in.fetchDepth,
+		Sparse:     in.sparse,
+		Submodules: in.submodules,
+		Recursive:  in.recursive,
+	})
+	if err != nil {
+		return fmt.Errorf("actions/checkout: %w", err)
+	}
+
+	dst := containerWorkspace
+	if in.path != "" {
+		dst = path.Join(containerWorkspace, in.path)
+	}
+	prepare := fmt.Sprintf("mkdir -p '%s'", dst)
+	if in.clean {
+		prepare += fmt.Sprintf(" && find '%s' -mindepth 1 -delete", dst)
+	}
+	if err := x.mgr.RunCommandWithOptions(x.containerID, prepare, nil); err != nil {
+		return fmt.Errorf("actions/checkout: failed to prepare %s: %w", dst, err)
+	}
+	if err := x.mgr.CopyToContainer(x.containerID, src, dst); err != nil {
+		return fmt.Errorf("actions/checkout: %w", err)
+	}
+	return nil
+}
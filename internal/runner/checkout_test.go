@@ -0,0 +1,179 @@
+package runner
+
+import (
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aykay76/ici/internal/container/containertest"
+	"github.com/aykay76/ici/internal/git"
+)
+
+func TestIsCheckoutAction(t *testing.T) {
+	if !isCheckoutAction("actions/checkout@v4") || !isCheckoutAction("Actions/Checkout@main") {
+		t.Fatalf("expected actions/checkout references to match")
+	}
+	if isCheckoutAction("actions/setup-go@v5") {
+		t.Fatalf("expected actions/setup-go not to match")
+	}
+}
+
+func TestParseCheckoutInputs_Defaults(t *testing.T) {
+	in, err := parseCheckoutInputs(nil)
+	if err != nil {
+		t.Fatalf("parseCheckoutInputs failed: %v", err)
+	}
+	want := &checkoutInputs{fetchDepth: 1, clean: true}
+	if !reflect.DeepEqual(in, want) {
+		t.Fatalf("expected %+v, got %+v", want, in)
+	}
+}
+
+func TestParseCheckoutInputs(t *testing.T) {
+	in, err := parseCheckoutInputs(map[string]string{
+		"ref":             "v1.2.0",
+		"path":            "./src/app/",
+		"fetch-depth":     "0",
+		"submodules":      "recursive",
+		"sparse-checkout": "docs\ninternal/expr\n",
+		"clean":           "false",
+	})
+	if err != nil {
+		t.Fatalf("parseCheckoutInputs failed: %v", err)
+	}
+	want := &checkoutInputs{
+		ref:        "v1.2.0",
+		path:       "src/app",
+		fetchDepth: 0,
+		submodules: true,
+		recursive:  true,
+		sparse:     []string{"docs", "internal/expr"},
+		clean:      false,
+	}
+	if !reflect.DeepEqual(in, want) {
+		t.Fatalf("expected %+v, got %+v", want, in)
+	}
+}
+
+func TestParseCheckoutInputs_Invalid(t *testing.T) {
+	for _, with := range []map[string]string{
+		{"repository": "octo/other"},
+		{"path": "../outside"},
+		{"path": "/abs"},
+		{"fetch-depth": "-1"},
+		{"submodules": "sometimes"},
+		{"clean": "maybe"},
+	} {
+		if _, err := parseCheckoutInputs(with); err == nil {
+			t.Errorf("expected %v to be rejected", with)
+		}
+	}
+}
+
+// gitRepo creates a repository with two commits, the second with a lib
+// submodule, and returns its path and the SHA of the first commit.
+func gitRepo(t *testing.T) (string, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	gitIn := func(dir string, args ...string) string {
+		t.Helper()
+		args = append([]string{"-C", dir, "-c", "user.email=test@example.com", "-c", "user.name=test", "-c", "protocol.file.allow=always"}, args...)
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	lib := t.TempDir()
+	writeFile(t, filepath.Join(lib, "lib.go"), "package lib\n")
+	gitIn(lib, "init", "-q", "-b", "main")
+	gitIn(lib, "add", ".")
+	gitIn(lib, "commit", "-q", "-m", "lib")
+
+	repo := t.TempDir()
+	writeFile(t, filepath.Join(repo, "README.md"), "hello\n")
+	gitIn(repo, "init", "-q", "-b", "main")
+	gitIn(repo, "add", ".")
+	gitIn(repo, "commit", "-q", "-m", "initial")
+	first := gitIn(repo, "rev-parse", "HEAD")
+	gitIn(repo, "submodule", "add", "-q", lib, "lib")
+	gitIn(repo, "commit", "-q", "-m", "add lib")
+	return repo, first
+}
+
+func TestCheckoutInputs_Differences(t *testing.T) {
+	repo, first := gitRepo(t)
+	// a clone without its submodule, with a shallow history
+	shallow := filepath.Join(t.TempDir(), "shallow")
+	if err := git.CloneLocal(repo, shallow, &git.CloneOptions{Depth: 1}); err != nil {
+		t.Fatal(err)
+	}
+	head, _ := git.ResolveCommit(repo, "HEAD")
+
+	tests := []struct {
+		workspace string
+		with      map[string]string
+		want      string
+	}{
+		{repo, nil, ""},
+		{repo, map[string]string{"ref": "main", "fetch-depth": "0", "submodules": "recursive"}, ""},
+		{repo, map[string]string{"ref": head[:8]}, ""},
+		{repo, map[string]string{"ref": first[:8]}, "ref " + first[:8]},
+		{repo, map[string]string{"path": "src", "sparse-checkout": "docs"}, "path src|sparse-checkout"},
+		{shallow, nil, ""},
+		{shallow, map[string]string{"fetch-depth": "0"}, "fetch-depth 0 (the workspace is shallow)"},
+		{shallow, map[string]string{"submodules": "true"}, "submodules (not checked out: lib)"},
+	}
+	for _, tt := range tests {
+		in, err := parseCheckoutInputs(tt.with)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diffs, err := in.differences(tt.workspace)
+		if err != nil || strings.Join(diffs, "|") != tt.want {
+			t.Errorf("differences(%v) = %q, %v, want %q", tt.with, diffs, err, tt.want)
+		}
+	}
+
+	in, _ := parseCheckoutInputs(map[string]string{"ref": "no-such-branch"})
+	if _, err := in.differences(repo); err == nil {
+		t.Errorf("expected an unknown ref to fail")
+	}
+}
+
+func TestRunCheckout(t *testing.T) {
+	repo, first := gitRepo(t)
+	rt := containertest.New()
+	id, err := rt.CreateContainerWithConfig("ubuntu:22.04", "build", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	x := &jobExecution{mgr: rt, containerID: id, stdout: &out}
+
+	// The ref the workspace has checked out works with the bind mount.
+	bind := NewExecutorWithOptions(Options{Workspace: repo})
+	if err := bind.runCheckout(x, map[string]string{"ref": "main"}); err != nil {
+		t.Fatalf("runCheckout failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Using local workspace") {
+		t.Fatalf("expected the workspace to be used, got %q", out.String())
+	}
+	err = bind.runCheckout(x, map[string]string{"ref": first})
+	if err == nil || !strings.Contains(err.Error(), "ref "+first+" would modify the bind-mounted repository") {
+		t.Fatalf("expected the bind mount to be protected, got %v", err)
+	}
+
+	// Other refs are cloned and copied in.
+	copied := NewExecutorWithOptions(Options{Workspace: repo, WorkspaceMode: WorkspaceCopy})
+	if err := copied.runCheckout(x, map[string]string{"ref": first[:7]}); err != nil {
+		t.Fatalf("runCheckout failed: %v", err)
+	}
+	if log := strings.Join(rt.Log(), "|"); !strings.Contains(log, "build:"+containerWorkspace) {
+		t.Fatalf("expected the clone to be copied into the container, got %s", log)
+	}
+}
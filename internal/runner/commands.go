@@ -0,0 +1,208 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Annotation is an error, warning or notice reported by a step with a
+// workflow command, e.g. ::error file=app.go,line=1::message.
+type Annotation struct {
+	// Job is the display name of the job that reported it.
+	Job       string `json:"job,omitempty"`
+	Level     string `json:"level"`
+	Message   string `json:"message"`
+	Title     string `json:"title,omitempty"`
+	File      string `json:"file,omitempty"`
+	Line      int    `json:"line,omitempty"`
+	EndLine   int    `json:"endLine,omitempty"`
+	Col       int    `json:"col,omitempty"`
+	EndColumn int    `json:"endColumn,omitempty"`
+}
+
+// String formats the annotation the way the GitHub log shows it.
+func (a Annotation) String() string {
+	var b strings.Builder
+	b.WriteString(strings.ToUpper(a.Level[:1]) + a.Level[1:] + ": ")
+	if a.File != "" {
+		b.WriteString(a.File)
+		if a.Line > 0 {
+			fmt.Fprintf(&b, ":%d", a.Line)
+			if a.Col > 0 {
+				fmt.Fprintf(&b, ":%d", a.Col)
+			}
+		}
+		b.WriteString(": ")
+	}
+	if a.Title != "" {
+		b.WriteString(a.Title + ": ")
+	}
+	b.WriteString(a.Message)
+	return b.String()
+}
+
+// commandWriter processes the workflow commands steps write to stdout, such
+// as ::group::, ::error:: and ::add-mask::, and forwards the remaining
+// output to out. Annotations are collected for the run summary.
+type commandWriter struct {
+	out     io.Writer
+	verbose bool
+	masks   *masker
+	buf     []byte
+
+	inGroup bool
+	// stopToken is set while workflow commands are disabled with
+	// ::stop-commands::.
+	stopToken   string
+	annotations []Annotation
+}
+
+// newCommandWriter creates a commandWriter that writes to out.
+func newCommandWriter(out io.Writer, masks *masker, verbose bool) *commandWriter {
+	return &commandWriter{out: out, masks: masks, verbose: verbose}
+}
+
+// Write buffers p and processes every complete line.
+func (w *commandWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimSuffix(string(w.buf[:i]), "\r")
+		w.buf = w.buf[i+1:]
+		if err := w.processLine(line); err != nil {
+			return len(p), err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush processes any buffered partial line and closes an open group. It is
+// called at the end of every step.
+func (w *commandWriter) Flush() error {
+	if len(w.buf) > 0 {
+		line := string(w.buf)
+		w.buf = nil
+		if err := w.processLine(line); err != nil {
+			return err
+		}
+	}
+	w.inGroup = false
+	return nil
+}
+
+func (w *commandWriter) processLine(line string) error {
+	name, props, message, ok := parseWorkflowCommand(line)
+	if !ok {
+		return w.writeLine(line)
+	}
+
+	if w.stopToken != "" {
+		if name == w.stopToken {
+			w.stopToken = ""
+			return nil
+		}
+		return w.writeLine(line)
+	}
+
+	switch name {
+	case "group":
+		w.inGroup = false
+		err := w.writeLine("▼ " + message)
+		w.inGroup = true
+		return err
+	case "endgroup":
+		w.inGroup = false
+		return nil
+	case "add-mask":
+		w.masks.add(message)
+		return nil
+	case "stop-commands":
+		if message == "" {
+			return w.writeLine(line)
+		}
+		w.stopToken = message
+		return nil
+	case "debug":
+		if w.verbose {
+			return w.writeLine("Debug: " + message)
+		}
+		return nil
+	case "error", "warning", "notice":
+		a := Annotation{
+			Level:     name,
+			Message:   message,
+			Title:     props["title"],
+			File:      props["file"],
+			Line:      atoi(props["line"]),
+			EndLine:   atoi(props["endLine"]),
+			Col:       atoi(props["col"]),
+			EndColumn: atoi(props["endColumn"]),
+		}
+		a.Message = w.masks.mask(a.Message)
+		a.Title = w.masks.mask(a.Title)
+		w.annotations = append(w.annotations, a)
+		return w.writeLine(a.String())
+	default:
+		// unknown or unsupported commands are shown as regular output
+		return w.writeLine(line)
+	}
+}
+
+func (w *commandWriter) writeLine(line string) error {
+	line = w.masks.mask(line)
+	if w.inGroup {
+		line = "  " + line
+	}
+	_, err := io.WriteString(w.out, line+"\n")
+	return err
+}
+
+// parseWorkflowCommand parses a line of the form
+// ::name key=value,key=value::message.
+func parseWorkflowCommand(line string) (name string, props map[string]string, message string, ok bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "::") {
+		return "", nil, "", false
+	}
+	rest := trimmed[2:]
+	end := strings.Index(rest, "::")
+	if end < 0 {
+		return "", nil, "", false
+	}
+	command, message := rest[:end], rest[end+2:]
+	name, params, _ := strings.Cut(command, " ")
+	if name == "" {
+		return "", nil, "", false
+	}
+
+	props = make(map[string]string)
+	for _, param := range strings.Split(params, ",") {
+		k, v, found := strings.Cut(strings.TrimSpace(param), "=")
+		if found && k != "" {
+			props[k] = unescapeProperty(v)
+		}
+	}
+	return name, props, unescapeData(message), true
+}
+
+// unescapeData reverses the escaping GitHub applies to command messages.
+func unescapeData(s string) string {
+	return strings.NewReplacer("%0D", "\r", "%0A", "\n", "%25", "%").Replace(s)
+}
+
+// unescapeProperty reverses the escaping GitHub applies to command
+// properties.
+func unescapeProperty(s string) string {
+	return strings.NewReplacer("%0D", "\r", "%0A", "\n", "%3A", ":", "%2C", ",", "%25", "%").Replace(s)
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
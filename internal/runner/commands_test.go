@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCommandWriter_ProcessesWorkflowCommands(t *testing.T) {
+	var out bytes.Buffer
+	w := newCommandWriter(&out, &masker{}, false)
+
+	input := "::group::Build\n" +
+		"compiling\n" +
+		"::endgroup::\n" +
+		"::add-mask::s3cret\n" +
+		"token is s3cret\n" +
+		"::error file=app.go,line=10,col=2,title=Compile::undefined: foo%0Asecond line\n" +
+		"::warning::deprecated\n" +
+		"::debug::hidden\n" +
+		"::stop-commands::pause\n" +
+		"::error::not a command\n" +
+		"::pause::\n" +
+		"::notice title=Done::all good"
+	if _, err := w.Write([]byte(input)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "▼ Build\n" +
+		"  compiling\n" +
+		"token is ***\n" +
+		"Error: app.go:10:2: Compile: undefined: foo\nsecond line\n" +
+		"Warning: deprecated\n" +
+		"::error::not a command\n" +
+		"Notice: Done: all good\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	if len(w.annotations) != 3 {
+		t.Fatalf("expected 3 annotations, got %d: %+v", len(w.annotations), w.annotations)
+	}
+	a := w.annotations[0]
+	if a.Level != "error" || a.File != "app.go" || a.Line != 10 || a.Col != 2 || a.Title != "Compile" {
+		t.Fatalf("unexpected annotation: %+v", a)
+	}
+	if w.annotations[1].Level != "warning" || w.annotations[2].Level != "notice" {
+		t.Fatalf("unexpected annotations: %+v", w.annotations)
+	}
+}
+
+func TestCommandWriter_DebugInVerboseMode(t *testing.T) {
+	var out bytes.Buffer
+	w := newCommandWriter(&out, &masker{}, true)
+	_, _ = w.Write([]byte("::debug::details\n"))
+	if out.String() != "Debug: details\n" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
+
+func TestParseWorkflowCommand(t *testing.T) {
+	name, props, msg, ok := parseWorkflowCommand("::error file=a%2Cb.go,line=3::boom")
+	if !ok || name != "error" || props["file"] != "a,b.go" || props["line"] != "3" || msg != "boom" {
+		t.Fatalf("unexpected result: %v %v %v %v", name, props, msg, ok)
+	}
+	for _, line := range []string{"plain output", "::no end", ":: ::x"} {
+		if _, _, _, ok := parseWorkflowCommand(line); ok {
+			t.Fatalf("expected %q not to be a command", line)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aykay76/ici/internal/expr"
+)
+
+// statusFunctions are the job status functions. A condition that calls
+// none of them is implicitly combined with success().
+var statusFunctions = []string{"success", "failure", "always", "cancelled"}
+
+// conditionStatus holds the values returned by the status functions: for a
+// job they reflect the jobs it needs, for a step the previous steps.
+type conditionStatus struct {
+	success   bool
+	failure   bool
+	cancelled bool
+}
+
+// evaluateCondition evaluates an `if:` condition. An empty condition behaves
+// like success(), and a condition that does not call a status function is
+// evaluated as `success() && (<condition>)`, matching GitHub Actions. Text
+// around ${{ }} expressions makes the condition a string, which holds when
+// it is not empty.
+func evaluateCondition(condition string, ctx *expr.Context, status conditionStatus) (bool, error) {
+	c, err := expr.TemplateExpression(strings.TrimSpace(condition))
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate if condition: %w", err)
+	}
+	if c == "" {
+		c = "success()"
+	} else {
+		calls, err := expr.CallsFunction(c, statusFunctions...)
+		if err != nil {
+			return false, fmt.Errorf("failed to evaluate if condition: %w", err)
+		}
+		if !calls {
+			c = "success() && (" + c + ")"
+		}
+	}
+
+	funcs := make(map[string]expr.Func, len(ctx.Funcs)+4)
+	for name, fn := range ctx.Funcs {
+		funcs[name] = fn
+	}
+	funcs["success"] = statusFunc(status.success && !status.cancelled)
+	funcs["failure"] = statusFunc(status.failure && !status.cancelled)
+	funcs["cancelled"] = statusFunc(status.cancelled)
+	funcs["always"] = statusFunc(true)
+
+	v, err := expr.Evaluate(c, &expr.Context{Values: ctx.Values, Funcs: funcs, WorkDir: ctx.WorkDir})
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate if condition: %w", err)
+	}
+	return expr.IsTruthy(v), nil
+}
+
+func statusFunc(result bool) expr.Func {
+	return func(args []interface{}) (interface{}, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("status functions take no arguments")
+		}
+		return result, nil
+	}
+}
+
+// needsStatus returns the condition status of a job from the results of the
+// jobs it needs. success() requires every needed job to have succeeded,
+// while failure() is true when any ancestor job failed, even one further up
+// the dependency chain.
+func needsStatus(g *jobGraph, jobID string, results map[string]jobResult) conditionStatus {
+	status := conditionStatus{
+		success: firstUnsuccessful(g.needs[jobID], results) == "",
+	}
+	seen := make(map[string]bool)
+	var visit func(id string)
+	visit = func(id string) {
+		for _, need := range g.needs[id] {
+			if seen[need] {
+				continue
+			}
+			seen[need] = true
+			if results[need] == resultFailure {
+				status.failure = true
+			}
+			visit(need)
+		}
+	}
+	visit(jobID)
+	return status
+}
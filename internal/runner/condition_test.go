@@ -0,0 +1,90 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/aykay76/ici/internal/expr"
+	"github.com/aykay76/ici/internal/parser"
+)
+
+func TestEvaluateCondition(t *testing.T) {
+	ctx := &expr.Context{
+		Values: map[string]interface{}{
+			"github": map[string]interface{}{"ref": "refs/heads/main"},
+		},
+	}
+	ok := conditionStatus{success: true}
+	failed := conditionStatus{failure: true}
+	cancelled := conditionStatus{success: true, cancelled: true}
+
+	tests := []struct {
+		condition string
+		status    conditionStatus
+		want      bool
+	}{
+		{"", ok, true},
+		{"", failed, false},
+		{"always()", failed, true},
+		{"failure()", failed, true},
+		{"failure()", ok, false},
+		{"success()", ok, true},
+		{"cancelled()", ok, false},
+		{"github.ref == 'refs/heads/main'", ok, true},
+		{"${{ github.ref == 'refs/heads/main' }}", ok, true},
+		{"github.ref == 'refs/heads/main'", failed, false},
+		{"github.ref != 'refs/heads/main'", ok, false},
+		{"failure() || github.ref == 'refs/heads/main'", ok, true},
+		// status function names inside strings are not calls
+		{"github.ref != 'failure()'", ok, true},
+		{"github.ref != 'failure()'", failed, false},
+		{"contains(github.ref, 'always()')", failed, false},
+		{"FAILURE()", failed, true},
+		{"!cancelled() && github.ref == 'refs/heads/main'", failed, true},
+		// text around expressions makes the condition a non-empty string
+		{"${{ github.ref }} == ${{ 'refs/heads/dev' }}", ok, true},
+		{"${{ github.ref }} == ${{ 'refs/heads/dev' }}", failed, false},
+		{"${{ always() }} == ${{ github.ref }}", failed, true},
+		{" ${{ github.ref != 'refs/heads/main' }} ", ok, false},
+		{"always()", cancelled, true},
+		{"cancelled()", cancelled, true},
+		{"", cancelled, false},
+		{"failure()", cancelled, false},
+	}
+
+	for _, tt := range tests {
+		got, err := evaluateCondition(tt.condition, ctx, tt.status)
+		if err != nil {
+			t.Fatalf("evaluateCondition(%q) failed: %v", tt.condition, err)
+		}
+		if got != tt.want {
+			t.Errorf("evaluateCondition(%q, %+v) = %v, want %v", tt.condition, tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestNeedsStatus(t *testing.T) {
+	jobs := map[string]parser.Job{
+		"build":  {},
+		"test":   {Needs: "build"},
+		"report": {Needs: "test"},
+	}
+	g, err := buildJobGraph(jobs)
+	if err != nil {
+		t.Fatalf("buildJobGraph failed: %v", err)
+	}
+
+	results := map[string]jobResult{"build": resultFailure, "test": resultSkipped}
+	status := needsStatus(g, "report", results)
+	if status.success {
+		t.Fatalf("expected success() to be false when a needed job was skipped")
+	}
+	if !status.failure {
+		t.Fatalf("expected failure() to be true when an ancestor failed")
+	}
+
+	results = map[string]jobResult{"build": resultSuccess, "test": resultSuccess}
+	status = needsStatus(g, "report", results)
+	if !status.success || status.failure {
+		t.Fatalf("expected success status, got %+v", status)
+	}
+}
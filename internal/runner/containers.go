@@ -0,0 +1,76 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aykay76/ici/internal/container"
+	"github.com/aykay76/ici/internal/expr"
+	"github.com/aykay76/ici/internal/parser"
+)
+
+// containerSpec is a `container:` with its expressions evaluated.
+type containerSpec struct {
+	image    string
+	env      map[string]string
+	ports    []string
+	volumes  []string
+	options  string
+	username string
+	password string
+}
+
+// evaluateContainer evaluates the expressions of a `container:`, which
+// typically refer to the matrix or to secrets.
+func evaluateContainer(c *parser.Container, ctx *expr.Context) (*containerSpec, error) {
+	spec := &containerSpec{}
+	var err error
+	eval := func(s string) string {
+		if err != nil {
+			return ""
+		}
+		var v string
+		v, err = expr.Interpolate(s, ctx)
+		return v
+	}
+	evalList := func(list []string) []string {
+		out := make([]string, 0, len(list))
+		for _, s := range list {
+			out = append(out, eval(s))
+		}
+		return out
+	}
+
+	spec.image = eval(c.Image)
+	spec.ports = evalList(c.Ports)
+	spec.volumes = evalList(c.Volumes)
+	spec.options = eval(c.Options)
+	if c.Credentials != nil {
+		spec.username = eval(c.Credentials.Username)
+		spec.password = eval(c.Credentials.Password)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if spec.env, err = expr.InterpolateMap(c.Env, ctx); err != nil {
+		return nil, fmt.Errorf("failed to evaluate env: %w", err)
+	}
+	if spec.image == "" {
+		return nil, errors.New("container image is empty")
+	}
+	return spec, nil
+}
+
+// prepare logs in to the image's registry when credentials are given and
+// adds the ports, volumes and options of the container to cfg.
+func (s *containerSpec) prepare(mgr container.Runtime, cfg *container.ContainerConfig) error {
+	if s.username != "" || s.password != "" {
+		if err := mgr.Login(s.image, s.username, s.password); err != nil {
+			return err
+		}
+	}
+	cfg.Ports = append(cfg.Ports, s.ports...)
+	cfg.Volumes = append(cfg.Volumes, s.volumes...)
+	cfg.Options = s.options
+	return nil
+}
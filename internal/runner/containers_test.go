@@ -0,0 +1,48 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/aykay76/ici/internal/container"
+	"github.com/aykay76/ici/internal/container/containertest"
+	"github.com/aykay76/ici/internal/expr"
+	"github.com/aykay76/ici/internal/parser"
+)
+
+func TestEvaluateContainer(t *testing.T) {
+	ctx := &expr.Context{Values: map[string]interface{}{
+		"matrix":  map[string]interface{}{"go": "1.25"},
+		"secrets": map[string]interface{}{"REGISTRY_TOKEN": "t0ken"},
+	}}
+	c := &parser.Container{
+		Image:       "ghcr.io/octo/build:${{ matrix.go }}",
+		Env:         map[string]string{"GOFLAGS": "-mod=${{ 'vendor' }}"},
+		Ports:       []string{"8080:80"},
+		Volumes:     []string{"/tmp/cache:/cache"},
+		Options:     "--cpus 1",
+		Credentials: &parser.Credentials{Username: "octo", Password: "${{ secrets.REGISTRY_TOKEN }}"},
+	}
+	spec, err := evaluateContainer(c, ctx)
+	if err != nil {
+		t.Fatalf("evaluateContainer failed: %v", err)
+	}
+	if spec.image != "ghcr.io/octo/build:1.25" || spec.env["GOFLAGS"] != "-mod=vendor" || spec.password != "t0ken" {
+		t.Fatalf("unexpected spec: %+v", spec)
+	}
+
+	rt := containertest.New()
+	cfg := &container.ContainerConfig{}
+	if err := spec.prepare(rt, cfg); err != nil {
+		t.Fatalf("prepare failed: %v", err)
+	}
+	if len(cfg.Ports) != 1 || cfg.Volumes[0] != "/tmp/cache:/cache" || cfg.Options != "--cpus 1" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	if log := rt.Log(); len(log) != 1 || log[0] != "login ghcr.io octo" {
+		t.Fatalf("expected a registry login, got %v", log)
+	}
+
+	if _, err := evaluateContainer(&parser.Container{Image: "${{ matrix.missing }}"}, ctx); err == nil {
+		t.Fatalf("expected an empty image to fail")
+	}
+}
@@ -0,0 +1,226 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aykay76/ici/internal/expr"
+	"github.com/aykay76/ici/internal/git"
+	"github.com/aykay76/ici/internal/parser"
+)
+
+// workflowRun holds the state shared by every job of a single workflow run.
+type workflowRun struct {
+	workflow  *parser.Workflow
+	eventName string
+	// event is the event payload, exposed as github.event.
+	event map[string]interface{}
+	// workspace is the host directory mounted or copied into job containers.
+	workspace string
+	// repo describes the repository in the workspace.
+	repo *git.RepoInfo
+	// actor is reported as the user that triggered the run.
+	actor string
+	// secrets holds the secrets context.
+	secrets map[string]string
+	// vars holds the vars context.
+	vars map[string]string
+	// inputs holds the inputs context.
+	inputs map[string]interface{}
+	// pool limits how many job instances run at the same time.
+	pool workerPool
+	// source is the directory that local reusable workflows are resolved
+	// against: the workspace, or the checkout of a remote called workflow.
+	source string
+	// caller is the job instance that called this workflow, and depth the
+	// number of calls that led to it, for a reusable workflow.
+	caller *jobRun
+	depth  int
+	// ctx is cancelled when the job that called this workflow is cancelled.
+	// Nil means the run is never cancelled.
+	ctx context.Context
+
+	// mu guards the fields below, which jobs update as they finish.
+	mu sync.Mutex
+	// outputs holds the outputs of finished jobs by job id.
+	outputs map[string]map[string]string
+	// annotations holds the annotations reported by steps.
+	annotations []Annotation
+	// jobResults holds the results of finished job instances by job id.
+	jobResults map[string][]*JobResult
+}
+
+// addAnnotations records the annotations reported by a job and returns them
+// with the job's name set.
+func (r *workflowRun) addAnnotations(job string, annotations []Annotation) []Annotation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var added []Annotation
+	for _, a := range annotations {
+		a.Job = job
+		added = append(added, a)
+	}
+	r.annotations = append(r.annotations, added...)
+	return added
+}
+
+// secretMasks returns a masker for the values of the secrets context.
+func (r *workflowRun) secretMasks() *masker {
+	masks := &masker{}
+	for _, v := range r.secrets {
+		masks.add(v)
+	}
+	return masks
+}
+
+// setOutputs records outputs of a job. The outputs of matrix instances are
+// merged, a later instance's non-empty value replacing an earlier one.
+func (r *workflowRun) setOutputs(jobID string, outputs map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.outputs == nil {
+		r.outputs = make(map[string]map[string]string)
+	}
+	if r.outputs[jobID] == nil {
+		r.outputs[jobID] = make(map[string]string)
+	}
+	for k, v := range outputs {
+		if _, ok := r.outputs[jobID][k]; !ok || v != "" {
+			r.outputs[jobID][k] = v
+		}
+	}
+}
+
+// jobOutputs returns the outputs of a finished job as a context value.
+func (r *workflowRun) jobOutputs(jobID string) map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return stringMap(r.outputs[jobID])
+}
+
+// newJobContext builds the expression context available while running a
+// job. env is the environment visible to expressions through the env
+// context.
+func (r *workflowRun) newJobContext(jr *jobRun, env map[string]string) *expr.Context {
+	needsCtx := make(map[string]interface{}, len(jr.needs))
+	for id, result := range jr.needs {
+		needsCtx[id] = map[string]interface{}{
+			"result":  string(result),
+			"outputs": r.jobOutputs(id),
+		}
+	}
+
+	matrix := map[string]interface{}{}
+	strategy := map[string]interface{}{}
+	if jr.matrix != nil {
+		matrix = jr.matrix.values
+		maxParallel := jr.total
+		if jr.job.Strategy.MaxParallel > 0 {
+			maxParallel = jr.job.Strategy.MaxParallel
+		}
+		strategy = map[string]interface{}{
+			"fail-fast":    jr.job.Strategy.GetFailFast(),
+			"max-parallel": maxParallel,
+			"job-index":    jr.index,
+			"job-total":    jr.total,
+		}
+	}
+
+	refName, refType := splitRef(r.repo.Ref)
+	owner, _, _ := strings.Cut(r.repo.Repository, "/")
+	headRef, baseRef := pullRequestRefs(r.event)
+	event := r.event
+	if event == nil {
+		event = map[string]interface{}{}
+	}
+	inputs := r.inputs
+	if inputs == nil {
+		inputs = map[string]interface{}{}
+	}
+
+	return &expr.Context{
+		Values: map[string]interface{}{
+			"github": map[string]interface{}{
+				"event_name":       r.eventName,
+				"workflow":         r.workflow.Name,
+				"job":              jr.id,
+				"run_id":           "1",
+				"run_number":       "1",
+				"run_attempt":      "1",
+				"event":            event,
+				"event_path":       containerEventPath,
+				"head_ref":         headRef,
+				"base_ref":         baseRef,
+				"workspace":        containerWorkspace,
+				"sha":              r.repo.SHA,
+				"ref":              r.repo.Ref,
+				"ref_name":         refName,
+				"ref_type":         refType,
+				"repository":       r.repo.Repository,
+				"repository_owner": owner,
+				"actor":            r.actor,
+				"server_url":       "https://github.com",
+				"api_url":          "https://api.github.com",
+				"graphql_url":      "https://api.github.com/graphql",
+			},
+			"runner": map[string]interface{}{
+				"name": "ici",
+				"os":   "Linux",
+				"arch": "X64",
+				"temp": runnerTemp,
+			},
+			"env":      copyEnv(env),
+			"job":      map[string]interface{}{"status": "success"},
+			"steps":    map[string]interface{}{},
+			"matrix":   matrix,
+			"strategy": strategy,
+			"needs":    needsCtx,
+			"secrets":  stringMap(r.secrets),
+			"vars":     stringMap(r.vars),
+			"inputs":   inputs,
+		},
+		// hashFiles reads the workspace from the host
+		WorkDir: r.workspace,
+	}
+}
+
+// copyEnv returns a copy of an environment map, never returning nil.
+func copyEnv(env map[string]string) map[string]string {
+	out := make(map[string]string, len(env))
+	for k, v := range env {
+		out[k] = v
+	}
+	return out
+}
+
+// cloneContext returns a copy of ctx whose top-level contexts can be replaced
+// without affecting the original.
+func cloneContext(ctx *expr.Context) *expr.Context {
+	values := make(map[string]interface{}, len(ctx.Values))
+	for k, v := range ctx.Values {
+		values[k] = v
+	}
+	return &expr.Context{Values: values, Funcs: ctx.Funcs, WorkDir: ctx.WorkDir}
+}
+
+// recordOutputs evaluates the `outputs:` of a job once its steps have run
+// and records them for the jobs that need it.
+func (r *workflowRun) recordOutputs(jr *jobRun, ctx *expr.Context) error {
+	outputs, err := expr.InterpolateMap(jr.job.Outputs, ctx)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate outputs: %w", err)
+	}
+	r.setOutputs(jr.id, outputs)
+	return nil
+}
+
+// stringMap converts a map of strings into a context value.
+func stringMap(m map[string]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
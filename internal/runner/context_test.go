@@ -0,0 +1,68 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/aykay76/ici/internal/expr"
+	"github.com/aykay76/ici/internal/git"
+	"github.com/aykay76/ici/internal/parser"
+)
+
+func TestJobOutputs_AvailableThroughNeeds(t *testing.T) {
+	wf := &parser.Workflow{Jobs: map[string]parser.Job{
+		"version": {Outputs: map[string]string{
+			"version": "${{ steps.compute.outputs.version }}",
+			"channel": "stable",
+		}},
+		"publish": {Needs: "version"},
+	}}
+	run := &workflowRun{workflow: wf, repo: &git.RepoInfo{}}
+
+	producer := &jobRun{id: "version", job: wf.Jobs["version"]}
+	ctx := run.newJobContext(producer, nil)
+	ctx.Values["steps"] = map[string]interface{}{
+		"compute": map[string]interface{}{"outputs": map[string]interface{}{"version": "1.2.3"}},
+	}
+	if err := run.recordOutputs(producer, ctx); err != nil {
+		t.Fatalf("recordOutputs failed: %v", err)
+	}
+
+	consumer := &jobRun{id: "publish", job: wf.Jobs["publish"], needs: map[string]jobResult{"version": resultSuccess}}
+	got, err := expr.Interpolate("${{ needs.version.outputs.version }}/${{ needs.version.outputs.channel }}/${{ needs.version.result }}", run.newJobContext(consumer, nil))
+	if err != nil {
+		t.Fatalf("Interpolate failed: %v", err)
+	}
+	if got != "1.2.3/stable/success" {
+		t.Fatalf("unexpected result: %q", got)
+	}
+}
+
+func TestSetOutputs_MergesMatrixInstances(t *testing.T) {
+	run := &workflowRun{}
+	run.setOutputs("build", map[string]string{"linux": "ok", "windows": ""})
+	run.setOutputs("build", map[string]string{"linux": "", "windows": "ok"})
+
+	got := run.jobOutputs("build")
+	if got["linux"] != "ok" || got["windows"] != "ok" {
+		t.Fatalf("unexpected outputs: %v", got)
+	}
+	if len(run.jobOutputs("missing")) != 0 {
+		t.Fatalf("expected no outputs for an unknown job")
+	}
+}
+
+func TestSecretsAndVarsContexts(t *testing.T) {
+	run := &workflowRun{
+		workflow: &parser.Workflow{},
+		repo:     &git.RepoInfo{},
+		secrets:  map[string]string{"TOKEN": "abc"},
+		vars:     map[string]string{"DEPLOY_REGION": "eu-west-1"},
+	}
+	got, err := expr.Interpolate("${{ secrets.TOKEN }}|${{ secrets.MISSING }}|${{ vars.DEPLOY_REGION }}", run.newJobContext(&jobRun{id: "build"}, nil))
+	if err != nil {
+		t.Fatalf("Interpolate failed: %v", err)
+	}
+	if got != "abc||eu-west-1" {
+		t.Fatalf("unexpected result: %q", got)
+	}
+}
@@ -0,0 +1,123 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aykay76/ici/internal/expr"
+	"github.com/aykay76/ici/internal/git"
+	"github.com/aykay76/ici/internal/parser"
+)
+
+// inspectWorkspace returns what is known about the repository in the
+// workspace. Outside a git repository, or without an origin remote, the
+// repository is named after the workspace directory.
+func inspectWorkspace(workspace string) *git.RepoInfo {
+	info, err := git.Inspect(workspace)
+	if err != nil {
+		info = &git.RepoInfo{}
+	}
+	if info.Repository == "" {
+		info.Repository = "local/" + filepath.Base(workspace)
+	}
+	return info
+}
+
+// localActor returns the name reported as the user that triggered the run.
+func localActor() string {
+	if user := os.Getenv("USER"); user != "" {
+		return user
+	}
+	return "ici"
+}
+
+// splitRef returns the short name and type (branch or tag) of a fully
+// qualified ref.
+func splitRef(ref string) (name, refType string) {
+	switch {
+	case strings.HasPrefix(ref, "refs/heads/"):
+		return strings.TrimPrefix(ref, "refs/heads/"), "branch"
+	case strings.HasPrefix(ref, "refs/tags/"):
+		return strings.TrimPrefix(ref, "refs/tags/"), "tag"
+	default:
+		return ref, ""
+	}
+}
+
+// defaultEnv returns the variables GitHub sets in every job, describing the
+// run, the repository and the runner.
+func (r *workflowRun) defaultEnv(jr *jobRun) map[string]string {
+	refName, refType := splitRef(r.repo.Ref)
+	owner, _, _ := strings.Cut(r.repo.Repository, "/")
+	headRef, baseRef := pullRequestRefs(r.event)
+	return map[string]string{
+		"CI":                      "true",
+		"GITHUB_ACTIONS":          "true",
+		"GITHUB_ACTOR":            r.actor,
+		"GITHUB_API_URL":          "https://api.github.com",
+		"GITHUB_BASE_REF":         baseRef,
+		"GITHUB_EVENT_NAME":       r.eventName,
+		"GITHUB_EVENT_PATH":       containerEventPath,
+		"GITHUB_GRAPHQL_URL":      "https://api.github.com/graphql",
+		"GITHUB_HEAD_REF":         headRef,
+		"GITHUB_JOB":              jr.id,
+		"GITHUB_REF":              r.repo.Ref,
+		"GITHUB_REF_NAME":         refName,
+		"GITHUB_REF_TYPE":         refType,
+		"GITHUB_REPOSITORY":       r.repo.Repository,
+		"GITHUB_REPOSITORY_OWNER": owner,
+		"GITHUB_RUN_ATTEMPT":      "1",
+		"GITHUB_RUN_ID":           "1",
+		"GITHUB_RUN_NUMBER":       "1",
+		"GITHUB_SERVER_URL":       "https://github.com",
+		"GITHUB_SHA":              r.repo.SHA,
+		"GITHUB_WORKFLOW":         r.workflow.Name,
+		"RUNNER_ARCH":             "X64",
+		"RUNNER_NAME":             "ici",
+		"RUNNER_OS":               "Linux",
+		"RUNNER_TEMP":             runnerTemp,
+		"RUNNER_TOOL_CACHE":       "/opt/hostedtoolcache",
+	}
+}
+
+// runnerTemp is the temporary directory reported as RUNNER_TEMP and
+// runner.temp.
+const runnerTemp = "/tmp"
+
+// evaluateJobEnv evaluates the workflow env and then the job env, whose
+// expressions see the workflow env. Job values override workflow values.
+func evaluateJobEnv(workflow *parser.Workflow, job parser.Job, ctx *expr.Context) (map[string]string, error) {
+	workflowEnv, err := expr.InterpolateMap(workflow.Env, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("workflow env: %w", err)
+	}
+	jobCtx := cloneContext(ctx)
+	jobCtx.Values["env"] = copyEnv(workflowEnv)
+	jobEnv, err := expr.InterpolateMap(job.Env, jobCtx)
+	if err != nil {
+		return nil, err
+	}
+	return mergeEnv(workflowEnv, jobEnv), nil
+}
+
+// mergeEnv returns the union of envs, later maps overriding earlier ones.
+func mergeEnv(envs ...map[string]string) map[string]string {
+	out := make(map[string]string)
+	for _, env := range envs {
+		for k, v := range env {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// envList returns env as KEY=VALUE pairs sorted by key.
+func envList(env map[string]string) []string {
+	out := make([]string, 0, len(env))
+	for _, k := range sortedKeys(env) {
+		out = append(out, k+"="+env[k])
+	}
+	return out
+}
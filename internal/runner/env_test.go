@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aykay76/ici/internal/git"
+	"github.com/aykay76/ici/internal/parser"
+)
+
+func TestEvaluateJobEnv_Precedence(t *testing.T) {
+	wf := &parser.Workflow{
+		Name: "ci",
+		Env:  map[string]string{"STAGE": "dev", "APP": "web", "WF": "${{ github.workflow }}"},
+	}
+	job := parser.Job{Env: map[string]string{"STAGE": "prod", "TARGET": "${{ env.APP }}-${{ env.STAGE }}"}}
+	run := &workflowRun{workflow: wf, repo: &git.RepoInfo{}}
+	ctx := run.newJobContext(&jobRun{id: "build", job: job}, nil)
+
+	env, err := evaluateJobEnv(wf, job, ctx)
+	if err != nil {
+		t.Fatalf("evaluateJobEnv failed: %v", err)
+	}
+	want := map[string]string{"STAGE": "prod", "APP": "web", "WF": "ci", "TARGET": "web-dev"}
+	if !reflect.DeepEqual(env, want) {
+		t.Fatalf("unexpected env: %v", env)
+	}
+
+	// step env overrides the job env and sees it through the env context
+	ctx.Values["env"] = copyEnv(env)
+	step := parser.Step{Env: map[string]string{"APP": "api", "FROM_JOB": "${{ env.STAGE }}"}}
+	stepCtx, stepEnv, err := stepContext(ctx, env, step)
+	if err != nil {
+		t.Fatalf("stepContext failed: %v", err)
+	}
+	if stepEnv["FROM_JOB"] != "prod" {
+		t.Fatalf("unexpected step env: %v", stepEnv)
+	}
+	if got := stepCtx.Values["env"].(map[string]string); got["APP"] != "api" || got["STAGE"] != "prod" {
+		t.Fatalf("unexpected env context: %v", got)
+	}
+}
+
+func TestDefaultEnv(t *testing.T) {
+	run := &workflowRun{
+		workflow:  &parser.Workflow{Name: "ci"},
+		eventName: "push",
+		repo:      &git.RepoInfo{SHA: "abc123", Ref: "refs/tags/v1.2.0", Repository: "octo/hello"},
+		actor:     "octocat",
+	}
+	env := run.defaultEnv(&jobRun{id: "build"})
+	for k, want := range map[string]string{
+		"CI":                      "true",
+		"GITHUB_ACTIONS":          "true",
+		"GITHUB_SHA":              "abc123",
+		"GITHUB_REF":              "refs/tags/v1.2.0",
+		"GITHUB_REF_NAME":         "v1.2.0",
+		"GITHUB_REF_TYPE":         "tag",
+		"GITHUB_REPOSITORY":       "octo/hello",
+		"GITHUB_REPOSITORY_OWNER": "octo",
+		"GITHUB_JOB":              "build",
+		"GITHUB_EVENT_NAME":       "push",
+		"GITHUB_ACTOR":            "octocat",
+		"RUNNER_OS":               "Linux",
+		"RUNNER_TEMP":             "/tmp",
+	} {
+		if env[k] != want {
+			t.Fatalf("%s = %q, want %q", k, env[k], want)
+		}
+	}
+
+	got := envList(mergeEnv(env, map[string]string{"CI": "false"}))
+	if got[0] != "CI=false" {
+		t.Fatalf("expected workflow env to override defaults, got %v", got[0])
+	}
+}
+
+func TestInspectWorkspace_OutsideRepository(t *testing.T) {
+	dir := t.TempDir()
+	info := inspectWorkspace(dir)
+	if info.SHA != "" || info.Repository == "" {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+}
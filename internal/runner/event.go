@@ -0,0 +1,134 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aykay76/ici/internal/container"
+	"github.com/aykay76/ici/internal/git"
+)
+
+// containerEventPath is where the event payload is written inside job
+// containers, reported as GITHUB_EVENT_PATH.
+const containerEventPath = "/github/workflow/event.json"
+
+// zeroSHA is the "before" commit GitHub reports for a newly pushed ref.
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+// ReadEventFile reads a JSON event payload, such as one saved from a real
+// workflow run.
+func ReadEventFile(file string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read event file: %w", err)
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("invalid event file %s: %w", file, err)
+	}
+	return event, nil
+}
+
+// defaultEvent synthesizes the payload GitHub would send for an event in
+// the local repository. Events without a specific payload get the
+// repository and sender only.
+func defaultEvent(eventName string, repo *git.RepoInfo, actor string) map[string]interface{} {
+	owner, name, _ := strings.Cut(repo.Repository, "/")
+	refName, refType := splitRef(repo.Ref)
+	branch := "main"
+	if refType == "branch" {
+		branch = refName
+	}
+
+	event := map[string]interface{}{
+		"repository": map[string]interface{}{
+			"name":           name,
+			"full_name":      repo.Repository,
+			"owner":          map[string]interface{}{"login": owner},
+			"default_branch": branch,
+			"html_url":       "https://github.com/" + repo.Repository,
+		},
+		"sender": map[string]interface{}{"login": actor},
+	}
+
+	headCommit := map[string]interface{}{
+		"id":      repo.SHA,
+		"message": "",
+		"author":  map[string]interface{}{"name": actor},
+	}
+
+	switch eventName {
+	case "push":
+		event["ref"] = repo.Ref
+		event["before"] = zeroSHA
+		event["after"] = repo.SHA
+		event["created"] = false
+		event["deleted"] = false
+		event["forced"] = false
+		event["head_commit"] = headCommit
+		event["commits"] = []interface{}{headCommit}
+		event["pusher"] = map[string]interface{}{"name": actor}
+	case "pull_request", "pull_request_target":
+		event["action"] = "opened"
+		event["number"] = 1
+		event["pull_request"] = map[string]interface{}{
+			"number": 1,
+			"state":  "open",
+			"title":  "Local changes",
+			"draft":  false,
+			"user":   map[string]interface{}{"login": actor},
+			"head":   map[string]interface{}{"ref": refName, "sha": repo.SHA},
+			"base":   map[string]interface{}{"ref": "main", "sha": ""},
+		}
+	case "workflow_dispatch":
+		event["ref"] = repo.Ref
+		event["inputs"] = map[string]interface{}{}
+	case "schedule":
+		event["schedule"] = ""
+	case "release":
+		tag := refName
+		if refType != "tag" {
+			tag = "v0.0.0-local"
+		}
+		event["action"] = "published"
+		event["release"] = map[string]interface{}{
+			"tag_name":         tag,
+			"name":             tag,
+			"target_commitish": repo.SHA,
+			"draft":            false,
+			"prerelease":       false,
+			"author":           map[string]interface{}{"login": actor},
+		}
+	}
+	return event
+}
+
+// pullRequestRefs returns the head and base branches of a pull_request
+// payload, reported as github.head_ref and github.base_ref.
+func pullRequestRefs(event map[string]interface{}) (head, base string) {
+	pr, _ := event["pull_request"].(map[string]interface{})
+	if pr == nil {
+		return "", ""
+	}
+	ref := func(side string) string {
+		m, _ := pr[side].(map[string]interface{})
+		s, _ := m["ref"].(string)
+		return s
+	}
+	return ref("head"), ref("base")
+}
+
+// writeEvent writes the event payload to GITHUB_EVENT_PATH in a job
+// container.
+func (r *workflowRun) writeEvent(mgr container.Runtime, containerID string) error {
+	data, err := json.MarshalIndent(r.event, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode event payload: %w", err)
+	}
+	if err := mgr.WriteFile(containerID, containerEventPath, data); err != nil {
+		return fmt.Errorf("failed to write event payload: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aykay76/ici/internal/expr"
+	"github.com/aykay76/ici/internal/git"
+	"github.com/aykay76/ici/internal/parser"
+)
+
+func TestDefaultEvent(t *testing.T) {
+	repo := &git.RepoInfo{SHA: "abc123", Ref: "refs/heads/feature", Repository: "octo/hello"}
+
+	push := defaultEvent("push", repo, "octocat")
+	if push["ref"] != "refs/heads/feature" || push["after"] != "abc123" || push["before"] != zeroSHA {
+		t.Fatalf("unexpected push payload: %v", push)
+	}
+	if push["repository"].(map[string]interface{})["full_name"] != "octo/hello" {
+		t.Fatalf("unexpected repository: %v", push["repository"])
+	}
+
+	pr := defaultEvent("pull_request", repo, "octocat")
+	if head, base := pullRequestRefs(pr); head != "feature" || base != "main" {
+		t.Fatalf("unexpected pull request refs: %s, %s", head, base)
+	}
+
+	release := defaultEvent("release", &git.RepoInfo{Ref: "refs/tags/v1.0.0"}, "octocat")
+	if release["release"].(map[string]interface{})["tag_name"] != "v1.0.0" {
+		t.Fatalf("unexpected release payload: %v", release["release"])
+	}
+
+	for _, name := range []string{"workflow_dispatch", "schedule", "issues"} {
+		if defaultEvent(name, repo, "octocat")["sender"] == nil {
+			t.Fatalf("expected a sender in the %s payload", name)
+		}
+	}
+}
+
+func TestEventContext(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "event.json")
+	data := `{"action": "labeled", "pull_request": {"number": 42, "head": {"ref": "fix"}, "base": {"ref": "develop"}}}`
+	if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	event, err := ReadEventFile(file)
+	if err != nil {
+		t.Fatalf("ReadEventFile failed: %v", err)
+	}
+
+	run := &workflowRun{workflow: &parser.Workflow{}, eventName: "pull_request", event: event, repo: &git.RepoInfo{}}
+	ctx := run.newJobContext(&jobRun{id: "test"}, nil)
+	got, err := expr.Interpolate("${{ github.event.action }} #${{ github.event.pull_request.number }} ${{ github.head_ref }}->${{ github.base_ref }}", ctx)
+	if err != nil {
+		t.Fatalf("Interpolate failed: %v", err)
+	}
+	if got != "labeled #42 fix->develop" {
+		t.Fatalf("unexpected result: %q", got)
+	}
+	if env := run.defaultEnv(&jobRun{id: "test"}); env["GITHUB_EVENT_PATH"] != containerEventPath || env["GITHUB_BASE_REF"] != "develop" {
+		t.Fatalf("unexpected env: %v", env)
+	}
+
+	if err := os.WriteFile(file, []byte("not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadEventFile(file); err == nil {
+		t.Fatalf("expected invalid JSON to fail")
+	}
+}
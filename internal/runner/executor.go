@@ -1,78 +1,635 @@
 package runner
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aykay76/ici/internal/container"
+	"github.com/aykay76/ici/internal/expr"
 	"github.com/aykay76/ici/internal/parser"
 )
 
+// Options configures an Executor.
+type Options struct {
+	// Verbose enables detailed progress output.
+	Verbose bool
+	// Parallel is the maximum number of jobs that run at the same time.
+	// Values below 1 run jobs one at a time.
+	Parallel int
+	// Matrix restricts matrix jobs to the combinations matching every
+	// key=value pair. Keys that a job's matrix does not use are ignored.
+	Matrix map[string]string
+	// Workspace is the host directory made available to job containers as
+	// GITHUB_WORKSPACE. Defaults to the current directory.
+	Workspace string
+	// WorkspaceMode is WorkspaceBind (the default) or WorkspaceCopy.
+	WorkspaceMode string
+	// Secrets holds the secrets context. Their values are masked in job
+	// output.
+	Secrets map[string]string
+	// Vars holds the vars context of configuration variables.
+	Vars map[string]string
+	// Event is the payload of the event that triggers the run, exposed as
+	// github.event. When nil a default payload is synthesized.
+	Event map[string]interface{}
+	// Inputs holds the values given for the inputs of a workflow_dispatch
+	// run. They override the inputs of Event.
+	Inputs map[string]string
+	// Quiet stops the output of steps from being written to the terminal.
+	// It is captured in the StepResults of the run either way.
+	Quiet bool
+	// NewRuntime creates the container runtime each job runs with.
+	// Defaults to container.NewRuntime, which uses the podman or docker CLI.
+	NewRuntime func(verbose bool) container.Runtime
+}
+
 // Executor handles workflow execution
 type Executor struct {
-	verbose bool
+	verbose       bool
+	parallel      int
+	matrix        map[string]string
+	workspace     string
+	workspaceMode string
+	secrets       map[string]string
+	vars          map[string]string
+	event         map[string]interface{}
+	inputs        map[string]string
+	quiet         bool
+	newRuntime    func(verbose bool) container.Runtime
+	// outMu serialises writes to the terminal from concurrently running jobs.
+	outMu sync.Mutex
+	// fetchMu guards fetched, which maps the owner/repo@ref of remote
+	// reusable workflows fetched by this executor to their checkouts.
+	fetchMu sync.Mutex
+	fetched map[string]string
 }
 
 // NewExecutor creates a new workflow executor
 func NewExecutor(verbose bool) *Executor {
+	return NewExecutorWithOptions(Options{Verbose: verbose})
+}
+
+// NewExecutorWithOptions creates a new workflow executor using the provided
+// options.
+func NewExecutorWithOptions(opts Options) *Executor {
+	parallel := opts.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	workspace := opts.Workspace
+	if workspace == "" {
+		workspace, _ = os.Getwd()
+	}
+	workspaceMode := opts.WorkspaceMode
+	if workspaceMode == "" {
+		workspaceMode = WorkspaceBind
+	}
+	newRuntime := opts.NewRuntime
+	if newRuntime == nil {
+		newRuntime = container.NewRuntime
+	}
 	return &Executor{
-		verbose: verbose,
+		verbose:       opts.Verbose,
+		parallel:      parallel,
+		matrix:        opts.Matrix,
+		workspace:     workspace,
+		workspaceMode: workspaceMode,
+		secrets:       opts.Secrets,
+		vars:          opts.Vars,
+		event:         opts.Event,
+		inputs:        opts.Inputs,
+		quiet:         opts.Quiet,
+		newRuntime:    newRuntime,
 	}
 }
 
 // Run executes a workflow
 func (e *Executor) Run(workflow *parser.Workflow, jobName string, eventName string) error {
+	_, err := e.RunWithResult(workflow, jobName, eventName)
+	return err
+}
+
+// RunWithResult executes a workflow like Run and also returns the result of
+// every job and step that ran. The result is nil when the workflow could
+// not be started, e.g. because its job dependencies are invalid.
+func (e *Executor) RunWithResult(workflow *parser.Workflow, jobName string, eventName string) (*RunResult, error) {
 	if e.verbose {
 		fmt.Printf("Executing workflow: %s\n", workflow.Name)
 		fmt.Printf("Event: %s\n", eventName)
 	}
 
-	// If specific job requested, run only that job
+	graph, err := buildJobGraph(workflow.Jobs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid job dependencies: %w", err)
+	}
+
+	run := &workflowRun{
+		workflow:  workflow,
+		eventName: eventName,
+		event:     e.event,
+		workspace: e.workspace,
+		repo:      inspectWorkspace(e.workspace),
+		actor:     localActor(),
+		secrets:   e.secrets,
+		vars:      e.vars,
+		pool:      newWorkerPool(e.parallel),
+		source:    e.workspace,
+	}
+	if run.event == nil {
+		run.event = defaultEvent(eventName, run.repo, run.actor)
+	}
+	if eventName == "workflow_dispatch" {
+		if err := run.resolveInputs(e.inputs); err != nil {
+			return nil, err
+		}
+	}
+
+	// If specific job requested, run only that job, ignoring its needs
 	if jobName != "" {
-		job, exists := workflow.Jobs[jobName]
-		if !exists {
-			return fmt.Errorf("job '%s' not found in workflow", jobName)
+		if _, exists := workflow.Jobs[jobName]; !exists {
+			return nil, fmt.Errorf("job '%s' not found in workflow", jobName)
 		}
-		return e.runJob(jobName, job)
+		graph = &jobGraph{order: []string{jobName}}
+	}
+
+	if e.verbose {
+		fmt.Printf("Scheduling %d job(s), up to %d in parallel\n", len(graph.order), e.parallel)
 	}
 
-	// Otherwise run all jobs (TODO: handle dependencies)
-	for jobID, job := range workflow.Jobs {
-		if err := e.runJob(jobID, job); err != nil {
-			return fmt.Errorf("job '%s' failed: %w", jobID, err)
+	started := time.Now()
+	results := e.execute(run, graph)
+	outcome, err := runOutcome(graph.order, results)
+	result := &RunResult{
+		Workflow:    workflow.Name,
+		Event:       eventName,
+		Result:      string(outcome),
+		Started:     started,
+		Duration:    time.Since(started),
+		Jobs:        run.results(graph),
+		Annotations: run.annotations,
+	}
+
+	if len(run.annotations) > 0 {
+		fmt.Println("Annotations:")
+		for _, a := range run.annotations {
+			fmt.Printf("  [%s] %s\n", a.Job, a)
 		}
 	}
 
-	return nil
+	return result, err
+}
+
+// execute runs the jobs of a workflow run in dependency order. Jobs whose
+// needs have all finished run concurrently; each job's `if:` decides whether
+// it runs given the results of the jobs it needs.
+func (e *Executor) execute(run *workflowRun, graph *jobGraph) map[string]jobResult {
+	s := &scheduler{
+		graph: graph,
+		run: func(jobID string, results map[string]jobResult) jobResult {
+			jr := &jobRun{
+				id:     jobID,
+				job:    run.workflow.Jobs[jobID],
+				needs:  make(map[string]jobResult),
+				status: needsStatus(graph, jobID, results),
+				caller: run.caller,
+			}
+			for _, need := range graph.needs[jobID] {
+				jr.needs[need] = results[need]
+			}
+			return e.startJob(run, jr)
+		},
+	}
+	return s.execute()
+}
+
+// jobResult is the outcome of a job, using the same values GitHub reports for
+// `needs.<job_id>.result`.
+type jobResult string
+
+const (
+	resultSuccess   jobResult = "success"
+	resultFailure   jobResult = "failure"
+	resultSkipped   jobResult = "skipped"
+	resultCancelled jobResult = "cancelled"
+)
+
+// errJobCancelled is returned by runJob when the job was cancelled while it
+// was running, e.g. because a sibling matrix instance failed.
+var errJobCancelled = errors.New("job was cancelled")
+
+// jobRun describes a single job, or a single matrix instance of a job, to
+// execute.
+type jobRun struct {
+	id  string
+	job parser.Job
+	// name is the display name, which includes the matrix values for
+	// matrix instances.
+	name string
+	// needs holds the results of the jobs this job needs.
+	needs map[string]jobResult
+	// status is what the status functions report in the job's `if:`.
+	status conditionStatus
+	// matrix holds the matrix values of a matrix instance.
+	matrix *matrixCombination
+	// index and total locate a matrix instance within its job.
+	index, total int
+	// caller is the job instance that called the reusable workflow this
+	// job belongs to, if any.
+	caller *jobRun
+	// result collects the result of a job instance while it runs.
+	result *JobResult
+}
+
+// containerName returns the name of the container a job instance runs in.
+func (jr *jobRun) containerName() string {
+	name := jr.id
+	if jr.matrix != nil {
+		name = fmt.Sprintf("%s-%d", jr.id, jr.index+1)
+	}
+	if jr.caller != nil {
+		name = jr.caller.containerName() + "-" + name
+	}
+	return name
+}
+
+// newResult returns a result for the job instance.
+func (jr *jobRun) newResult(result jobResult, err error) *JobResult {
+	name := jr.name
+	if name == "" {
+		name = jr.id
+		if jr.job.Name != "" && !expr.ContainsExpression(jr.job.Name) {
+			name = jr.job.Name
+		}
+		if jr.caller != nil {
+			name = jr.caller.name + " / " + name
+		}
+	}
+	res := &JobResult{ID: jr.id, Name: name, Result: string(result), Error: errorString(err)}
+	if jr.matrix != nil {
+		res.Matrix = jr.matrix.values
+	}
+	return res
+}
+
+// needsReason describes which needed job prevented the job from running.
+func (jr *jobRun) needsReason() string {
+	var needs []string
+	for need, result := range jr.needs {
+		if result != resultSuccess {
+			needs = append(needs, fmt.Sprintf("%s (%s)", need, result))
+		}
+	}
+	if len(needs) == 0 {
+		return ""
+	}
+	sort.Strings(needs)
+	return "needed job(s) did not succeed: " + strings.Join(needs, ", ")
+}
+
+// firstUnsuccessful returns the first of the needed jobs that did not
+// succeed, or an empty string if all of them succeeded.
+func firstUnsuccessful(needs []string, results map[string]jobResult) string {
+	for _, need := range needs {
+		if results[need] != resultSuccess {
+			return need
+		}
+	}
+	return ""
+}
+
+// printf writes a line of executor output without interleaving it with job
+// output written concurrently.
+func (e *Executor) printf(format string, args ...interface{}) {
+	e.outMu.Lock()
+	defer e.outMu.Unlock()
+	fmt.Printf(format, args...)
 }
 
-func (e *Executor) runJob(jobID string, job parser.Job) error {
+// startJob evaluates a job's `if:` condition and runs the job when it holds.
+// Matrix jobs are expanded and their instances run concurrently, and the job
+// fails if any instance fails.
+func (e *Executor) startJob(run *workflowRun, jr *jobRun) jobResult {
+	// Errors are printed and recorded with secrets masked: those of the
+	// container runtime can quote a container's env.
+	masks := run.secretMasks()
+	ctx := run.newJobContext(jr, nil)
+	ok, err := evaluateCondition(jr.job.If, ctx, jr.status)
+	err = maskError(err, masks)
+	if err != nil {
+		e.printf("✗ Job '%s' failed: %v\n", jr.id, err)
+		run.addJobResults(jr.id, jr.newResult(resultFailure, err))
+		return resultFailure
+	}
+	if !ok {
+		e.printf("- Job '%s' skipped\n", jr.id)
+		res := jr.newResult(resultSkipped, nil)
+		res.SkipReason = skipReason(jr.job.If, jr.needsReason())
+		run.addJobResults(jr.id, res)
+		return resultSkipped
+	}
+
+	instances, err := e.expandJob(jr, ctx)
+	err = maskError(err, masks)
+	if err != nil {
+		e.printf("✗ Job '%s' failed: %v\n", jr.id, err)
+		run.addJobResults(jr.id, jr.newResult(resultFailure, err))
+		return resultFailure
+	}
+	// jobs of a called workflow are shown under the calling job's name
+	if jr.caller != nil {
+		for _, inst := range instances {
+			inst.name = jr.caller.name + " / " + inst.name
+		}
+	}
+
+	// With fail-fast (the default) the first failing matrix instance
+	// cancels its siblings, and max-parallel bounds how many instances of
+	// this job run at once on top of the overall --parallel limit.
+	failFast := jr.job.Strategy.GetFailFast()
+	var limit workerPool
+	if jr.job.Strategy != nil && jr.job.Strategy.MaxParallel > 0 {
+		limit = newWorkerPool(jr.job.Strategy.MaxParallel)
+	}
+	parent := run.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	runCtx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	results := make([]jobResult, len(instances))
+	reports := make([]*JobResult, len(instances))
+	var wg sync.WaitGroup
+	for i, inst := range instances {
+		inst.result = inst.newResult(resultSuccess, nil)
+		reports[i] = inst.result
+		wg.Add(1)
+		go func(i int, inst *jobRun) {
+			defer wg.Done()
+			if limit != nil {
+				limit.acquire()
+				defer limit.release()
+			}
+			// the jobs of a called workflow take their own slots
+			if inst.job.Uses == "" {
+				run.pool.acquire()
+				defer run.pool.release()
+			}
+
+			if runCtx.Err() != nil {
+				e.printf("- Job '%s' cancelled\n", inst.name)
+				results[i] = resultCancelled
+				inst.result.Result = string(resultCancelled)
+				return
+			}
+			inst.result.Started = time.Now()
+			var err error
+			if inst.job.Uses != "" {
+				err = e.runCalledWorkflow(runCtx, run, inst)
+			} else {
+				err = e.runJobWithOutput(runCtx, run, inst)
+			}
+			err = maskError(err, masks)
+			inst.result.Duration = time.Since(inst.result.Started)
+			switch {
+			case err == nil:
+				results[i] = resultSuccess
+			case errors.Is(err, errJobCancelled):
+				e.printf("- Job '%s' cancelled\n", inst.name)
+				results[i] = resultCancelled
+			default:
+				e.printf("✗ Job '%s' failed: %v\n", inst.name, err)
+				results[i] = resultFailure
+				inst.result.Error = err.Error()
+				if failFast && inst.matrix != nil {
+					cancel()
+				}
+			}
+			inst.result.Result = string(results[i])
+		}(i, inst)
+	}
+	wg.Wait()
+	run.addJobResults(jr.id, reports...)
+
+	return aggregateResults(results)
+}
+
+// aggregateResults combines the results of a job's instances, or of the jobs
+// of a run: it is a failure if any of them failed and cancelled if any of
+// them was cancelled.
+func aggregateResults(results []jobResult) jobResult {
+	aggregate := resultSuccess
+	for _, r := range results {
+		switch r {
+		case resultFailure:
+			return resultFailure
+		case resultCancelled:
+			aggregate = resultCancelled
+		}
+	}
+	return aggregate
+}
+
+// runOutcome returns the result of a run from the results of its jobs, in
+// order: it fails if any job failed and is cancelled if any job was
+// cancelled, with an error naming those jobs.
+func runOutcome(order []string, results map[string]jobResult) (jobResult, error) {
+	byResult := make(map[jobResult][]string)
+	ordered := make([]jobResult, 0, len(order))
+	for _, jobID := range order {
+		byResult[results[jobID]] = append(byResult[results[jobID]], jobID)
+		ordered = append(ordered, results[jobID])
+	}
+	res := aggregateResults(ordered)
+	switch res {
+	case resultFailure:
+		return res, fmt.Errorf("job(s) failed: %s", strings.Join(byResult[resultFailure], ", "))
+	case resultCancelled:
+		return res, fmt.Errorf("job(s) cancelled: %s", strings.Join(byResult[resultCancelled], ", "))
+	}
+	return res, nil
+}
+
+// expandJob returns the instances of a job: one per matrix combination, or
+// the job itself when it has no matrix.
+func (e *Executor) expandJob(jr *jobRun, ctx *expr.Context) ([]*jobRun, error) {
+	base := jr.job.Name
+	if base == "" {
+		base = jr.id
+	}
+	if jr.job.Strategy == nil || jr.job.Strategy.Matrix == nil {
+		inst := *jr
+		inst.name = base
+		if expr.ContainsExpression(base) {
+			name, err := expr.Interpolate(base, ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate name: %w", err)
+			}
+			inst.name = name
+		}
+		return []*jobRun{&inst}, nil
+	}
+
+	combos, err := expandMatrix(jr.job.Strategy.Matrix, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if combos, err = filterCombinations(combos, e.matrix); err != nil {
+		return nil, err
+	}
+
+	instances := make([]*jobRun, len(combos))
+	for i, c := range combos {
+		inst := *jr
+		inst.matrix = c
+		inst.index = i
+		inst.total = len(combos)
+		inst.name = c.name(base)
+		// A name that uses expressions, typically of the matrix, is used
+		// as is rather than suffixed with the matrix values.
+		if expr.ContainsExpression(base) {
+			nameCtx := cloneContext(ctx)
+			nameCtx.Values["matrix"] = c.values
+			name, err := expr.Interpolate(base, nameCtx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate name: %w", err)
+			}
+			inst.name = name
+		}
+		instances[i] = &inst
+	}
+	return instances, nil
+}
+
+// runJobWithOutput runs a job with its output prefixed by the job name so
+// that logs from concurrently running jobs stay readable.
+func (e *Executor) runJobWithOutput(runCtx context.Context, run *workflowRun, jr *jobRun) error {
+	prefix := fmt.Sprintf("[%s] ", jr.name)
+	stdout := newPrefixWriter(os.Stdout, &e.outMu, prefix)
+	stderr := newPrefixWriter(os.Stderr, &e.outMu, prefix)
+	defer func() {
+		_ = stdout.Flush()
+		_ = stderr.Flush()
+	}()
+
+	return e.runJob(runCtx, run, jr, stdout, stderr)
+}
+
+// runJob runs a job instance in its own container. When runCtx is cancelled
+// the container is stopped, only the steps whose `if:` holds once the job is
+// cancelled run, and errJobCancelled is returned.
+func (e *Executor) runJob(runCtx context.Context, run *workflowRun, jr *jobRun, stdout, stderr io.Writer) (err error) {
+	jobID, job := jr.id, jr.job
+	ctx := run.newJobContext(jr, nil)
+	jobEnv, err := evaluateJobEnv(run.workflow, job, ctx)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate env for job %s: %w", jobID, err)
+	}
+	ctx.Values["env"] = copyEnv(jobEnv)
+
+	runsOn, err := expr.Interpolate(job.GetRunsOn(), ctx)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate runs-on for job %s: %w", jobID, err)
+	}
+
 	if e.verbose {
-		fmt.Printf("\n=== Running job: %s ===\n", jobID)
-		fmt.Printf("Runs-on: %s\n", job.GetRunsOn())
-		fmt.Printf("Steps: %d\n", len(job.Steps))
+		fmt.Fprintf(stdout, "=== Running job: %s ===\n", jr.name)
+		fmt.Fprintf(stdout, "Runs-on: %s\n", runsOn)
+		fmt.Fprintf(stdout, "Steps: %d\n", len(job.Steps))
 	}
 
 	// Create container based on runs-on
-	mgr := container.NewManager(e.verbose)
-	image, err := mgr.MapRunsOn(job.GetRunsOn())
-	if err != nil {
-		return fmt.Errorf("failed to map runs-on for job %s: %w", jobID, err)
+	// Secrets and values registered with ::add-mask:: are masked in
+	// everything the job prints, and workflow commands such as ::group::
+	// written by steps are processed before their output is shown.
+	masks := run.secretMasks()
+	defer func() { err = maskError(err, masks) }()
+	// The output of each step is also captured for its StepResult; with
+	// Quiet it is only captured.
+	stdoutGate, stderrGate := &gateWriter{out: stdout}, &gateWriter{out: stderr}
+	commands := newCommandWriter(stdoutGate, masks, e.verbose)
+	defer func() {
+		annotations := run.addAnnotations(jr.name, commands.annotations)
+		if jr.result != nil {
+			jr.result.Annotations = annotations
+		}
+	}()
+	// Output is captured after it is masked, so that truncating it never
+	// cuts a secret short of being masked.
+	output := &stepOutput{
+		stdout: newCaptureWriter(commands),
+		stderr: newCaptureWriter(stderrGate),
+		gates:  []*gateWriter{stdoutGate, stderrGate},
+		tee:    !e.quiet,
+		masks:  masks,
+	}
+	maskedStdout := newMaskWriter(output.stdout, masks)
+	maskedStderr := newMaskWriter(output.stderr, masks)
+	flush := func() {
+		_ = maskedStdout.Flush()
+		_ = maskedStderr.Flush()
+		_ = commands.Flush()
 	}
+	defer flush()
+	output.flush = flush
+	stdout, stderr = maskedStdout, maskedStderr
 
-	// Build a simple ContainerConfig: pass job-level env into the container.
+	mgr := e.newRuntime(e.verbose)
+	mgr.SetOutput(stdout, stderr)
+
+	// Build the ContainerConfig: pass the default variables and the
+	// workflow and job env into the container and make the workspace
+	// available to it. A job with `container:` runs in that image, with
+	// its env, ports, volumes and options, instead of the runner image.
 	cfg := &container.ContainerConfig{}
-	if len(job.Env) > 0 {
-		envs := make([]string, 0, len(job.Env))
-		for k, v := range job.Env {
-			envs = append(envs, fmt.Sprintf("%s=%s", k, v))
+	var image string
+	containerEnv := map[string]string{}
+	if job.Container != nil {
+		spec, err := evaluateContainer(job.Container, ctx)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate container for job %s: %w", jobID, err)
+		}
+		if err := spec.prepare(mgr, cfg); err != nil {
+			return fmt.Errorf("failed to prepare container for job %s: %w", jobID, err)
 		}
-		cfg.Env = envs
+		image, containerEnv = spec.image, spec.env
+	} else if image, err = container.MapRunsOn(runsOn); err != nil {
+		return fmt.Errorf("failed to map runs-on for job %s: %w", jobID, err)
+	}
+	cfg.Env = envList(mergeEnv(run.defaultEnv(jr), containerEnv, jobEnv))
+	if err := e.configureWorkspace(cfg); err != nil {
+		return err
 	}
 
-	containerID, err := mgr.CreateContainerWithConfig(image, jobID, cfg)
+	// Service containers start first, on a network the job container
+	// joins, and are torn down after it.
+	services, err := startServices(runCtx, mgr, jr, ctx, stdout)
+	defer services.stop()
+	if errors.Is(err, errJobCancelled) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start services for job %s: %w", jobID, err)
+	}
+	cfg.Network = services.network
+
+	containerID, err := mgr.CreateContainerWithConfig(image, jr.containerName(), cfg)
 	if err != nil {
 		return fmt.Errorf("failed to create container for job %s: %w", jobID, err)
 	}
+	jobCtx := map[string]interface{}{
+		"status":    "success",
+		"container": map[string]interface{}{"id": containerID, "network": services.network},
+		"services":  services.context,
+	}
+	ctx.Values["job"] = jobCtx
 	// Ensure cleanup: stop then remove the container explicitly so lifecycle is clear.
 	defer func() {
 		// best-effort stop; ignore error to prefer removal
@@ -80,25 +637,340 @@ func (e *Executor) runJob(jobID string, job parser.Job) error {
 		_ = mgr.RemoveContainer(containerID)
 	}()
 
-	// Execute each step inside the container
+	if err := e.populateWorkspace(mgr, containerID); err != nil {
+		return err
+	}
+	if err := run.writeEvent(mgr, containerID); err != nil {
+		return err
+	}
+
+	// Stop the container as soon as the job is cancelled so that the step
+	// running in it stops. The container is kept until the job ends, for
+	// the steps that still run once the job is cancelled.
+	finished := make(chan struct{})
+	defer close(finished)
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-runCtx.Done():
+			// not written to stderr: the step's output is still
+			// streaming into it
+			e.printf("Cancelling job '%s'\n", jr.name)
+			_ = mgr.StopContainer(containerID)
+			close(stopped)
+		case <-finished:
+		}
+	}()
+
+	// Execute each step inside the container. Once a step fails or the job
+	// is cancelled the remaining steps only run if their `if:` asks for it,
+	// e.g. always().
+	x := &jobExecution{
+		mgr:         mgr,
+		containerID: containerID,
+		ctx:         ctx,
+		env:         jobEnv,
+		defaults:    runDefaults(run.workflow, job),
+		steps:       make(map[string]interface{}),
+		stdout:      stdout,
+		stderr:      stderr,
+		output:      output,
+		commands:    commands,
+		masks:       masks,
+		result:      jr.result,
+	}
+	ctx.Values["steps"] = x.steps
+	var firstErr error
+	cancel := func() {
+		<-stopped
+		x.cancelled, x.stopped = true, true
+		jobCtx["status"] = "cancelled"
+	}
 	for i, step := range job.Steps {
-		if e.verbose {
-			fmt.Printf("\nStep %d: %s\n", i+1, step.Name)
-			if step.Uses != "" {
-				fmt.Printf("  Uses: %s\n", step.Uses)
+		if runCtx.Err() != nil && !x.cancelled {
+			cancel()
+		}
+		interrupted := !x.cancelled
+		err := e.runStep(x, i, step)
+		flush()
+		if err != nil {
+			if interrupted && runCtx.Err() != nil {
+				// the step was stopped because the job was cancelled
+				cancel()
+				x.cancelStep(step)
+				continue
 			}
-			if step.Run != "" {
-				fmt.Printf("  Run: %s\n", step.Run)
+			fmt.Fprintf(stderr, "✗ Step %d (%s) failed: %v\n", i+1, stepName(step), err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("step %d failed: %w", i+1, err)
+				x.failed = true
+				jobCtx["status"] = "failure"
 			}
 		}
+	}
+	// Outputs are evaluated even when a step failed, so that jobs that
+	// run after a failure can still use them.
+	if err := run.recordOutputs(jr, ctx); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	if x.cancelled {
+		return errJobCancelled
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+
+	fmt.Fprintf(stdout, "✓ Job '%s' completed successfully\n", jr.name)
+	return nil
+}
+
+// jobExecution holds the state of a job while its steps run.
+type jobExecution struct {
+	mgr         container.Runtime
+	containerID string
+	ctx         *expr.Context
+	// env holds the job env, updated by the steps' GITHUB_ENV files.
+	env      map[string]string
+	defaults parser.RunDefaults
+	// steps is the steps context: the outputs and outcome of each step
+	// with an id.
+	steps map[string]interface{}
+	// path holds the directories steps added to GITHUB_PATH, most recently
+	// added first, and basePath the PATH of the container they prefix.
+	path     []string
+	basePath string
+	stdout   io.Writer
+	stderr   io.Writer
+	// output captures the output of each step, and commands collects the
+	// annotations steps report.
+	output   *stepOutput
+	commands *commandWriter
+	masks    *masker
+	// failed records whether a step of the job has failed, and cancelled
+	// whether the job was cancelled.
+	failed    bool
+	cancelled bool
+	// stopped records that the container was stopped when the job was
+	// cancelled and must be started again before a step runs.
+	stopped bool
+	// result collects the results of the steps, if not nil.
+	result *JobResult
+}
+
+// addStepResult records the result of a step.
+func (x *jobExecution) addStepResult(res *StepResult) {
+	if x.result != nil {
+		x.result.Steps = append(x.result.Steps, res)
+	}
+}
+
+// cancelStep records the step that was running when the job was cancelled
+// as cancelled.
+func (x *jobExecution) cancelStep(step parser.Step) {
+	x.recordStep(step, "cancelled", nil)
+	if x.result != nil && len(x.result.Steps) > 0 {
+		x.result.Steps[len(x.result.Steps)-1].Outcome = "cancelled"
+	}
+}
+
+// recordStep adds the outcome and outputs of a step to the steps context.
+// Steps without an id cannot be referenced and are not recorded.
+func (x *jobExecution) recordStep(step parser.Step, outcome string, outputs map[string]string) {
+	if step.ID == "" {
+		return
+	}
+	x.steps[step.ID] = map[string]interface{}{
+		"outputs":    stringMap(outputs),
+		"outcome":    outcome,
+		"conclusion": outcome,
+	}
+}
+
+// applyFileCommands makes the env and PATH updates a step wrote to its
+// GITHUB_ENV and GITHUB_PATH files visible to the steps that follow it.
+func (x *jobExecution) applyFileCommands(res *fileCommandResult) {
+	if len(res.env) > 0 {
+		x.env = mergeEnv(x.env, res.env)
+		x.ctx.Values["env"] = copyEnv(x.env)
+	}
+	for _, p := range res.path {
+		x.path = append([]string{p}, x.path...)
+	}
+}
+
+// pathEnv returns the PATH for the next step, with the directories added to
+// GITHUB_PATH in front of the container's PATH.
+func (x *jobExecution) pathEnv() string {
+	if x.basePath == "" {
+		out, err := x.mgr.CommandOutput(x.containerID, "printenv", "PATH")
+		x.basePath = strings.TrimSpace(out)
+		if err != nil || x.basePath == "" {
+			x.basePath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+		}
+	}
+	return strings.Join(append(append([]string{}, x.path...), x.basePath), ":")
+}
+
+// runStep runs a single step if its `if:` condition holds and records its
+// result.
+func (e *Executor) runStep(x *jobExecution, i int, step parser.Step) (err error) {
+	res := &StepResult{Number: i + 1, ID: step.ID, Name: stepName(step), Outcome: "success", Started: time.Now()}
+	x.addStepResult(res)
+	defer func() {
+		res.Duration = time.Since(res.Started)
+		if err != nil {
+			res.Outcome = "failure"
+			res.Error = maskError(err, x.masks).Error()
+			res.ExitCode = exitCode(err)
+		}
+	}()
 
-		if step.Run != "" {
-			if err := mgr.RunCommand(containerID, step.Run); err != nil {
-				return fmt.Errorf("step %d failed: %w", i+1, err)
+	stdout := x.stdout
+	stepCtx, stepEnv, err := stepContext(x.ctx, x.env, step)
+	if err != nil {
+		return err
+	}
+
+	ok, err := evaluateCondition(step.If, stepCtx, conditionStatus{success: !x.failed, failure: x.failed, cancelled: x.cancelled})
+	if err != nil {
+		return err
+	}
+	if !ok && x.cancelled {
+		fmt.Fprintf(stdout, "- Step %d (%s) cancelled\n", i+1, stepName(step))
+		x.recordStep(step, "cancelled", nil)
+		res.Outcome = "cancelled"
+		res.SkipReason = "the job was cancelled"
+		return nil
+	}
+	if !ok {
+		fmt.Fprintf(stdout, "- Step %d (%s) skipped\n", i+1, stepName(step))
+		x.recordStep(step, "skipped", nil)
+		res.Outcome = "skipped"
+		res.SkipReason = skipReason(step.If, "a previous step failed")
+		return nil
+	}
+
+	if x.stopped {
+		if err := x.mgr.StartContainer(x.containerID); err != nil {
+			return fmt.Errorf("failed to restart container: %w", err)
+		}
+		x.stopped = false
+	}
+
+	with, err := expr.InterpolateMap(step.With, stepCtx)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate with: %w", err)
+	}
+	command, err := expr.Interpolate(step.Run, stepCtx)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate run: %w", err)
+	}
+
+	if e.verbose {
+		fmt.Fprintf(stdout, "Step %d: %s\n", i+1, step.Name)
+		if step.Uses != "" {
+			fmt.Fprintf(stdout, "  Uses: %s\n", step.Uses)
+			for k, v := range with {
+				fmt.Fprintf(stdout, "    %s: %s\n", k, v)
 			}
 		}
+		if command != "" {
+			fmt.Fprintf(stdout, "  Run: %s\n", command)
+		}
 	}
 
-	fmt.Printf("✓ Job '%s' completed successfully\n", jobID)
-	return nil
+	var outputs map[string]string
+	if x.output != nil {
+		annotations := len(x.commands.annotations)
+		x.output.begin()
+		defer func() {
+			res.Stdout, res.Stderr = x.output.end()
+			res.Annotations = append([]Annotation(nil), x.commands.annotations[annotations:]...)
+		}()
+	}
+	switch {
+	case step.Uses != "":
+		err = e.runAction(x, step.Uses, with)
+	case command != "":
+		outputs, err = e.runCommandStep(x, i, step, command, stepCtx, stepEnv)
+	}
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	x.recordStep(step, outcome, outputs)
+	return err
+}
+
+// runCommandStep runs a `run:` step and applies what it wrote to its
+// GITHUB_OUTPUT, GITHUB_ENV and GITHUB_PATH files, returning its outputs.
+func (e *Executor) runCommandStep(x *jobExecution, i int, step parser.Step, command string, ctx *expr.Context, stepEnv map[string]string) (map[string]string, error) {
+	fc := newFileCommands(i)
+	if err := fc.create(x.mgr, x.containerID); err != nil {
+		return nil, err
+	}
+
+	env := mergeEnv(x.env, stepEnv, fc.vars())
+	if len(x.path) > 0 {
+		env["PATH"] = x.pathEnv()
+	}
+	opts, err := runExecOptions(step, x.defaults, ctx, env)
+	if err != nil {
+		return nil, err
+	}
+	runErr := x.mgr.RunCommandWithOptions(x.containerID, command, opts)
+
+	res, err := fc.collect(x.mgr, x.containerID)
+	if err != nil {
+		if runErr != nil {
+			return nil, runErr
+		}
+		return nil, err
+	}
+	x.applyFileCommands(res)
+	return res.outputs, runErr
+}
+
+// runAction runs a `uses:` step. Only actions with a built-in local
+// implementation are supported; other actions are reported and skipped.
+func (e *Executor) runAction(x *jobExecution, uses string, with map[string]string) error {
+	switch {
+	case isCheckoutAction(uses):
+		return e.runCheckout(x, with)
+	default:
+		fmt.Fprintf(x.stdout, "⚠️  Action %s is not supported yet; skipping\n", uses)
+		return nil
+	}
+}
+
+// stepName returns a display name for a step, falling back to what it runs
+// when it has no name.
+func stepName(step parser.Step) string {
+	switch {
+	case step.Name != "":
+		return step.Name
+	case step.Uses != "":
+		return step.Uses
+	default:
+		line := strings.TrimSpace(strings.SplitN(strings.TrimSpace(step.Run), "\n", 2)[0])
+		return "Run " + line
+	}
+}
+
+// stepContext returns a copy of the job context whose env context also holds
+// the step's own env, evaluated against the job context. The step's env is
+// returned as well.
+func stepContext(jobCtx *expr.Context, jobEnv map[string]string, step parser.Step) (*expr.Context, map[string]string, error) {
+	stepEnv, err := expr.InterpolateMap(step.Env, jobCtx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to evaluate env: %w", err)
+	}
+	env := copyEnv(jobEnv)
+	for k, v := range stepEnv {
+		env[k] = v
+	}
+
+	ctx := cloneContext(jobCtx)
+	ctx.Values["env"] = env
+	return ctx, stepEnv, nil
 }
@@ -0,0 +1,650 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aykay76/ici/internal/container"
+	"github.com/aykay76/ici/internal/container/containertest"
+	"github.com/aykay76/ici/internal/parser"
+	"gopkg.in/yaml.v3"
+)
+
+const pipelineWorkflow = `
+name: CI
+on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    container: golang:1.25
+    services:
+      postgres:
+        image: postgres:16
+        ports: [5432]
+    outputs:
+      version: ${{ steps.version.outputs.version }}
+    steps:
+      - id: version
+        run: compute-version
+      - run: test --db localhost:${{ job.services.postgres.ports['5432'] }}
+  publish:
+    needs: build
+    runs-on: ubuntu-latest
+    steps:
+      - run: publish ${{ needs.build.outputs.version }}
+      - run: fail
+      - run: never
+      - if: always()
+        run: cleanup
+`
+
+// fakeCommands simulates the commands of pipelineWorkflow.
+func fakeCommands(c *containertest.Container, command string, opts *container.ExecOptions, stdout, stderr io.Writer) error {
+	switch {
+	case command == "compute-version":
+		c.AppendFile(c.Getenv(opts, "GITHUB_OUTPUT"), "version=1.2.3\n")
+	case command == "fail":
+		fmt.Fprintln(stderr, "boom")
+		return &container.ExitError{Code: 1}
+	case strings.HasPrefix(command, "publish"):
+		fmt.Fprintln(stdout, "::warning::publishing a snapshot")
+		fmt.Fprintln(stdout, "published")
+	}
+	return nil
+}
+
+func TestExecutor_RunsWorkflowWithFakeRuntime(t *testing.T) {
+	var wf parser.Workflow
+	if err := yaml.Unmarshal([]byte(pipelineWorkflow), &wf); err != nil {
+		t.Fatalf("failed to parse workflow: %v", err)
+	}
+	rt := containertest.New()
+	rt.Exec = fakeCommands
+
+	e := NewExecutorWithOptions(Options{Workspace: t.TempDir(), NewRuntime: rt.NewRuntime})
+	err := e.Run(&wf, "", "push")
+	if err == nil || !strings.Contains(err.Error(), "job(s) failed: publish") {
+		t.Fatalf("expected the publish job to fail, got %v", err)
+	}
+
+	build := rt.Container("build")
+	if build == nil || build.Image != "golang:1.25" || build.Config.Network != "ici-build" {
+		t.Fatalf("unexpected build container: %+v", build)
+	}
+	postgres := rt.Container("build-postgres")
+	if postgres == nil || !postgres.Config.ImageCommand || postgres.Config.NetworkAliases[0] != "postgres" {
+		t.Fatalf("unexpected postgres service: %+v", postgres)
+	}
+	if got := strings.Join(build.Commands, "|"); !strings.Contains(got, "test --db localhost:32768") {
+		t.Fatalf("expected the service port in the job context, got commands %q", got)
+	}
+
+	publish := rt.Container("publish")
+	if publish == nil || publish.Image != "ubuntu:22.04" {
+		t.Fatalf("unexpected publish container: %+v", publish)
+	}
+	var ran []string
+	for _, cmd := range publish.Commands {
+		if !strings.HasPrefix(cmd, "printenv") {
+			ran = append(ran, cmd)
+		}
+	}
+	if got := strings.Join(ran, "|"); got != "publish 1.2.3|fail|cleanup" {
+		t.Fatalf("unexpected publish commands: %q", got)
+	}
+
+	for _, c := range rt.Containers() {
+		if !c.Removed {
+			t.Errorf("container %s was not removed", c.Name)
+		}
+	}
+	if networks := rt.Networks(); len(networks) != 0 {
+		t.Errorf("networks were not removed: %v", networks)
+	}
+}
+
+func TestExecutor_UnhealthyServiceFailsJob(t *testing.T) {
+	var wf parser.Workflow
+	if err := yaml.Unmarshal([]byte(pipelineWorkflow), &wf); err != nil {
+		t.Fatalf("failed to parse workflow: %v", err)
+	}
+	rt := containertest.New()
+	rt.Unhealthy = map[string]bool{"postgres:16": true}
+
+	e := NewExecutorWithOptions(Options{Workspace: t.TempDir(), NewRuntime: rt.NewRuntime})
+	if err := e.Run(&wf, "build", "push"); err == nil {
+		t.Fatalf("expected an unhealthy service to fail the job")
+	}
+	if c := rt.Container("build"); c != nil {
+		t.Fatalf("expected the job container not to be created")
+	}
+	if !rt.Container("build-postgres").Removed || len(rt.Networks()) != 0 {
+		t.Fatalf("expected services to be torn down: %v", rt.Log())
+	}
+}
+
+func TestExecutor_RunWithResult(t *testing.T) {
+	var wf parser.Workflow
+	if err := yaml.Unmarshal([]byte(pipelineWorkflow), &wf); err != nil {
+		t.Fatalf("failed to parse workflow: %v", err)
+	}
+	rt := containertest.New()
+	rt.Exec = fakeCommands
+
+	e := NewExecutorWithOptions(Options{Workspace: t.TempDir(), NewRuntime: rt.NewRuntime, Quiet: true})
+	result, err := e.RunWithResult(&wf, "", "push")
+	if err == nil || result == nil {
+		t.Fatalf("expected a failed run with a result, got %v, %v", result, err)
+	}
+	if result.Workflow != "CI" || result.Result != "failure" || len(result.Jobs) != 2 {
+		t.Fatalf("unexpected run result: %+v", result)
+	}
+
+	build, publish := result.Jobs[0], result.Jobs[1]
+	if build.ID != "build" || build.Result != "success" || len(build.Steps) != 2 {
+		t.Fatalf("unexpected build result: %+v", build)
+	}
+	if publish.Result != "failure" || !strings.Contains(publish.Error, "step 2 failed") {
+		t.Fatalf("unexpected publish result: %+v", publish)
+	}
+
+	var got []string
+	for _, s := range publish.Steps {
+		got = append(got, fmt.Sprintf("%d %s %s %d %q %q %q", s.Number, s.Name, s.Outcome, s.ExitCode, s.Stdout, s.Stderr, s.SkipReason))
+	}
+	want := []string{
+		`1 Run publish ${{ needs.build.outputs.version }} success 0 "::warning::publishing a snapshot\npublished\n" "" ""`,
+		`2 Run fail failure 1 "" "boom\n" ""`,
+		`3 Run never skipped 0 "" "" "a previous step failed"`,
+		`4 Run cleanup success 0 "" "" ""`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected step results:\n%s", strings.Join(got, "\n"))
+	}
+
+	step := publish.Steps[0]
+	if len(step.Annotations) != 1 || step.Annotations[0].Message != "publishing a snapshot" {
+		t.Fatalf("expected the step's annotation, got %+v", step.Annotations)
+	}
+	if len(publish.Annotations) != 1 || publish.Annotations[0].Job != "publish" || len(result.Annotations) != 1 {
+		t.Fatalf("expected the annotation on the job and run, got %+v and %+v", publish.Annotations, result.Annotations)
+	}
+}
+
+func TestExecutor_RunWithResultRecordsSkippedJobs(t *testing.T) {
+	var wf parser.Workflow
+	if err := yaml.Unmarshal([]byte(pipelineWorkflow), &wf); err != nil {
+		t.Fatalf("failed to parse workflow: %v", err)
+	}
+	rt := containertest.New()
+	rt.Unhealthy = map[string]bool{"postgres:16": true}
+
+	e := NewExecutorWithOptions(Options{Workspace: t.TempDir(), NewRuntime: rt.NewRuntime, Quiet: true})
+	result, _ := e.RunWithResult(&wf, "", "push")
+	publish := result.Jobs[1]
+	if publish.Result != "skipped" || publish.SkipReason != "needed job(s) did not succeed: build (failure)" {
+		t.Fatalf("unexpected publish result: %+v", publish)
+	}
+	if build := result.Jobs[0]; !strings.Contains(build.Error, "failed to start services") || len(build.Steps) != 0 {
+		t.Fatalf("unexpected build result: %+v", build)
+	}
+}
+
+func TestExecutor_MasksSecretsInJobErrors(t *testing.T) {
+	const workflow = `
+name: CI
+on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    env:
+      TOKEN: ${{ secrets.TOKEN }}
+    steps:
+      - run: make
+`
+	var wf parser.Workflow
+	if err := yaml.Unmarshal([]byte(workflow), &wf); err != nil {
+		t.Fatalf("failed to parse workflow: %v", err)
+	}
+	rt := containertest.New()
+	// Like the CLI runtime did, quote the container's env in the error.
+	rt.Create = func(name string, image string, cfg *container.ContainerConfig) error {
+		return fmt.Errorf("create %s failed: %s", name, strings.Join(cfg.Env, " "))
+	}
+
+	e := NewExecutorWithOptions(Options{
+		Workspace:  t.TempDir(),
+		NewRuntime: rt.NewRuntime,
+		Secrets:    map[string]string{"TOKEN": "s3cr3t-value"},
+		Quiet:      true,
+	})
+	result, err := e.RunWithResult(&wf, "", "push")
+	if err == nil {
+		t.Fatalf("expected the job to fail")
+	}
+	build := result.Jobs[0]
+	if build.Result != "failure" || !strings.Contains(build.Error, "TOKEN=***") {
+		t.Fatalf("expected the masked env in the job error, got %+v", build)
+	}
+	if strings.Contains(build.Error, "s3cr3t-value") || strings.Contains(err.Error(), "s3cr3t-value") {
+		t.Fatalf("secret leaked into the error: %q / %q", build.Error, err)
+	}
+}
+
+func TestExecutor_ParallelJobsKeepTheirOwnOutput(t *testing.T) {
+	const workflow = `
+name: CI
+on: push
+jobs:
+  a:
+    runs-on: ubuntu-latest
+    steps:
+      - run: say a
+  b:
+    runs-on: ubuntu-latest
+    steps:
+      - run: say b
+`
+	var wf parser.Workflow
+	if err := yaml.Unmarshal([]byte(workflow), &wf); err != nil {
+		t.Fatalf("failed to parse workflow: %v", err)
+	}
+	rt := containertest.New()
+	// The job containers wait for each other, so both jobs have set their
+	// output before either runs a command.
+	var created sync.WaitGroup
+	created.Add(2)
+	rt.Create = func(name string, image string, cfg *container.ContainerConfig) error {
+		created.Done()
+		created.Wait()
+		return nil
+	}
+	rt.Exec = func(c *containertest.Container, command string, opts *container.ExecOptions, stdout, stderr io.Writer) error {
+		if word, ok := strings.CutPrefix(command, "say "); ok {
+			fmt.Fprintln(stdout, word)
+		}
+		return nil
+	}
+
+	e := NewExecutorWithOptions(Options{Workspace: t.TempDir(), NewRuntime: rt.NewRuntime, Parallel: 2, Quiet: true})
+	result, err := e.RunWithResult(&wf, "", "push")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	for _, job := range result.Jobs {
+		if got := job.Steps[0].Stdout; got != job.ID+"\n" {
+			t.Errorf("job %s captured %q", job.ID, got)
+		}
+	}
+}
+
+// waitForLog waits for the runtime to log entry.
+func waitForLog(rt *containertest.Runtime, entry string) bool {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		for _, e := range rt.Log() {
+			if e == entry {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// peakContainers returns the most containers that existed at once.
+func peakContainers(log []string) int {
+	live, peak := 0, 0
+	for _, e := range log {
+		switch {
+		case strings.HasPrefix(e, "create "):
+			live++
+			peak = max(peak, live)
+		case strings.HasPrefix(e, "rm "):
+			live--
+		}
+	}
+	return peak
+}
+
+func TestExecutor_FailFastCancelsSiblings(t *testing.T) {
+	const matrixJob = `
+name: CI
+on: push
+jobs:
+  test:
+    runs-on: ubuntu-latest
+    strategy:
+      max-parallel: 2
+      matrix:
+        n: [1, 2, 3, 4, 5]
+    steps:
+      - run: work ${{ matrix.n }}
+`
+	const matrixCall = `
+name: CI
+on: push
+jobs:
+  test:
+    strategy:
+      max-parallel: 2
+      matrix:
+        n: [1, 2, 3, 4, 5]
+    uses: ./.github/workflows/work.yml
+    with:
+      n: ${{ matrix.n }}
+`
+	const called = `
+on:
+  workflow_call:
+    inputs:
+      n:
+        type: string
+jobs:
+  work:
+    runs-on: ubuntu-latest
+    steps:
+      - run: work ${{ inputs.n }}
+`
+	for _, tt := range []struct {
+		name     string
+		workflow string
+	}{
+		{"job", matrixJob},
+		{"reusable workflow", matrixCall},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := t.TempDir()
+			writeFile(t, filepath.Join(ws, ".github", "workflows", "work.yml"), called)
+			var wf parser.Workflow
+			if err := yaml.Unmarshal([]byte(tt.workflow), &wf); err != nil {
+				t.Fatalf("failed to parse workflow: %v", err)
+			}
+
+			// The first instance to run its step fails once a second
+			// instance is running its own, which then runs until its
+			// container is stopped.
+			rt := containertest.New()
+			var arrived atomic.Int32
+			inFlight := make(chan string, 1)
+			rt.Exec = func(c *containertest.Container, command string, opts *container.ExecOptions, stdout, stderr io.Writer) error {
+				if !strings.HasPrefix(command, "work ") {
+					return nil
+				}
+				if arrived.Add(1) == 1 {
+					select {
+					case <-inFlight:
+					case <-time.After(5 * time.Second):
+						t.Errorf("no sibling started")
+					}
+					return &container.ExitError{Code: 1}
+				}
+				inFlight <- c.Name
+				if !waitForLog(rt, "stop "+c.Name) {
+					t.Errorf("container %s was not stopped", c.Name)
+				}
+				return &container.ExitError{Code: 137}
+			}
+
+			e := NewExecutorWithOptions(Options{Workspace: ws, NewRuntime: rt.NewRuntime, Parallel: 5, Quiet: true})
+			result, err := e.RunWithResult(&wf, "", "push")
+			if err == nil {
+				t.Fatalf("expected the run to fail")
+			}
+
+			var got []string
+			for _, job := range result.Jobs {
+				got = append(got, job.Result)
+			}
+			sort.Strings(got)
+			if want := "cancelled|cancelled|cancelled|cancelled|failure"; strings.Join(got, "|") != want {
+				t.Fatalf("unexpected instance results %q, want %q", got, want)
+			}
+			if arrived.Load() != 2 || len(rt.Containers()) != 2 {
+				t.Fatalf("expected only two instances to start, log: %v", rt.Log())
+			}
+			for _, c := range rt.Containers() {
+				if !waitForLog(rt, "rm "+c.Name) {
+					t.Errorf("container %s was not removed: %v", c.Name, rt.Log())
+				}
+			}
+		})
+	}
+}
+
+func TestExecutor_CancelledJobRunsCleanupSteps(t *testing.T) {
+	const workflow = `
+name: CI
+on: push
+jobs:
+  test:
+    runs-on: ubuntu-latest
+    strategy:
+      matrix:
+        n: [1, 2]
+    steps:
+      - run: work ${{ matrix.n }}
+      - run: build ${{ matrix.n }}
+      - if: always()
+        run: cleanup ${{ matrix.n }}
+      - if: cancelled()
+        run: notify ${{ matrix.n }}
+      - if: failure()
+        run: report ${{ matrix.n }}
+`
+	var wf parser.Workflow
+	if err := yaml.Unmarshal([]byte(workflow), &wf); err != nil {
+		t.Fatalf("failed to parse workflow: %v", err)
+	}
+
+	// The first instance to run its step fails once its sibling is
+	// running its own, which then runs until its container is stopped.
+	rt := containertest.New()
+	var arrived atomic.Int32
+	inFlight := make(chan string, 1)
+	rt.Exec = func(c *containertest.Container, command string, opts *container.ExecOptions, stdout, stderr io.Writer) error {
+		if !strings.HasPrefix(command, "work ") {
+			return nil
+		}
+		if arrived.Add(1) == 1 {
+			select {
+			case <-inFlight:
+			case <-time.After(5 * time.Second):
+				t.Errorf("no sibling started")
+			}
+			return &container.ExitError{Code: 1}
+		}
+		inFlight <- c.Name
+		if !waitForLog(rt, "stop "+c.Name) {
+			t.Errorf("container %s was not stopped", c.Name)
+		}
+		return &container.ExitError{Code: 137}
+	}
+
+	e := NewExecutorWithOptions(Options{Workspace: t.TempDir(), NewRuntime: rt.NewRuntime, Parallel: 2, Quiet: true})
+	result, err := e.RunWithResult(&wf, "", "push")
+	if err == nil {
+		t.Fatalf("expected the run to fail")
+	}
+
+	var cancelled *JobResult
+	for _, job := range result.Jobs {
+		if job.Result == string(resultCancelled) {
+			cancelled = job
+		}
+	}
+	if cancelled == nil {
+		t.Fatalf("expected an instance to be cancelled, got %+v", result.Jobs)
+	}
+	var outcomes []string
+	for _, step := range cancelled.Steps {
+		outcomes = append(outcomes, step.Outcome)
+	}
+	if want := "cancelled|cancelled|success|success|cancelled"; strings.Join(outcomes, "|") != want {
+		t.Fatalf("unexpected step outcomes %q, want %q", outcomes, want)
+	}
+
+	// The cleanup steps run in the stopped container, started again.
+	n := strings.TrimSuffix(strings.TrimPrefix(cancelled.Name, "test ("), ")")
+	c := rt.Container("test-" + n)
+	if c == nil {
+		t.Fatalf("no container for %s: %v", cancelled.Name, rt.Log())
+	}
+	for _, command := range []string{"cleanup " + n, "notify " + n} {
+		if !containsString(c.Commands, command) {
+			t.Errorf("expected %q to run in the cancelled instance, got %q", command, c.Commands)
+		}
+	}
+	for _, command := range []string{"build " + n, "report " + n} {
+		if containsString(c.Commands, command) {
+			t.Errorf("expected %q not to run in the cancelled instance", command)
+		}
+	}
+	if !waitForLog(rt, "start "+c.Name) || !waitForLog(rt, "rm "+c.Name) {
+		t.Errorf("expected container %s to be started again and removed: %v", c.Name, rt.Log())
+	}
+}
+
+func TestExecutor_MaxParallelBoundsMatrixInstances(t *testing.T) {
+	const workflow = `
+name: CI
+on: push
+jobs:
+  test:
+    runs-on: ubuntu-latest
+    strategy:
+      fail-fast: false
+      max-parallel: 2
+      matrix:
+        n: [1, 2, 3, 4, 5]
+    steps:
+      - run: work ${{ matrix.n }}
+`
+	var wf parser.Workflow
+	if err := yaml.Unmarshal([]byte(workflow), &wf); err != nil {
+		t.Fatalf("failed to parse workflow: %v", err)
+	}
+	rt := containertest.New()
+	rt.Exec = func(c *containertest.Container, command string, opts *container.ExecOptions, stdout, stderr io.Writer) error {
+		switch command {
+		case "work 1":
+			return &container.ExitError{Code: 1}
+		case "work 2", "work 3", "work 4", "work 5":
+			time.Sleep(20 * time.Millisecond)
+		}
+		return nil
+	}
+
+	e := NewExecutorWithOptions(Options{Workspace: t.TempDir(), NewRuntime: rt.NewRuntime, Parallel: 5, Quiet: true})
+	result, _ := e.RunWithResult(&wf, "", "push")
+
+	// Without fail-fast the failure cancels nothing.
+	var got []string
+	for _, job := range result.Jobs {
+		got = append(got, job.Result)
+	}
+	if want := "failure|success|success|success|success"; strings.Join(got, "|") != want {
+		t.Fatalf("unexpected instance results %q, want %q", got, want)
+	}
+	if peak := peakContainers(rt.Log()); peak != 2 {
+		t.Fatalf("expected at most 2 instances at once, got %d: %v", peak, rt.Log())
+	}
+}
+
+func TestExecutor_CancelStopsWaitingForServices(t *testing.T) {
+	const workflow = `
+name: CI
+on: push
+jobs:
+  test:
+    runs-on: ubuntu-latest
+    strategy:
+      matrix:
+        db: ['broken:1', 'slow:1']
+    services:
+      db:
+        image: ${{ matrix.db }}
+    steps:
+      - run: test
+`
+	var wf parser.Workflow
+	if err := yaml.Unmarshal([]byte(workflow), &wf); err != nil {
+		t.Fatalf("failed to parse workflow: %v", err)
+	}
+	saved := serviceHealthTimeout
+	serviceHealthTimeout = time.Minute
+	defer func() { serviceHealthTimeout = saved }()
+
+	// broken:1 is only created, and fails its health check, once slow:1
+	// has started.
+	rt := containertest.New()
+	rt.Starting = map[string]bool{"slow:1": true}
+	rt.Unhealthy = map[string]bool{"broken:1": true}
+	rt.Create = func(name string, image string, cfg *container.ContainerConfig) error {
+		if image == "broken:1" && !waitForLog(rt, "create test-2-db slow:1") {
+			t.Errorf("the slow service was not started")
+		}
+		return nil
+	}
+
+	e := NewExecutorWithOptions(Options{Workspace: t.TempDir(), NewRuntime: rt.NewRuntime, Parallel: 2, Quiet: true})
+	start := time.Now()
+	result, _ := e.RunWithResult(&wf, "", "push")
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("the cancelled job waited %s for its service", elapsed)
+	}
+	if got := result.Jobs[0].Result + "|" + result.Jobs[1].Result; got != "failure|cancelled" {
+		t.Fatalf("unexpected instance results %s", got)
+	}
+	if !rt.Container("test-2-db").Removed {
+		t.Fatalf("expected the slow service to be removed: %v", rt.Log())
+	}
+}
+
+func TestExecutor_TruncatedOutputKeepsSecretsMasked(t *testing.T) {
+	const workflow = `
+name: CI
+on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - run: print
+`
+	var wf parser.Workflow
+	if err := yaml.Unmarshal([]byte(workflow), &wf); err != nil {
+		t.Fatalf("failed to parse workflow: %v", err)
+	}
+	const secret = "s3cr3t-value"
+	rt := containertest.New()
+	// The secret straddles the point where the captured output is cut.
+	rt.Exec = func(c *containertest.Container, command string, opts *container.ExecOptions, stdout, stderr io.Writer) error {
+		if command == "print" {
+			io.WriteString(stdout, strings.Repeat("a", maxCapturedOutput-2)+secret+"\n")
+		}
+		return nil
+	}
+
+	e := NewExecutorWithOptions(Options{
+		Workspace:  t.TempDir(),
+		NewRuntime: rt.NewRuntime,
+		Secrets:    map[string]string{"TOKEN": secret},
+		Quiet:      true,
+	})
+	result, err := e.RunWithResult(&wf, "", "push")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	out := result.Jobs[0].Steps[0].Stdout
+	if !strings.HasSuffix(out, "a**\n[output truncated]\n") {
+		t.Fatalf("expected the masked output to be truncated, got ...%q", out[max(len(out)-40, 0):])
+	}
+	if strings.Contains(out, secret[:2]) {
+		t.Fatalf("part of the secret was captured: ...%q", out[max(len(out)-40, 0):])
+	}
+}
@@ -0,0 +1,132 @@
+package runner
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aykay76/ici/internal/container"
+)
+
+// fileCommandDir is where the files steps write GITHUB_OUTPUT, GITHUB_ENV
+// and GITHUB_PATH updates to are created inside job containers.
+var fileCommandDir = path.Join(runnerTemp, "_runner_file_commands")
+
+// fileCommands holds the paths of the files a single step can write to.
+type fileCommands struct {
+	output string
+	env    string
+	path   string
+}
+
+// newFileCommands returns the file command paths for the step at index i.
+func newFileCommands(i int) *fileCommands {
+	base := path.Join(fileCommandDir, fmt.Sprintf("step-%d", i+1))
+	return &fileCommands{
+		output: base + "-output",
+		env:    base + "-env",
+		path:   base + "-path",
+	}
+}
+
+// vars returns the variables pointing a step at its files.
+func (fc *fileCommands) vars() map[string]string {
+	return map[string]string{
+		"GITHUB_OUTPUT": fc.output,
+		"GITHUB_ENV":    fc.env,
+		"GITHUB_PATH":   fc.path,
+	}
+}
+
+// create creates the step's files, empty, inside the container.
+func (fc *fileCommands) create(mgr container.Runtime, containerID string) error {
+	for _, p := range []string{fc.output, fc.env, fc.path} {
+		if err := mgr.WriteFile(containerID, p, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileCommandResult holds what a step wrote to its files.
+type fileCommandResult struct {
+	outputs map[string]string
+	env     map[string]string
+	path    []string
+}
+
+// collect reads and parses the step's files once it has run.
+func (fc *fileCommands) collect(mgr container.Runtime, containerID string) (*fileCommandResult, error) {
+	read := func(p string) (string, error) {
+		data, err := mgr.ReadFile(containerID, p)
+		return string(data), err
+	}
+
+	res := &fileCommandResult{}
+	data, err := read(fc.output)
+	if err != nil {
+		return nil, err
+	}
+	if res.outputs, err = parseFileCommand(data); err != nil {
+		return nil, fmt.Errorf("GITHUB_OUTPUT: %w", err)
+	}
+	if data, err = read(fc.env); err != nil {
+		return nil, err
+	}
+	if res.env, err = parseFileCommand(data); err != nil {
+		return nil, fmt.Errorf("GITHUB_ENV: %w", err)
+	}
+	if data, err = read(fc.path); err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(data, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			res.path = append(res.path, line)
+		}
+	}
+	return res, nil
+}
+
+// parseFileCommand parses the contents of a GITHUB_OUTPUT or GITHUB_ENV
+// file: lines of name=value, or multiline values written as
+//
+//	name<<DELIMITER
+//	...
+//	DELIMITER
+func parseFileCommand(data string) (map[string]string, error) {
+	out := make(map[string]string)
+	lines := strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		eq := strings.Index(line, "=")
+		heredoc := strings.Index(line, "<<")
+		if heredoc > 0 && (eq < 0 || heredoc < eq) {
+			name, delimiter := line[:heredoc], line[heredoc+2:]
+			if delimiter == "" {
+				return nil, fmt.Errorf("line %d: missing delimiter for %s", i+1, name)
+			}
+			var value []string
+			terminated := false
+			for i++; i < len(lines); i++ {
+				if lines[i] == delimiter {
+					terminated = true
+					break
+				}
+				value = append(value, lines[i])
+			}
+			if !terminated {
+				return nil, fmt.Errorf("delimiter %s for %s was not found", delimiter, name)
+			}
+			out[name] = strings.Join(value, "\n")
+			continue
+		}
+		if eq <= 0 {
+			return nil, fmt.Errorf("line %d: invalid format %q", i+1, line)
+		}
+		out[line[:eq]] = line[eq+1:]
+	}
+	return out, nil
+}
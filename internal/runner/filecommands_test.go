@@ -0,0 +1,75 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aykay76/ici/internal/expr"
+	"github.com/aykay76/ici/internal/parser"
+)
+
+func TestParseFileCommand(t *testing.T) {
+	data := "version=1.2.3\nempty=\nnotes<<EOF\nline one\nline=two\nEOF\n\nurl=https://example.com/?a=b\n"
+	got, err := parseFileCommand(data)
+	if err != nil {
+		t.Fatalf("parseFileCommand failed: %v", err)
+	}
+	want := map[string]string{
+		"version": "1.2.3",
+		"empty":   "",
+		"notes":   "line one\nline=two",
+		"url":     "https://example.com/?a=b",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected values: %#v", got)
+	}
+
+	for _, bad := range []string{"novalue\n", "notes<<EOF\nunterminated\n", "=value\n"} {
+		if _, err := parseFileCommand(bad); err == nil {
+			t.Fatalf("expected %q to fail", bad)
+		}
+	}
+}
+
+func TestFileCommands_UpdateLaterSteps(t *testing.T) {
+	ctx := &expr.Context{Values: map[string]interface{}{}}
+	x := &jobExecution{
+		ctx:   ctx,
+		env:   map[string]string{"STAGE": "dev"},
+		steps: make(map[string]interface{}),
+	}
+	ctx.Values["steps"] = x.steps
+
+	x.applyFileCommands(&fileCommandResult{
+		env:  map[string]string{"STAGE": "prod", "VERSION": "1.2.3"},
+		path: []string{"/opt/a", "/opt/b"},
+	})
+	x.recordStep(parser.Step{ID: "version"}, "success", map[string]string{"tag": "v1.2.3"})
+	x.recordStep(parser.Step{Name: "no id"}, "success", map[string]string{"ignored": "x"})
+
+	if x.env["STAGE"] != "prod" || x.env["VERSION"] != "1.2.3" {
+		t.Fatalf("unexpected env: %v", x.env)
+	}
+	if !reflect.DeepEqual(x.path, []string{"/opt/b", "/opt/a"}) {
+		t.Fatalf("unexpected path: %v", x.path)
+	}
+	x.basePath = "/usr/bin"
+	if got := x.pathEnv(); got != "/opt/b:/opt/a:/usr/bin" {
+		t.Fatalf("unexpected PATH: %s", got)
+	}
+
+	stepCtx, _, err := stepContext(ctx, x.env, parser.Step{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := expr.Interpolate("${{ steps.version.outputs.tag }} ${{ steps.version.outcome }} ${{ env.VERSION }}", stepCtx)
+	if err != nil {
+		t.Fatalf("Interpolate failed: %v", err)
+	}
+	if got != "v1.2.3 success 1.2.3" {
+		t.Fatalf("unexpected result: %q", got)
+	}
+	if len(x.steps) != 1 {
+		t.Fatalf("steps without an id should not be recorded: %v", x.steps)
+	}
+}
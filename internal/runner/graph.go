@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aykay76/ici/internal/parser"
+)
+
+// jobGraph holds the dependency relationships declared through `needs:`.
+type jobGraph struct {
+	// needs maps a job ID to the jobs it depends on.
+	needs map[string][]string
+	// dependents maps a job ID to the jobs that depend on it.
+	dependents map[string][]string
+	// order lists every job ID in a valid topological order.
+	order []string
+}
+
+// buildJobGraph builds the dependency graph for the given jobs. It returns an
+// error if a job needs an unknown job or if the dependencies contain a cycle.
+func buildJobGraph(jobs map[string]parser.Job) (*jobGraph, error) {
+	g := &jobGraph{
+		needs:      make(map[string][]string, len(jobs)),
+		dependents: make(map[string][]string, len(jobs)),
+	}
+
+	ids := make([]string, 0, len(jobs))
+	for id := range jobs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	inDegree := make(map[string]int, len(jobs))
+	for _, id := range ids {
+		job := jobs[id]
+		for _, need := range job.GetNeeds() {
+			if _, ok := jobs[need]; !ok {
+				return nil, fmt.Errorf("job '%s' needs unknown job '%s'", id, need)
+			}
+			if need == id {
+				return nil, fmt.Errorf("job '%s' cannot depend on itself", id)
+			}
+			g.needs[id] = append(g.needs[id], need)
+			g.dependents[need] = append(g.dependents[need], id)
+			inDegree[id]++
+		}
+	}
+
+	// Kahn's algorithm; the ready set is kept sorted so the order is stable
+	// between runs.
+	ready := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if inDegree[id] == 0 {
+			ready = append(ready, id)
+		}
+	}
+	for len(ready) > 0 {
+		id := ready[0]
+		ready = ready[1:]
+		g.order = append(g.order, id)
+		for _, dep := range g.dependents[id] {
+			inDegree[dep]--
+			if inDegree[dep] == 0 {
+				ready = append(ready, dep)
+				sort.Strings(ready)
+			}
+		}
+	}
+
+	if len(g.order) != len(ids) {
+		var cyclic []string
+		for _, id := range ids {
+			if inDegree[id] > 0 {
+				cyclic = append(cyclic, id)
+			}
+		}
+		return nil, fmt.Errorf("dependency cycle detected between jobs: %s", strings.Join(cyclic, ", "))
+	}
+
+	return g, nil
+}
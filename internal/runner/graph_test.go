@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aykay76/ici/internal/parser"
+)
+
+func TestBuildJobGraph_TopologicalOrder(t *testing.T) {
+	jobs := map[string]parser.Job{
+		"deploy": {Needs: []interface{}{"test", "lint"}},
+		"test":   {Needs: "build"},
+		"lint":   {},
+		"build":  {},
+	}
+
+	g, err := buildJobGraph(jobs)
+	if err != nil {
+		t.Fatalf("buildJobGraph failed: %v", err)
+	}
+
+	want := []string{"build", "lint", "test", "deploy"}
+	if !reflect.DeepEqual(g.order, want) {
+		t.Fatalf("expected order %v, got %v", want, g.order)
+	}
+	if !reflect.DeepEqual(g.needs["deploy"], []string{"test", "lint"}) {
+		t.Fatalf("unexpected needs for deploy: %v", g.needs["deploy"])
+	}
+}
+
+func TestBuildJobGraph_UnknownJob(t *testing.T) {
+	jobs := map[string]parser.Job{
+		"test": {Needs: "build"},
+	}
+
+	_, err := buildJobGraph(jobs)
+	if err == nil || !strings.Contains(err.Error(), "unknown job 'build'") {
+		t.Fatalf("expected unknown job error, got %v", err)
+	}
+}
+
+func TestBuildJobGraph_Cycle(t *testing.T) {
+	jobs := map[string]parser.Job{
+		"a":    {Needs: "c"},
+		"b":    {Needs: "a"},
+		"c":    {Needs: "b"},
+		"root": {},
+	}
+
+	_, err := buildJobGraph(jobs)
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "a, b, c") {
+		t.Fatalf("expected cyclic jobs to be listed, got %v", err)
+	}
+}
+
+func TestFirstUnsuccessful(t *testing.T) {
+	results := map[string]jobResult{
+		"build": resultSuccess,
+		"lint":  resultFailure,
+	}
+
+	if got := firstUnsuccessful([]string{"build"}, results); got != "" {
+		t.Fatalf("expected no blocker, got %q", got)
+	}
+	if got := firstUnsuccessful([]string{"build", "lint"}, results); got != "lint" {
+		t.Fatalf("expected lint to block, got %q", got)
+	}
+}
@@ -0,0 +1,123 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aykay76/ici/internal/parser"
+)
+
+// ResolveInputs validates the values given for a workflow's inputs against
+// their declared types and choices, and applies the defaults of the inputs
+// that were not given. It returns the inputs context, in which boolean and
+// number inputs are typed, and the inputs as strings, as GitHub reports them
+// in the event payload.
+func ResolveInputs(declared map[string]parser.Input, given map[string]string) (map[string]interface{}, map[string]string, error) {
+	var unknown []string
+	for name := range given {
+		if _, ok := declared[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, nil, fmt.Errorf("unexpected input(s): %s", strings.Join(unknown, ", "))
+	}
+
+	names := make([]string, 0, len(declared))
+	for name := range declared {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := make(map[string]interface{}, len(declared))
+	raw := make(map[string]string, len(declared))
+	for _, name := range names {
+		in := declared[name]
+		s, ok := given[name]
+		if !ok {
+			if in.Required && in.Default == "" {
+				return nil, nil, fmt.Errorf("input %s is required", name)
+			}
+			s = in.Default
+		}
+		v, err := inputValue(in, s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("input %s: %w", name, err)
+		}
+		values[name] = v
+		raw[name] = s
+	}
+	return values, raw, nil
+}
+
+// inputValue converts the string value of an input to its declared type.
+func inputValue(in parser.Input, s string) (interface{}, error) {
+	switch in.Type {
+	case "", "string", "environment":
+		return s, nil
+	case "boolean":
+		switch s {
+		case "", "false":
+			return false, nil
+		case "true":
+			return true, nil
+		}
+		return nil, fmt.Errorf("%q is not a boolean (use true or false)", s)
+	case "number":
+		if s == "" {
+			return s, nil
+		}
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", s)
+		}
+		return n, nil
+	case "choice":
+		if s == "" || containsString(in.Options, s) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("%q is not one of the options: %s", s, strings.Join(in.Options, ", "))
+	}
+	return nil, fmt.Errorf("unsupported type %q", in.Type)
+}
+
+// eventInputs returns the string values of the inputs of a
+// workflow_dispatch payload.
+func eventInputs(event map[string]interface{}) map[string]string {
+	m, _ := event["inputs"].(map[string]interface{})
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = fmt.Sprint(v)
+	}
+	return out
+}
+
+// resolveInputs validates the inputs of a workflow_dispatch run, given in
+// the event payload or overridden by values, and records them in the inputs
+// context and the payload.
+func (r *workflowRun) resolveInputs(values map[string]string) error {
+	given := eventInputs(r.event)
+	for k, v := range values {
+		given[k] = v
+	}
+	var declared map[string]parser.Input
+	if d := r.workflow.On.WorkflowDispatch; d != nil {
+		declared = d.Inputs
+	}
+	inputs, raw, err := ResolveInputs(declared, given)
+	if err != nil {
+		return fmt.Errorf("invalid workflow_dispatch inputs: %w", err)
+	}
+	// the payload may be shared with other runs, so it is copied
+	event := make(map[string]interface{}, len(r.event)+1)
+	for k, v := range r.event {
+		event[k] = v
+	}
+	event["inputs"] = stringMap(raw)
+	r.event = event
+	r.inputs = inputs
+	return nil
+}
@@ -0,0 +1,74 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aykay76/ici/internal/expr"
+	"github.com/aykay76/ici/internal/git"
+	"github.com/aykay76/ici/internal/parser"
+)
+
+var releaseInputs = map[string]parser.Input{
+	"version": {Required: true},
+	"channel": {Type: "choice", Options: []string{"stable", "beta"}, Default: "stable"},
+	"dry-run": {Type: "boolean", Default: "false"},
+	"retries": {Type: "number", Default: "3"},
+	"target":  {Type: "environment"},
+}
+
+func TestResolveInputs(t *testing.T) {
+	values, raw, err := ResolveInputs(releaseInputs, map[string]string{"version": "1.2.0", "dry-run": "true"})
+	if err != nil {
+		t.Fatalf("ResolveInputs failed: %v", err)
+	}
+	if values["version"] != "1.2.0" || values["channel"] != "stable" || values["dry-run"] != true || values["retries"] != 3.0 || values["target"] != "" {
+		t.Fatalf("unexpected values: %v", values)
+	}
+	if raw["dry-run"] != "true" || raw["retries"] != "3" {
+		t.Fatalf("unexpected raw values: %v", raw)
+	}
+
+	tests := []struct {
+		given map[string]string
+		want  string
+	}{
+		{map[string]string{}, "version is required"},
+		{map[string]string{"version": "1", "channel": "nightly"}, "not one of the options"},
+		{map[string]string{"version": "1", "dry-run": "yes"}, "not a boolean"},
+		{map[string]string{"version": "1", "retries": "many"}, "not a number"},
+		{map[string]string{"version": "1", "unknown": "x"}, "unexpected input(s): unknown"},
+	}
+	for _, tt := range tests {
+		_, _, err := ResolveInputs(releaseInputs, tt.given)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ResolveInputs(%v) error = %v, want %q", tt.given, err, tt.want)
+		}
+	}
+}
+
+func TestInputsContexts(t *testing.T) {
+	wf := &parser.Workflow{On: parser.Triggers{WorkflowDispatch: &parser.WorkflowDispatch{Inputs: releaseInputs}}}
+	event := map[string]interface{}{"inputs": map[string]interface{}{"version": "1.0.0", "channel": "beta"}}
+	run := &workflowRun{workflow: wf, eventName: "workflow_dispatch", event: event, repo: &git.RepoInfo{}}
+	if err := run.resolveInputs(map[string]string{"version": "1.2.0", "dry-run": "true"}); err != nil {
+		t.Fatalf("resolveInputs failed: %v", err)
+	}
+	if event["inputs"].(map[string]interface{})["version"] != "1.0.0" {
+		t.Fatalf("expected the given payload to be left unchanged")
+	}
+
+	ctx := run.newJobContext(&jobRun{id: "release"}, nil)
+	got, err := expr.Interpolate("${{ inputs.version }} ${{ inputs.channel }} ${{ github.event.inputs.dry-run }} ${{ inputs.dry-run && 'dry' || 'live' }}", ctx)
+	if err != nil {
+		t.Fatalf("Interpolate failed: %v", err)
+	}
+	if got != "1.2.0 beta true dry" {
+		t.Fatalf("unexpected result: %q", got)
+	}
+
+	v, err := expr.Evaluate("github.event.inputs.dry-run == 'true' && inputs.dry-run == true", ctx)
+	if err != nil || v != true {
+		t.Fatalf("expected inputs to be strings in the payload and typed in the context: %v, %v", v, err)
+	}
+}
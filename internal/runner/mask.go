@@ -0,0 +1,136 @@
+package runner
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// masker holds the values, secrets and those registered with ::add-mask::,
+// that are replaced by *** in job output.
+type masker struct {
+	mu     sync.Mutex
+	values []string
+}
+
+// add registers a value to mask. The lines of a multiline value are also
+// masked on their own. Empty values are ignored.
+func (m *masker) add(value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	candidates := []string{value}
+	if strings.Contains(value, "\n") {
+		candidates = append(candidates, strings.Split(value, "\n")...)
+	}
+	for _, v := range candidates {
+		v = strings.TrimSpace(v)
+		if v == "" || containsString(m.values, v) {
+			continue
+		}
+		m.values = append(m.values, v)
+	}
+	// longest first, so that a value containing another one is masked whole
+	sort.SliceStable(m.values, func(i, j int) bool { return len(m.values[i]) > len(m.values[j]) })
+}
+
+// mask returns s with every registered value replaced by ***.
+func (m *masker) mask(s string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range m.values {
+		s = strings.ReplaceAll(s, v, "***")
+	}
+	return s
+}
+
+// pending returns how many bytes at the end of s could be the start of a
+// registered value and must be held back until more output arrives.
+func (m *masker) pending(s string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	held := 0
+	for _, v := range m.values {
+		for n := min(len(v)-1, len(s)); n > held; n-- {
+			if strings.HasSuffix(s, v[:n]) {
+				held = n
+				break
+			}
+		}
+	}
+	return held
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// maskWriter replaces masked values in a stream of output before forwarding
+// it to out. Output that may be the beginning of a masked value is held back
+// until it can be decided, so values split across writes or spanning lines
+// are masked too.
+type maskWriter struct {
+	out   io.Writer
+	masks *masker
+	buf   string
+}
+
+// newMaskWriter creates a maskWriter that writes to out.
+func newMaskWriter(out io.Writer, masks *masker) *maskWriter {
+	return &maskWriter{out: out, masks: masks}
+}
+
+// Write masks p, together with any output held back, and forwards what can
+// no longer be part of a masked value.
+func (w *maskWriter) Write(p []byte) (int, error) {
+	w.buf = w.masks.mask(w.buf + string(p))
+	n := len(w.buf) - w.masks.pending(w.buf)
+	if n > 0 {
+		if _, err := io.WriteString(w.out, w.buf[:n]); err != nil {
+			return len(p), err
+		}
+		w.buf = w.buf[n:]
+	}
+	return len(p), nil
+}
+
+// Flush forwards any output held back.
+func (w *maskWriter) Flush() error {
+	if w.buf == "" {
+		return nil
+	}
+	out := w.masks.mask(w.buf)
+	w.buf = ""
+	_, err := io.WriteString(w.out, out)
+	return err
+}
+
+// maskedError is an error whose message has masked values replaced. It
+// unwraps to the original error so that errors.Is and errors.As still work.
+type maskedError struct {
+	msg string
+	err error
+}
+
+func (e *maskedError) Error() string { return e.msg }
+
+func (e *maskedError) Unwrap() error { return e.err }
+
+// maskError returns err with the values registered with masks replaced in
+// its message. Errors of the container runtime can quote the configuration
+// of a container, including its env.
+func maskError(err error, masks *masker) error {
+	if err == nil || masks == nil {
+		return err
+	}
+	msg := masks.mask(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return &maskedError{msg: msg, err: err}
+}
@@ -0,0 +1,43 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMaskWriter_MasksAcrossWrites(t *testing.T) {
+	masks := &masker{}
+	masks.add("s3cret")
+	masks.add("multi\nline")
+	masks.add("abcab")
+
+	var out bytes.Buffer
+	w := newMaskWriter(&out, masks)
+	for _, chunk := range []string{"token=s3", "cret\n", "value: multi", "\nline end\n", "abc", "ab!\n", "tail s3c"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// each line of a multiline value is masked on its own, like GitHub does
+	if got := out.String(); got != "token=***\nvalue: ***\n*** end\n***!\ntail " {
+		t.Fatalf("unexpected output before flush: %q", got)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "token=***\nvalue: ***\n*** end\n***!\ntail s3c" {
+		t.Fatalf("unexpected output: %q", got)
+	}
+}
+
+func TestMasker_MasksLinesOfMultilineValues(t *testing.T) {
+	masks := &masker{}
+	masks.add("first-line\nsecond-line")
+	masks.add("   ")
+	if got := masks.mask("a first-line b second-line"); got != "a *** b ***" {
+		t.Fatalf("unexpected result: %q", got)
+	}
+	if len(masks.values) != 3 {
+		t.Fatalf("expected blank values to be ignored: %q", masks.values)
+	}
+}
@@ -0,0 +1,281 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aykay76/ici/internal/expr"
+	"github.com/aykay76/ici/internal/parser"
+)
+
+// matrixCombination is one combination of matrix values. keys keeps the
+// order the values are listed in when naming the job instance.
+type matrixCombination struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newMatrixCombination() *matrixCombination {
+	return &matrixCombination{values: make(map[string]interface{})}
+}
+
+func (c *matrixCombination) set(key string, value interface{}) {
+	if _, ok := c.values[key]; !ok {
+		c.keys = append(c.keys, key)
+	}
+	c.values[key] = value
+}
+
+func (c *matrixCombination) clone() *matrixCombination {
+	out := newMatrixCombination()
+	for _, k := range c.keys {
+		out.set(k, c.values[k])
+	}
+	return out
+}
+
+// matches reports whether every key in partial has the same value in c.
+func (c *matrixCombination) matches(partial map[string]interface{}) bool {
+	for k, v := range partial {
+		cv, ok := c.values[k]
+		if !ok || !matrixValueEqual(cv, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// name returns the display name of a job instance, e.g. "test (1.22, ubuntu-latest)".
+func (c *matrixCombination) name(base string) string {
+	parts := make([]string, 0, len(c.keys))
+	for _, k := range c.keys {
+		parts = append(parts, matrixValueString(c.values[k]))
+	}
+	return fmt.Sprintf("%s (%s)", base, strings.Join(parts, ", "))
+}
+
+// expandMatrix returns every combination of a matrix following GitHub's
+// rules: the cartesian product of the dimensions, minus the exclude entries,
+// extended by the include entries.
+func expandMatrix(m *parser.Matrix, ctx *expr.Context) ([]*matrixCombination, error) {
+	m, err := resolveMatrix(m, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var combos []*matrixCombination
+	if len(m.Dimensions) > 0 {
+		combos = []*matrixCombination{newMatrixCombination()}
+		for _, dim := range m.Dimensions {
+			if len(dim.Values) == 0 {
+				return nil, fmt.Errorf("matrix key %s has no values", dim.Key)
+			}
+			next := make([]*matrixCombination, 0, len(combos)*len(dim.Values))
+			for _, c := range combos {
+				for _, v := range dim.Values {
+					nc := c.clone()
+					nc.set(dim.Key, v)
+					next = append(next, nc)
+				}
+			}
+			combos = next
+		}
+	}
+
+	kept := combos[:0]
+	for _, c := range combos {
+		excluded := false
+		for _, ex := range m.Exclude {
+			if c.matches(ex) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			kept = append(kept, c)
+		}
+	}
+	combos = kept
+
+	original := make(map[string]bool, len(m.Dimensions))
+	for _, dim := range m.Dimensions {
+		original[dim.Key] = true
+	}
+	base := len(combos)
+	for _, inc := range m.Include {
+		// Values of the original matrix keys select the combinations the
+		// entry extends; the remaining values are added to them.
+		selector := make(map[string]interface{})
+		for k, v := range inc {
+			if original[k] {
+				selector[k] = v
+			}
+		}
+		extended := false
+		for _, c := range combos[:base] {
+			if !c.matches(selector) {
+				continue
+			}
+			for _, k := range sortedKeys(inc) {
+				if !original[k] {
+					c.set(k, inc[k])
+				}
+			}
+			extended = true
+		}
+		if !extended {
+			nc := newMatrixCombination()
+			for _, k := range sortedKeys(inc) {
+				nc.set(k, inc[k])
+			}
+			combos = append(combos, nc)
+		}
+	}
+
+	if len(combos) == 0 {
+		return nil, fmt.Errorf("matrix does not produce any combinations")
+	}
+	return combos, nil
+}
+
+// resolveMatrix evaluates the expressions a matrix may be given as, returning
+// a matrix that only holds literal values.
+func resolveMatrix(m *parser.Matrix, ctx *expr.Context) (*parser.Matrix, error) {
+	if m.Expression != "" {
+		v, err := evaluateValue(m.Expression, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate matrix: %w", err)
+		}
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("matrix expression %q did not evaluate to an object", m.Expression)
+		}
+		resolved := &parser.Matrix{}
+		for _, k := range sortedKeys(obj) {
+			switch k {
+			case "include", "exclude":
+				list, err := toCombinationList(k, obj[k])
+				if err != nil {
+					return nil, err
+				}
+				if k == "include" {
+					resolved.Include = list
+				} else {
+					resolved.Exclude = list
+				}
+			default:
+				values, ok := obj[k].([]interface{})
+				if !ok {
+					return nil, fmt.Errorf("matrix key %s must be an array", k)
+				}
+				resolved.Dimensions = append(resolved.Dimensions, parser.MatrixDimension{Key: k, Values: values})
+			}
+		}
+		return resolved, nil
+	}
+
+	resolved := &parser.Matrix{Include: m.Include, Exclude: m.Exclude}
+	for _, dim := range m.Dimensions {
+		if dim.Expression != "" {
+			v, err := evaluateValue(dim.Expression, ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate matrix key %s: %w", dim.Key, err)
+			}
+			values, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("matrix key %s did not evaluate to an array", dim.Key)
+			}
+			dim = parser.MatrixDimension{Key: dim.Key, Values: values}
+		}
+		resolved.Dimensions = append(resolved.Dimensions, dim)
+	}
+	return resolved, nil
+}
+
+// evaluateValue evaluates a value that consists of a single ${{ }}
+// expression, returning the value itself rather than its string form.
+// Values with text around their expressions are interpolated.
+func evaluateValue(s string, ctx *expr.Context) (interface{}, error) {
+	if !expr.ContainsExpression(s) {
+		return s, nil
+	}
+	e, err := expr.TemplateExpression(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	return expr.Evaluate(e, ctx)
+}
+
+func toCombinationList(key string, v interface{}) ([]map[string]interface{}, error) {
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("matrix %s must be an array", key)
+	}
+	out := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("matrix %s entries must be objects", key)
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// filterCombinations keeps the combinations matching every key=value pair
+// of filter. Keys that are not part of the matrix are ignored so the same
+// filter can be applied to every job of a workflow.
+func filterCombinations(combos []*matrixCombination, filter map[string]string) ([]*matrixCombination, error) {
+	applicable := make(map[string]string)
+	for k, v := range filter {
+		for _, c := range combos {
+			if _, ok := c.values[k]; ok {
+				applicable[k] = v
+				break
+			}
+		}
+	}
+	if len(applicable) == 0 {
+		return combos, nil
+	}
+
+	var out []*matrixCombination
+	for _, c := range combos {
+		match := true
+		for k, v := range applicable {
+			if cv, ok := c.values[k]; !ok || matrixValueString(cv) != v {
+				match = false
+				break
+			}
+		}
+		if match {
+			out = append(out, c)
+		}
+	}
+	if len(out) == 0 {
+		pairs := make([]string, 0, len(applicable))
+		for _, k := range sortedKeys(applicable) {
+			pairs = append(pairs, k+"="+applicable[k])
+		}
+		return nil, fmt.Errorf("no matrix combination matches %s", strings.Join(pairs, ", "))
+	}
+	return out, nil
+}
+
+func matrixValueString(v interface{}) string {
+	return expr.ToString(v)
+}
+
+func matrixValueEqual(a, b interface{}) bool {
+	return matrixValueString(a) == matrixValueString(b)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,144 @@
+package runner
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aykay76/ici/internal/expr"
+	"github.com/aykay76/ici/internal/parser"
+)
+
+func comboNames(combos []*matrixCombination) []string {
+	names := make([]string, len(combos))
+	for i, c := range combos {
+		names[i] = c.name("test")
+	}
+	return names
+}
+
+func TestExpandMatrix_Product(t *testing.T) {
+	m := &parser.Matrix{
+		Dimensions: []parser.MatrixDimension{
+			{Key: "go", Values: []interface{}{"1.24", "1.25"}},
+			{Key: "os", Values: []interface{}{"ubuntu-latest", "ubuntu-22.04"}},
+		},
+	}
+
+	combos, err := expandMatrix(m, &expr.Context{})
+	if err != nil {
+		t.Fatalf("expandMatrix failed: %v", err)
+	}
+
+	want := []string{
+		"test (1.24, ubuntu-latest)",
+		"test (1.24, ubuntu-22.04)",
+		"test (1.25, ubuntu-latest)",
+		"test (1.25, ubuntu-22.04)",
+	}
+	if got := comboNames(combos); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestExpandMatrix_IncludeExclude(t *testing.T) {
+	m := &parser.Matrix{
+		Dimensions: []parser.MatrixDimension{
+			{Key: "fruit", Values: []interface{}{"apple", "pear"}},
+			{Key: "animal", Values: []interface{}{"cat", "dog"}},
+		},
+		Exclude: []map[string]interface{}{
+			{"fruit": "pear", "animal": "cat"},
+		},
+		Include: []map[string]interface{}{
+			{"color": "green"},
+			{"color": "pink", "animal": "cat"},
+			{"fruit": "apple", "shape": "circle"},
+			{"fruit": "banana"},
+			{"fruit": "banana", "animal": "cat"},
+		},
+	}
+
+	combos, err := expandMatrix(m, &expr.Context{})
+	if err != nil {
+		t.Fatalf("expandMatrix failed: %v", err)
+	}
+
+	want := []string{
+		"test (apple, cat, pink, circle)",
+		"test (apple, dog, green, circle)",
+		"test (pear, dog, green)",
+		"test (banana)",
+		"test (cat, banana)",
+	}
+	if got := comboNames(combos); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestExpandMatrix_Expression(t *testing.T) {
+	ctx := &expr.Context{
+		Values: map[string]interface{}{
+			"inputs": map[string]interface{}{"versions": `["1.24","1.25"]`},
+		},
+	}
+	m := &parser.Matrix{
+		Dimensions: []parser.MatrixDimension{
+			{Key: "go", Expression: "${{ fromJSON(inputs.versions) }}"},
+		},
+	}
+
+	combos, err := expandMatrix(m, ctx)
+	if err != nil {
+		t.Fatalf("expandMatrix failed: %v", err)
+	}
+	want := []string{"test (1.24)", "test (1.25)"}
+	if got := comboNames(combos); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestEvaluateValue(t *testing.T) {
+	ctx := &expr.Context{
+		Values: map[string]interface{}{
+			"inputs": map[string]interface{}{"versions": `["1.24","1.25"]`, "os": "linux"},
+		},
+	}
+	tests := map[string]interface{}{
+		"${{ fromJSON(inputs.versions) }}":              []interface{}{"1.24", "1.25"},
+		"${{ inputs.os }}-${{ fromJSON('\"amd64\"') }}": "linux-amd64",
+		"${{ inputs.os }} }}":                           "linux }}",
+		"plain":                                         "plain",
+	}
+	for s, want := range tests {
+		got, err := evaluateValue(s, ctx)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("evaluateValue(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+}
+
+func TestFilterCombinations(t *testing.T) {
+	m := &parser.Matrix{
+		Dimensions: []parser.MatrixDimension{
+			{Key: "go", Values: []interface{}{"1.24", "1.25"}},
+			{Key: "os", Values: []interface{}{"ubuntu-latest", "ubuntu-22.04"}},
+		},
+	}
+	combos, err := expandMatrix(m, &expr.Context{})
+	if err != nil {
+		t.Fatalf("expandMatrix failed: %v", err)
+	}
+
+	got, err := filterCombinations(combos, map[string]string{"go": "1.25", "os": "ubuntu-22.04", "arch": "arm64"})
+	if err != nil {
+		t.Fatalf("filterCombinations failed: %v", err)
+	}
+	if names := comboNames(got); len(names) != 1 || names[0] != "test (1.25, ubuntu-22.04)" {
+		t.Fatalf("unexpected filtered combinations: %v", names)
+	}
+
+	if _, err := filterCombinations(combos, map[string]string{"go": "1.19"}); err == nil || !strings.Contains(err.Error(), "go=1.19") {
+		t.Fatalf("expected no-match error, got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package runner
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// prefixWriter prefixes every line written to it before forwarding it to the
+// underlying writer. Lines are buffered until complete so that output from
+// concurrently running jobs never interleaves within a single line. Writers
+// sharing a destination must share the same mutex.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix []byte
+	buf    []byte
+}
+
+// newPrefixWriter creates a prefixWriter that writes to out, holding mu while
+// each complete line is written.
+func newPrefixWriter(out io.Writer, mu *sync.Mutex, prefix string) *prefixWriter {
+	return &prefixWriter{
+		mu:     mu,
+		out:    out,
+		prefix: []byte(prefix),
+	}
+}
+
+// Write buffers p and forwards every complete line with the prefix attached.
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := w.writeLine(w.buf[:i+1]); err != nil {
+			return len(p), err
+		}
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes any buffered partial line, terminating it with a newline.
+func (w *prefixWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	line := append(w.buf, '\n')
+	w.buf = nil
+	return w.writeLine(line)
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.out.Write(w.prefix); err != nil {
+		return err
+	}
+	_, err := w.out.Write(line)
+	return err
+}
+
+// maxCapturedOutput bounds how much of each output stream of a step is kept
+// in its StepResult.
+const maxCapturedOutput = 1 << 20
+
+// captureWriter forwards output to out and, while capturing, keeps a copy of
+// it for the result of the step that is running.
+type captureWriter struct {
+	out       io.Writer
+	mu        sync.Mutex
+	capturing bool
+	buf       bytes.Buffer
+	truncated bool
+}
+
+// newCaptureWriter creates a captureWriter that writes to out.
+func newCaptureWriter(out io.Writer) *captureWriter {
+	return &captureWriter{out: out}
+}
+
+// Write keeps a copy of p while capturing and forwards it to out.
+func (w *captureWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	if w.capturing {
+		if room := maxCapturedOutput - w.buf.Len(); room < len(p) {
+			w.buf.Write(p[:max(room, 0)])
+			w.truncated = true
+		} else {
+			w.buf.Write(p)
+		}
+	}
+	w.mu.Unlock()
+	return w.out.Write(p)
+}
+
+// start begins capturing, discarding what an earlier step captured.
+func (w *captureWriter) start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Reset()
+	w.truncated = false
+	w.capturing = true
+}
+
+// stop ends capturing and returns the captured output.
+func (w *captureWriter) stop() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.capturing = false
+	out := w.buf.String()
+	if w.truncated {
+		out += "\n[output truncated]\n"
+	}
+	return out
+}
+
+// gateWriter forwards output to out unless it is muted, which hides the
+// output of steps from the terminal when it is only captured.
+type gateWriter struct {
+	out   io.Writer
+	muted atomic.Bool
+}
+
+// Write forwards p to out unless the gate is muted.
+func (w *gateWriter) Write(p []byte) (int, error) {
+	if w.muted.Load() {
+		return len(p), nil
+	}
+	return w.out.Write(p)
+}
+
+// stepOutput captures the output of the step that is running for its
+// StepResult. Unless tee is set the output is only captured, and the gates
+// keep it from the terminal while the step runs.
+type stepOutput struct {
+	stdout, stderr *captureWriter
+	gates          []*gateWriter
+	tee            bool
+	masks          *masker
+	// flush forwards output the job's writers hold back.
+	flush func()
+}
+
+// begin starts capturing the output of a step.
+func (o *stepOutput) begin() {
+	o.stdout.start()
+	o.stderr.start()
+	for _, g := range o.gates {
+		g.muted.Store(!o.tee)
+	}
+}
+
+// end stops capturing and returns the step's output with secrets masked,
+// including the values the step itself registered with ::add-mask::.
+func (o *stepOutput) end() (stdout, stderr string) {
+	o.flush()
+	for _, g := range o.gates {
+		g.muted.Store(false)
+	}
+	return o.masks.mask(o.stdout.stop()), o.masks.mask(o.stderr.stop())
+}
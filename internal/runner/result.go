@@ -0,0 +1,116 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aykay76/ici/internal/container"
+)
+
+// RunResult is the outcome of a workflow run: the result of every job
+// instance, in the order the jobs are declared to depend on each other.
+type RunResult struct {
+	Workflow string        `json:"workflow"`
+	Event    string        `json:"event"`
+	Result   string        `json:"result"`
+	Started  time.Time     `json:"started,omitzero"`
+	Duration time.Duration `json:"durationNs"`
+	Jobs     []*JobResult  `json:"jobs"`
+	// Annotations holds the annotations reported by every job.
+	Annotations []Annotation `json:"annotations,omitempty"`
+}
+
+// JobResult is the outcome of a job, or of a single matrix instance of a
+// job.
+type JobResult struct {
+	ID string `json:"id"`
+	// Name is the display name, which includes the matrix values for
+	// matrix instances.
+	Name   string                 `json:"name"`
+	Matrix map[string]interface{} `json:"matrix,omitempty"`
+	// Result is success, failure, skipped or cancelled.
+	Result   string        `json:"result"`
+	Started  time.Time     `json:"started,omitzero"`
+	Duration time.Duration `json:"durationNs"`
+	// Error describes why the job failed.
+	Error string `json:"error,omitempty"`
+	// SkipReason describes why the job did not run.
+	SkipReason  string        `json:"skipReason,omitempty"`
+	Steps       []*StepResult `json:"steps,omitempty"`
+	Annotations []Annotation  `json:"annotations,omitempty"`
+	// Jobs holds the jobs of the reusable workflow the job called.
+	Jobs []*JobResult `json:"jobs,omitempty"`
+}
+
+// StepResult is the outcome of a step.
+type StepResult struct {
+	// Number is the position of the step in its job, starting at 1.
+	Number int    `json:"number"`
+	ID     string `json:"id,omitempty"`
+	Name   string `json:"name"`
+	// Outcome is success, failure, skipped or cancelled.
+	Outcome string `json:"outcome"`
+	// ExitCode is the exit code of the step's command. It is 0 for steps
+	// that failed before or without running a command.
+	ExitCode int           `json:"exitCode"`
+	Started  time.Time     `json:"started,omitzero"`
+	Duration time.Duration `json:"durationNs"`
+	// Stdout and Stderr hold the step's output, with secrets masked.
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+	// Error describes why the step failed.
+	Error string `json:"error,omitempty"`
+	// SkipReason describes why the step did not run.
+	SkipReason  string       `json:"skipReason,omitempty"`
+	Annotations []Annotation `json:"annotations,omitempty"`
+}
+
+// exitCode returns the exit code a command failed with, or 0 when err does
+// not come from the command's exit status.
+func exitCode(err error) int {
+	var exit *container.ExitError
+	if errors.As(err, &exit) {
+		return exit.Code
+	}
+	return 0
+}
+
+// errorString returns the message of err, or an empty string for nil.
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// skipReason describes why an `if:` condition prevented a job or step from
+// running. An empty condition only skips when something before it failed.
+func skipReason(condition string, previous string) string {
+	if strings.TrimSpace(condition) == "" {
+		return previous
+	}
+	return fmt.Sprintf("if: %s evaluated to false", strings.TrimSpace(condition))
+}
+
+// addJobResults records the results of the instances of a job.
+func (r *workflowRun) addJobResults(jobID string, results ...*JobResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.jobResults == nil {
+		r.jobResults = make(map[string][]*JobResult)
+	}
+	r.jobResults[jobID] = append(r.jobResults[jobID], results...)
+}
+
+// results returns the recorded job results in the order of the graph.
+func (r *workflowRun) results(graph *jobGraph) []*JobResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*JobResult
+	for _, jobID := range graph.order {
+		out = append(out, r.jobResults[jobID]...)
+	}
+	return out
+}
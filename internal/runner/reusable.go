@@ -0,0 +1,329 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aykay76/ici/internal/expr"
+	"github.com/aykay76/ici/internal/git"
+	"github.com/aykay76/ici/internal/parser"
+)
+
+// maxCallDepth is how deeply reusable workflows may call each other.
+const maxCallDepth = 10
+
+// githubURL is where remote reusable workflows are fetched from. Tests
+// point it at local repositories.
+var githubURL = "https://github.com"
+
+// runCalledWorkflow runs the reusable workflow a job calls with `uses:`.
+// Its jobs run as nested jobs of the workflow run, and the outputs it
+// declares become the outputs of the calling job. Cancelling runCtx cancels
+// the called jobs, and errJobCancelled is returned.
+func (e *Executor) runCalledWorkflow(runCtx context.Context, run *workflowRun, jr *jobRun) error {
+	if len(jr.job.Steps) > 0 {
+		return errors.New("a job that calls a reusable workflow cannot have steps")
+	}
+	if run.depth >= maxCallDepth {
+		return fmt.Errorf("reusable workflows are nested more than %d levels deep", maxCallDepth)
+	}
+
+	workflow, source, err := e.resolveCalledWorkflow(run, jr.job.Uses)
+	if err != nil {
+		return err
+	}
+	call := workflow.On.WorkflowCall
+	if call == nil {
+		if !workflow.TriggeredBy("workflow_call") {
+			return fmt.Errorf("%s does not trigger on workflow_call", jr.job.Uses)
+		}
+		call = &parser.WorkflowCall{}
+	}
+
+	ctx := run.newJobContext(jr, nil)
+	with, err := expr.InterpolateMap(jr.job.With, ctx)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate with: %w", err)
+	}
+	inputs, _, err := ResolveInputs(call.Inputs, with)
+	if err != nil {
+		return fmt.Errorf("invalid inputs for %s: %w", jr.job.Uses, err)
+	}
+	secrets, err := callSecrets(run, jr.job.Secrets, call.Secrets, ctx)
+	if err != nil {
+		return fmt.Errorf("invalid secrets for %s: %w", jr.job.Uses, err)
+	}
+
+	graph, err := buildJobGraph(workflow.Jobs)
+	if err != nil {
+		return fmt.Errorf("invalid job dependencies in %s: %w", jr.job.Uses, err)
+	}
+
+	if e.verbose {
+		e.printf("=== Calling %s from job: %s ===\n", jr.job.Uses, jr.name)
+	}
+	called := &workflowRun{
+		workflow:  workflow,
+		eventName: run.eventName,
+		event:     run.event,
+		workspace: run.workspace,
+		repo:      run.repo,
+		actor:     run.actor,
+		secrets:   secrets,
+		vars:      run.vars,
+		inputs:    inputs,
+		pool:      run.pool,
+		source:    source,
+		caller:    jr,
+		depth:     run.depth + 1,
+		ctx:       runCtx,
+	}
+	results := e.execute(called, graph)
+	if jr.result != nil {
+		jr.result.Jobs = called.results(graph)
+	}
+
+	run.mu.Lock()
+	run.annotations = append(run.annotations, called.annotations...)
+	run.mu.Unlock()
+
+	outputs, err := called.callOutputs(jr, call.Outputs, results)
+	if err != nil {
+		return err
+	}
+	run.setOutputs(jr.id, outputs)
+
+	var failed []string
+	for _, jobID := range graph.order {
+		if results[jobID] == resultFailure {
+			failed = append(failed, jobID)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("called workflow job(s) failed: %s", strings.Join(failed, ", "))
+	}
+	if runCtx.Err() != nil {
+		return errJobCancelled
+	}
+	return nil
+}
+
+// resolveCalledWorkflow parses the reusable workflow uses refers to and
+// returns it along with the directory its own local references resolve
+// against. Local workflows are referenced as ./path/to/workflow.yml and
+// remote ones as owner/repo/path/to/workflow.yml@ref.
+func (e *Executor) resolveCalledWorkflow(run *workflowRun, uses string) (*parser.Workflow, string, error) {
+	if strings.HasPrefix(uses, "./") {
+		workflow, err := parser.ParseWorkflow(filepath.Join(run.source, uses))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to load %s: %w", uses, err)
+		}
+		return workflow, run.source, nil
+	}
+
+	repo, path, ref, err := parseWorkflowRef(uses)
+	if err != nil {
+		return nil, "", err
+	}
+	dir, err := e.cachedWorkflowRepo(repo, ref)
+	if err != nil {
+		return nil, "", err
+	}
+	workflow, err := parser.ParseWorkflow(filepath.Join(dir, path))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load %s: %w", uses, err)
+	}
+	return workflow, dir, nil
+}
+
+// parseWorkflowRef splits a remote reusable workflow reference of the form
+// owner/repo/path/to/workflow.yml@ref. Every part must be a plain relative
+// path, as they locate the checkout and are passed to git.
+func parseWorkflowRef(uses string) (repo, path, ref string, err error) {
+	target, ref, ok := strings.Cut(uses, "@")
+	parts := strings.SplitN(target, "/", 3)
+	if !ok || len(parts) < 3 || !isPlainPath(parts[0]) || !isPlainPath(parts[1]) || !isPlainPath(parts[2]) || !isPlainPath(ref) {
+		return "", "", "", fmt.Errorf("invalid reusable workflow reference %q (use ./path/to/workflow.yml or owner/repo/path/to/workflow.yml@ref)", uses)
+	}
+	return parts[0] + "/" + parts[1], parts[2], ref, nil
+}
+
+// isPlainPath reports whether p is a relative slash-separated path whose
+// elements are neither empty nor . or .., and which does not start with '-'.
+func isPlainPath(p string) bool {
+	if p == "" || strings.HasPrefix(p, "-") || strings.ContainsRune(p, '\\') {
+		return false
+	}
+	for _, elem := range strings.Split(p, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// shaPattern matches a full commit SHA.
+var shaPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// cachedWorkflowRepo returns a checkout of ref of a remote repository from
+// the user cache directory. Checkouts are keyed by commit: a commit SHA is
+// fetched the first time it is used, while branches and tags are fetched
+// again by each executor so that they never go stale. When that fetch
+// fails, the commit the ref last resolved to is used.
+func (e *Executor) cachedWorkflowRepo(repo, ref string) (string, error) {
+	e.fetchMu.Lock()
+	defer e.fetchMu.Unlock()
+	key := repo + "@" + ref
+	if dir, ok := e.fetched[key]; ok {
+		return dir, nil
+	}
+
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	base := filepath.Join(cache, "ici", "workflows", filepath.FromSlash(repo))
+	// refs records the commit each branch or tag last resolved to
+	pointer := filepath.Join(base, ".refs", filepath.FromSlash(ref))
+
+	var dir string
+	if shaPattern.MatchString(ref) {
+		dir = filepath.Join(base, ref)
+		if _, err := os.Stat(dir); err == nil {
+			if e.verbose {
+				e.printf("Using cached %s@%s\n", repo, ref)
+			}
+		} else if _, err := e.fetchIntoCache(repo, ref, base); err != nil {
+			return "", err
+		}
+	} else {
+		sha, err := e.fetchIntoCache(repo, ref, base)
+		if err != nil {
+			data, readErr := os.ReadFile(pointer)
+			sha = strings.TrimSpace(string(data))
+			if readErr != nil || !shaPattern.MatchString(sha) {
+				return "", err
+			}
+			if _, statErr := os.Stat(filepath.Join(base, sha)); statErr != nil {
+				return "", err
+			}
+			e.printf("Warning: using the cached %s@%s (%s): %v\n", repo, ref, sha, err)
+		} else {
+			if err := os.MkdirAll(filepath.Dir(pointer), 0o755); err != nil {
+				return "", fmt.Errorf("failed to create cache directory: %w", err)
+			}
+			if err := os.WriteFile(pointer, []byte(sha+"\n"), 0o644); err != nil {
+				return "", fmt.Errorf("failed to cache %s@%s: %w", repo, ref, err)
+			}
+		}
+		dir = filepath.Join(base, sha)
+	}
+
+	if e.fetched == nil {
+		e.fetched = make(map[string]string)
+	}
+	e.fetched[key] = dir
+	return dir, nil
+}
+
+// fetchIntoCache fetches ref of a remote repository into the cache
+// directory base, under the commit it resolves to, and returns that commit.
+// The checkout is fetched next to its final location and moved into place,
+// so that concurrent runs never see a partial checkout.
+func (e *Executor) fetchIntoCache(repo, ref, base string) (string, error) {
+	if err := os.MkdirAll(base, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	tmp, err := os.MkdirTemp(base, ".fetch-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	e.printf("Fetching %s@%s\n", repo, ref)
+	if err := git.FetchRef(githubURL+"/"+repo, ref, tmp); err != nil {
+		return "", err
+	}
+	sha, err := git.ResolveCommit(tmp, "HEAD")
+	if err != nil {
+		return "", err
+	}
+	// a checkout of the same commit that is already in place is kept
+	if err := os.Rename(tmp, filepath.Join(base, sha)); err != nil {
+		if _, statErr := os.Stat(filepath.Join(base, sha)); statErr != nil {
+			return "", fmt.Errorf("failed to cache %s@%s: %w", repo, ref, err)
+		}
+	}
+	return sha, nil
+}
+
+// callSecrets returns the secrets context of a called workflow: the
+// caller's secrets for `secrets: inherit`, otherwise the secrets the job
+// passes, which the called workflow must declare.
+func callSecrets(run *workflowRun, given *parser.JobSecrets, declared map[string]parser.CallSecret, ctx *expr.Context) (map[string]string, error) {
+	secrets := make(map[string]string)
+	switch {
+	case given == nil:
+	case given.Inherit:
+		for k, v := range run.secrets {
+			secrets[k] = v
+		}
+	default:
+		values, err := expr.InterpolateMap(given.Values, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate secrets: %w", err)
+		}
+		var unknown []string
+		for k, v := range values {
+			if _, ok := declared[k]; !ok {
+				unknown = append(unknown, k)
+			}
+			secrets[k] = v
+		}
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			return nil, fmt.Errorf("unexpected secret(s): %s", strings.Join(unknown, ", "))
+		}
+	}
+
+	var missing []string
+	for name, s := range declared {
+		if _, ok := secrets[name]; s.Required && !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("secret(s) %s are required", strings.Join(missing, ", "))
+	}
+	return secrets, nil
+}
+
+// callOutputs evaluates the outputs a called workflow declares, which refer
+// to its jobs through the jobs context.
+func (r *workflowRun) callOutputs(caller *jobRun, declared map[string]parser.CallOutput, results map[string]jobResult) (map[string]string, error) {
+	jobs := make(map[string]interface{}, len(results))
+	for id, result := range results {
+		jobs[id] = map[string]interface{}{
+			"result":  string(result),
+			"outputs": r.jobOutputs(id),
+		}
+	}
+	ctx := r.newJobContext(&jobRun{id: caller.id}, nil)
+	ctx.Values["jobs"] = jobs
+
+	outputs := make(map[string]string, len(declared))
+	for name, o := range declared {
+		v, err := expr.Interpolate(o.Value, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate output %s: %w", name, err)
+		}
+		outputs[name] = v
+	}
+	return outputs, nil
+}
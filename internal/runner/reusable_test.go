@@ -0,0 +1,205 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aykay76/ici/internal/git"
+	"github.com/aykay76/ici/internal/parser"
+)
+
+// calledWorkflow is a reusable workflow whose only job is skipped, so it
+// runs without containers.
+const calledWorkflow = `
+on:
+  workflow_call:
+    inputs:
+      version:
+        type: string
+        required: true
+      publish:
+        type: boolean
+        default: false
+    secrets:
+      token:
+        required: true
+    outputs:
+      summary:
+        value: ${{ inputs.version }}/${{ inputs.publish }}/${{ jobs.build.result }}
+jobs:
+  build:
+    if: false
+    runs-on: ubuntu-latest
+    steps:
+      - run: echo building
+`
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func callerRun(workspace string) *workflowRun {
+	return &workflowRun{
+		workflow:  &parser.Workflow{},
+		eventName: "push",
+		workspace: workspace,
+		source:    workspace,
+		repo:      &git.RepoInfo{},
+		secrets:   map[string]string{"DEPLOY_TOKEN": "s3cret"},
+		pool:      newWorkerPool(1),
+	}
+}
+
+func TestRunCalledWorkflow_Local(t *testing.T) {
+	ws := t.TempDir()
+	writeFile(t, filepath.Join(ws, ".github", "workflows", "build.yml"), calledWorkflow)
+	e := NewExecutorWithOptions(Options{Workspace: ws})
+
+	run := callerRun(ws)
+	jr := &jobRun{id: "call", name: "call", job: parser.Job{
+		Uses:    "./.github/workflows/build.yml",
+		With:    map[string]string{"version": "1.2.0", "publish": "true"},
+		Secrets: &parser.JobSecrets{Values: map[string]string{"token": "${{ secrets.DEPLOY_TOKEN }}"}},
+	}}
+	jr.result = jr.newResult(resultSuccess, nil)
+	if err := e.runCalledWorkflow(context.Background(), run, jr); err != nil {
+		t.Fatalf("runCalledWorkflow failed: %v", err)
+	}
+	if got := run.jobOutputs("call")["summary"]; got != "1.2.0/true/skipped" {
+		t.Fatalf("unexpected summary output: %v", got)
+	}
+	if jobs := jr.result.Jobs; len(jobs) != 1 || jobs[0].Name != "call / build" || jobs[0].SkipReason != "if: false evaluated to false" {
+		t.Fatalf("expected the called job's result, got %+v", jobs)
+	}
+
+	tests := []struct {
+		job  parser.Job
+		want string
+	}{
+		{parser.Job{Uses: "./.github/workflows/build.yml", Secrets: &parser.JobSecrets{Inherit: true}}, "version is required"},
+		{parser.Job{Uses: "./.github/workflows/build.yml", With: map[string]string{"version": "1", "publish": "maybe"}, Secrets: &parser.JobSecrets{Inherit: true}}, "not a boolean"},
+		{parser.Job{Uses: "./.github/workflows/build.yml", With: map[string]string{"version": "1"}}, "token are required"},
+		{parser.Job{Uses: "./.github/workflows/build.yml", With: map[string]string{"version": "1"}, Secrets: &parser.JobSecrets{Values: map[string]string{"token": "x", "other": "y"}}}, "unexpected secret(s): other"},
+		{parser.Job{Uses: "./.github/workflows/missing.yml"}, "failed to load"},
+		{parser.Job{Uses: "octo/pipelines"}, "invalid reusable workflow reference"},
+	}
+	for _, tt := range tests {
+		err := e.runCalledWorkflow(context.Background(), callerRun(ws), &jobRun{id: "call", name: "call", job: tt.job})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("runCalledWorkflow(%+v) error = %v, want %q", tt.job, err, tt.want)
+		}
+	}
+}
+
+func TestRunCalledWorkflow_Remote(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	remotes := t.TempDir()
+	repo := filepath.Join(remotes, "octo", "pipelines")
+	writeFile(t, filepath.Join(repo, ".github", "workflows", "build.yml"), calledWorkflow)
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"add", "."},
+		{"-c", "user.email=test@example.com", "-c", "user.name=test", "commit", "-q", "-m", "initial"},
+		{"tag", "v1"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	saved := githubURL
+	githubURL = "file://" + remotes
+	defer func() { githubURL = saved }()
+
+	e := NewExecutorWithOptions(Options{})
+	jr := &jobRun{id: "call", name: "call", job: parser.Job{
+		Uses:    "octo/pipelines/.github/workflows/build.yml@v1",
+		With:    map[string]string{"version": "2.0.0"},
+		Secrets: &parser.JobSecrets{Inherit: true},
+	}}
+	run := callerRun(t.TempDir())
+	run.secrets["token"] = "abc"
+	if err := e.runCalledWorkflow(context.Background(), run, jr); err != nil {
+		t.Fatalf("runCalledWorkflow failed: %v", err)
+	}
+	if got := run.jobOutputs("call")["summary"]; got != "2.0.0/false/skipped" {
+		t.Fatalf("unexpected summary output: %v", got)
+	}
+
+	// a moved branch is fetched again by the next run
+	jr.job.Uses = "octo/pipelines/.github/workflows/build.yml@main"
+	if err := NewExecutorWithOptions(Options{}).runCalledWorkflow(context.Background(), run, jr); err != nil {
+		t.Fatalf("runCalledWorkflow failed: %v", err)
+	}
+	writeFile(t, filepath.Join(repo, ".github", "workflows", "build.yml"), strings.Replace(calledWorkflow, "value: ${{", "value: v2/${{", 1))
+	for _, args := range [][]string{
+		{"add", "."},
+		{"-c", "user.email=test@example.com", "-c", "user.name=test", "commit", "-q", "-m", "v2"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	if err := NewExecutorWithOptions(Options{}).runCalledWorkflow(context.Background(), run, jr); err != nil {
+		t.Fatalf("runCalledWorkflow failed: %v", err)
+	}
+	if got := run.jobOutputs("call")["summary"]; got != "v2/2.0.0/false/skipped" {
+		t.Fatalf("expected the moved branch to be used, got %v", got)
+	}
+
+	// without the remote the commit the branch last resolved to is used
+	githubURL = "file:///nonexistent"
+	if err := NewExecutorWithOptions(Options{}).runCalledWorkflow(context.Background(), run, jr); err != nil {
+		t.Fatalf("expected the cached workflow to be used: %v", err)
+	}
+	if got := run.jobOutputs("call")["summary"]; got != "v2/2.0.0/false/skipped" {
+		t.Fatalf("expected the cached commit to be used, got %v", got)
+	}
+	jr.job.Uses = "octo/pipelines/.github/workflows/build.yml@never-fetched"
+	if err := NewExecutorWithOptions(Options{}).runCalledWorkflow(context.Background(), run, jr); err == nil {
+		t.Fatalf("expected an uncached ref to fail without the remote")
+	}
+}
+
+func TestParseWorkflowRef(t *testing.T) {
+	repo, path, ref, err := parseWorkflowRef("octo/pipelines/.github/workflows/build.yml@v1")
+	if err != nil || repo != "octo/pipelines" || path != ".github/workflows/build.yml" || ref != "v1" {
+		t.Fatalf("unexpected result: %s %s %s %v", repo, path, ref, err)
+	}
+	for _, uses := range []string{
+		"octo/pipelines@v1",
+		"octo/pipelines/build.yml",
+		"/x/build.yml@v1",
+		"octo/../.github/workflows/build.yml@v1",
+		"octo/pipelines/../../build.yml@v1",
+		"octo/pipelines//build.yml@v1",
+		"octo/pipelines/build.yml@../../x",
+		"octo/pipelines/build.yml@/tmp/x",
+		"octo/pipelines/build.yml@--upload-pack=x",
+	} {
+		if _, _, _, err := parseWorkflowRef(uses); err == nil {
+			t.Errorf("expected %q to be rejected", uses)
+		}
+	}
+}
+
+func TestContainerName_CalledWorkflow(t *testing.T) {
+	caller := &jobRun{id: "call", matrix: &matrixCombination{}, index: 1}
+	jr := &jobRun{id: "build", caller: caller}
+	if got := jr.containerName(); got != "call-2-build" {
+		t.Fatalf("unexpected container name: %s", got)
+	}
+}
@@ -0,0 +1,98 @@
+package runner
+
+import (
+	"sort"
+)
+
+// scheduler runs the jobs of a dependency graph, starting each job as soon as
+// the jobs it needs have finished. How many jobs actually execute at once is
+// limited by the workerPool the jobs acquire before starting containers.
+type scheduler struct {
+	graph *jobGraph
+	// run executes a single job and reports its result. results holds the
+	// results of every job that finished before it started, so run can
+	// decide whether the job should be skipped.
+	run func(jobID string, results map[string]jobResult) jobResult
+}
+
+type finishedJob struct {
+	id     string
+	result jobResult
+}
+
+// execute runs every job in the graph and returns the result of each one.
+func (s *scheduler) execute() map[string]jobResult {
+	position := make(map[string]int, len(s.graph.order))
+	pending := make(map[string]int, len(s.graph.order))
+	var queue []string
+	for i, id := range s.graph.order {
+		position[id] = i
+		pending[id] = len(s.graph.needs[id])
+		if pending[id] == 0 {
+			queue = append(queue, id)
+		}
+	}
+
+	results := make(map[string]jobResult, len(s.graph.order))
+	// release marks a job as finished and queues the dependents whose needs
+	// are now all resolved, keeping the queue in topological order.
+	release := func(id string) {
+		for _, dep := range s.graph.dependents[id] {
+			pending[dep]--
+			if pending[dep] == 0 {
+				queue = append(queue, dep)
+			}
+		}
+		sort.SliceStable(queue, func(i, j int) bool {
+			return position[queue[i]] < position[queue[j]]
+		})
+	}
+
+	done := make(chan finishedJob)
+	running := 0
+	for {
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			running++
+			snapshot := make(map[string]jobResult, len(results))
+			for k, v := range results {
+				snapshot[k] = v
+			}
+			go func(id string) {
+				done <- finishedJob{id: id, result: s.run(id, snapshot)}
+			}(id)
+		}
+
+		if running == 0 {
+			break
+		}
+
+		f := <-done
+		running--
+		results[f.id] = f.result
+		release(f.id)
+	}
+
+	return results
+}
+
+// workerPool limits how many job instances run at the same time.
+type workerPool chan struct{}
+
+func newWorkerPool(size int) workerPool {
+	if size < 1 {
+		size = 1
+	}
+	return make(workerPool, size)
+}
+
+// acquire blocks until a worker is available.
+func (p workerPool) acquire() {
+	p <- struct{}{}
+}
+
+// release returns a worker to the pool.
+func (p workerPool) release() {
+	<-p
+}
@@ -0,0 +1,162 @@
+package runner
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aykay76/ici/internal/expr"
+	"github.com/aykay76/ici/internal/parser"
+)
+
+func TestScheduler_RespectsParallelLimit(t *testing.T) {
+	jobs := map[string]parser.Job{
+		"a": {}, "b": {}, "c": {}, "d": {},
+		"e": {Needs: []interface{}{"a", "b", "c", "d"}},
+	}
+	g, err := buildJobGraph(jobs)
+	if err != nil {
+		t.Fatalf("buildJobGraph failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+	var order []string
+	pool := newWorkerPool(2)
+	s := &scheduler{
+		graph: g,
+		run: func(jobID string, _ map[string]jobResult) jobResult {
+			pool.acquire()
+			defer pool.release()
+
+			mu.Lock()
+			active++
+			if active > maxActive {
+				maxActive = active
+			}
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			active--
+			order = append(order, jobID)
+			mu.Unlock()
+			return resultSuccess
+		},
+	}
+	results := s.execute()
+
+	if maxActive != 2 {
+		t.Fatalf("expected at most 2 concurrent jobs, got %d", maxActive)
+	}
+	if order[len(order)-1] != "e" {
+		t.Fatalf("expected e to run last, got order %v", order)
+	}
+	for id, r := range results {
+		if r != resultSuccess {
+			t.Fatalf("expected %s to succeed, got %s", id, r)
+		}
+	}
+}
+
+func TestScheduler_SkipsDependentsOfFailedJobs(t *testing.T) {
+	jobs := map[string]parser.Job{
+		"build":   {},
+		"test":    {Needs: "build"},
+		"deploy":  {Needs: "test"},
+		"cleanup": {Needs: "deploy", If: "always()"},
+		"lint":    {},
+	}
+	g, err := buildJobGraph(jobs)
+	if err != nil {
+		t.Fatalf("buildJobGraph failed: %v", err)
+	}
+
+	s := &scheduler{
+		graph: g,
+		run: func(jobID string, results map[string]jobResult) jobResult {
+			ok, err := evaluateCondition(jobs[jobID].If, &expr.Context{}, needsStatus(g, jobID, results))
+			if err != nil {
+				t.Errorf("evaluateCondition failed for %s: %v", jobID, err)
+			}
+			if !ok {
+				return resultSkipped
+			}
+			if jobID == "build" {
+				return resultFailure
+			}
+			return resultSuccess
+		},
+	}
+	results := s.execute()
+
+	want := map[string]jobResult{
+		"build":   resultFailure,
+		"test":    resultSkipped,
+		"deploy":  resultSkipped,
+		"cleanup": resultSuccess,
+		"lint":    resultSuccess,
+	}
+	for id, r := range want {
+		if results[id] != r {
+			t.Fatalf("expected %s to be %s, got %s", id, r, results[id])
+		}
+	}
+}
+
+func TestPrefixWriter_PrefixesCompleteLines(t *testing.T) {
+	var buf bytes.Buffer
+	var mu sync.Mutex
+	w := newPrefixWriter(&buf, &mu, "[job] ")
+
+	_, _ = w.Write([]byte("hello\nwor"))
+	_, _ = w.Write([]byte("ld\npartial"))
+	if got := buf.String(); got != "[job] hello\n[job] world\n" {
+		t.Fatalf("unexpected output before flush: %q", got)
+	}
+
+	_ = w.Flush()
+	if got := buf.String(); got != "[job] hello\n[job] world\n[job] partial\n" {
+		t.Fatalf("unexpected output after flush: %q", got)
+	}
+}
+
+func TestAggregateResults(t *testing.T) {
+	tests := []struct {
+		results []jobResult
+		want    jobResult
+	}{
+		{[]jobResult{resultSuccess, resultSuccess}, resultSuccess},
+		{[]jobResult{resultSuccess, resultCancelled}, resultCancelled},
+		{[]jobResult{resultCancelled, resultFailure, resultSuccess}, resultFailure},
+		// the jobs of a run: skipped jobs do not affect the result
+		{[]jobResult{resultSuccess, resultSkipped}, resultSuccess},
+		{[]jobResult{resultSkipped, resultCancelled, resultSuccess}, resultCancelled},
+	}
+	for _, tt := range tests {
+		if got := aggregateResults(tt.results); got != tt.want {
+			t.Errorf("aggregateResults(%v) = %s, want %s", tt.results, got, tt.want)
+		}
+	}
+}
+
+func TestRunOutcome(t *testing.T) {
+	order := []string{"build", "test", "deploy"}
+	tests := []struct {
+		results map[string]jobResult
+		want    jobResult
+		err     string
+	}{
+		{map[string]jobResult{"build": resultSuccess, "test": resultSuccess, "deploy": resultSkipped}, resultSuccess, ""},
+		{map[string]jobResult{"build": resultSuccess, "test": resultCancelled, "deploy": resultSkipped}, resultCancelled, "job(s) cancelled: test"},
+		{map[string]jobResult{"build": resultFailure, "test": resultCancelled, "deploy": resultFailure}, resultFailure, "job(s) failed: build, deploy"},
+	}
+	for _, tt := range tests {
+		got, err := runOutcome(order, tt.results)
+		if got != tt.want || errorString(err) != tt.err {
+			t.Errorf("runOutcome(%v) = %s, %v, want %s, %q", tt.results, got, err, tt.want, tt.err)
+		}
+	}
+}
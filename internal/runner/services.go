@@ -0,0 +1,98 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aykay76/ici/internal/container"
+	"github.com/aykay76/ici/internal/expr"
+)
+
+// serviceHealthTimeout bounds how long a job waits for its service
+// containers to report healthy.
+var serviceHealthTimeout = 5 * time.Minute
+
+// jobServices are the network and service containers of a running job.
+type jobServices struct {
+	mgr     container.Runtime
+	network string
+	// ids holds the container ids of the started services.
+	ids []string
+	// context is the job.services context, by service id.
+	context map[string]interface{}
+}
+
+// startServices creates a network for a job and starts its service
+// containers on it, each reachable by its key as host name. It waits for
+// the services' health checks to pass, returning errJobCancelled if runCtx
+// is cancelled meanwhile. The returned jobServices must be stopped even when
+// an error is returned.
+func startServices(runCtx context.Context, mgr container.Runtime, jr *jobRun, ctx *expr.Context, stdout io.Writer) (*jobServices, error) {
+	s := &jobServices{mgr: mgr, context: make(map[string]interface{})}
+	if len(jr.job.Services) == 0 {
+		return s, nil
+	}
+
+	network := "ici-" + jr.containerName()
+	if err := mgr.CreateNetwork(network); err != nil {
+		return s, err
+	}
+	s.network = network
+
+	ids := make(map[string]string, len(jr.job.Services))
+	for _, id := range sortedKeys(jr.job.Services) {
+		svc := jr.job.Services[id]
+		spec, err := evaluateContainer(&svc, ctx)
+		if err != nil {
+			return s, fmt.Errorf("failed to evaluate service %s: %w", id, err)
+		}
+		cfg := &container.ContainerConfig{
+			Env:            envList(spec.env),
+			Network:        network,
+			NetworkAliases: []string{id},
+			ImageCommand:   true,
+		}
+		if err := spec.prepare(mgr, cfg); err != nil {
+			return s, fmt.Errorf("failed to prepare service %s: %w", id, err)
+		}
+		fmt.Fprintf(stdout, "Starting service %s (%s)\n", id, spec.image)
+		containerID, err := mgr.CreateContainerWithConfig(spec.image, jr.containerName()+"-"+id, cfg)
+		if err != nil {
+			return s, fmt.Errorf("failed to start service %s: %w", id, err)
+		}
+		s.ids = append(s.ids, containerID)
+		ids[id] = containerID
+	}
+
+	for _, id := range sortedKeys(jr.job.Services) {
+		if err := mgr.WaitHealthy(runCtx, ids[id], serviceHealthTimeout); err != nil {
+			if runCtx.Err() != nil {
+				return s, errJobCancelled
+			}
+			return s, fmt.Errorf("service %s: %w", id, err)
+		}
+		ports, err := mgr.PublishedPorts(ids[id])
+		if err != nil {
+			return s, fmt.Errorf("service %s: %w", id, err)
+		}
+		s.context[id] = map[string]interface{}{
+			"id":      ids[id],
+			"network": network,
+			"ports":   stringMap(ports),
+		}
+	}
+	return s, nil
+}
+
+// stop removes the service containers and the network.
+func (s *jobServices) stop() {
+	for _, id := range s.ids {
+		_ = s.mgr.StopContainer(id)
+		_ = s.mgr.RemoveContainer(id)
+	}
+	if s.network != "" {
+		_ = s.mgr.RemoveNetwork(s.network)
+	}
+}
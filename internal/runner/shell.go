@@ -0,0 +1,90 @@
+package runner
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aykay76/ici/internal/container"
+	"github.com/aykay76/ici/internal/expr"
+	"github.com/aykay76/ici/internal/parser"
+)
+
+// shellTemplates maps the shell names accepted by `shell:` to the command
+// line GitHub runs a step's script with; {0} stands for the script path.
+var shellTemplates = map[string]string{
+	"bash":       "bash --noprofile --norc -eo pipefail {0}",
+	"sh":         "sh -e {0}",
+	"python":     "python {0}",
+	"pwsh":       `pwsh -command ". '{0}'"`,
+	"powershell": `powershell -command ". '{0}'"`,
+}
+
+// defaultShell runs steps that do not set a shell with bash when the image
+// has it and with sh otherwise, like GitHub does on Linux runners.
+const defaultShell = `sh -c 'if command -v bash >/dev/null 2>&1; then exec bash -e "$1"; else exec sh -e "$1"; fi' sh {0}`
+
+// resolveShell returns the command line for a `shell:` value, which is a
+// shell name or a custom command line containing {0}.
+func resolveShell(shell string) (string, error) {
+	shell = strings.TrimSpace(shell)
+	if shell == "" {
+		return defaultShell, nil
+	}
+	if t, ok := shellTemplates[shell]; ok {
+		return t, nil
+	}
+	if strings.Contains(shell, "{0}") {
+		return shell, nil
+	}
+	return "", fmt.Errorf("unsupported shell %q (use bash, sh, python, pwsh, powershell or a command containing {0})", shell)
+}
+
+// runDefaults returns defaults.run of a job, falling back to those of the
+// workflow for the settings the job does not set.
+func runDefaults(workflow *parser.Workflow, job parser.Job) parser.RunDefaults {
+	var out parser.RunDefaults
+	for _, d := range []*parser.Defaults{job.Defaults, workflow.Defaults} {
+		if d == nil || d.Run == nil {
+			continue
+		}
+		if out.Shell == "" {
+			out.Shell = d.Run.Shell
+		}
+		if out.WorkingDirectory == "" {
+			out.WorkingDirectory = d.Run.WorkingDirectory
+		}
+	}
+	return out
+}
+
+// runExecOptions returns how a run step executes: its shell, working
+// directory and env. Settings the step does not make come from defaults.
+func runExecOptions(step parser.Step, defaults parser.RunDefaults, ctx *expr.Context, env map[string]string) (*container.ExecOptions, error) {
+	shell := step.Shell
+	if shell == "" {
+		shell = defaults.Shell
+	}
+	shell, err := expr.Interpolate(shell, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate shell: %w", err)
+	}
+	template, err := resolveShell(shell)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := step.WorkingDirectory
+	if dir == "" {
+		dir = defaults.WorkingDirectory
+	}
+	dir, err = expr.Interpolate(dir, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate working-directory: %w", err)
+	}
+	if dir != "" && !path.IsAbs(dir) {
+		dir = path.Join(containerWorkspace, dir)
+	}
+
+	return &container.ExecOptions{Shell: template, WorkDir: dir, Env: envList(env)}, nil
+}
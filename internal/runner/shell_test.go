@@ -0,0 +1,81 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aykay76/ici/internal/expr"
+	"github.com/aykay76/ici/internal/parser"
+)
+
+func TestResolveShell(t *testing.T) {
+	tests := map[string]string{
+		"":                   defaultShell,
+		"bash":               "bash --noprofile --norc -eo pipefail {0}",
+		"sh":                 "sh -e {0}",
+		"python":             "python {0}",
+		"pwsh":               `pwsh -command ". '{0}'"`,
+		"perl -w {0}":        "perl -w {0}",
+		" bash ":             "bash --noprofile --norc -eo pipefail {0}",
+		"node --no-warn {0}": "node --no-warn {0}",
+	}
+	for shell, want := range tests {
+		got, err := resolveShell(shell)
+		if err != nil {
+			t.Fatalf("resolveShell(%q) failed: %v", shell, err)
+		}
+		if got != want {
+			t.Fatalf("resolveShell(%q) = %q, want %q", shell, got, want)
+		}
+	}
+	if _, err := resolveShell("cmd"); err == nil {
+		t.Fatalf("expected an unsupported shell to fail")
+	}
+}
+
+func TestRunDefaults_JobOverridesWorkflow(t *testing.T) {
+	wf := &parser.Workflow{Defaults: &parser.Defaults{Run: &parser.RunDefaults{Shell: "sh", WorkingDirectory: "app"}}}
+	job := parser.Job{Defaults: &parser.Defaults{Run: &parser.RunDefaults{Shell: "bash"}}}
+
+	got := runDefaults(wf, job)
+	if got.Shell != "bash" || got.WorkingDirectory != "app" {
+		t.Fatalf("unexpected defaults: %+v", got)
+	}
+	if got := runDefaults(&parser.Workflow{}, parser.Job{}); got != (parser.RunDefaults{}) {
+		t.Fatalf("expected empty defaults, got %+v", got)
+	}
+}
+
+func TestRunExecOptions(t *testing.T) {
+	ctx := &expr.Context{Values: map[string]interface{}{
+		"matrix": map[string]interface{}{"dir": "svc"},
+	}}
+	defaults := parser.RunDefaults{Shell: "sh", WorkingDirectory: "app"}
+
+	step := parser.Step{WorkingDirectory: "${{ matrix.dir }}"}
+	opts, err := runExecOptions(step, defaults, ctx, map[string]string{"B": "2", "A": "1"})
+	if err != nil {
+		t.Fatalf("runExecOptions failed: %v", err)
+	}
+	if opts.Shell != "sh -e {0}" {
+		t.Fatalf("expected the default shell, got %q", opts.Shell)
+	}
+	if opts.WorkDir != "/github/workspace/svc" {
+		t.Fatalf("unexpected workdir: %q", opts.WorkDir)
+	}
+	if !reflect.DeepEqual(opts.Env, []string{"A=1", "B=2"}) {
+		t.Fatalf("unexpected env: %v", opts.Env)
+	}
+
+	opts, err = runExecOptions(parser.Step{Shell: "python", WorkingDirectory: "/tmp"}, defaults, ctx, nil)
+	if err != nil {
+		t.Fatalf("runExecOptions failed: %v", err)
+	}
+	if opts.Shell != "python {0}" || opts.WorkDir != "/tmp" {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+
+	if _, err := runExecOptions(parser.Step{Shell: "fish"}, parser.RunDefaults{}, ctx, nil); err == nil {
+		t.Fatalf("expected an unsupported shell to fail")
+	}
+}
@@ -0,0 +1,54 @@
+package runner
+
+import (
+	"fmt"
+
+	"github.com/aykay76/ici/internal/container"
+)
+
+// containerWorkspace is where the workspace is available inside job
+// containers, matching GITHUB_WORKSPACE on GitHub-hosted runners.
+const containerWorkspace = "/github/workspace"
+
+// Workspace modes select how the host workspace reaches job containers.
+const (
+	// WorkspaceBind bind-mounts the host workspace, so changes made by
+	// steps are visible on the host.
+	WorkspaceBind = "bind"
+	// WorkspaceCopy copies the host workspace into each job container, so
+	// steps cannot modify the host.
+	WorkspaceCopy = "copy"
+)
+
+// ValidWorkspaceMode reports whether mode is a supported workspace mode.
+func ValidWorkspaceMode(mode string) bool {
+	return mode == WorkspaceBind || mode == WorkspaceCopy
+}
+
+// configureWorkspace adds the workspace mount, working directory and
+// GITHUB_WORKSPACE variable to a job container configuration.
+func (e *Executor) configureWorkspace(cfg *container.ContainerConfig) error {
+	switch e.workspaceMode {
+	case WorkspaceBind:
+		cfg.Volumes = append(cfg.Volumes, fmt.Sprintf("%s:%s", e.workspace, containerWorkspace))
+	case WorkspaceCopy:
+		// populated by populateWorkspace once the container is running
+	default:
+		return fmt.Errorf("unsupported workspace mode %q (use %s or %s)", e.workspaceMode, WorkspaceBind, WorkspaceCopy)
+	}
+	cfg.WorkDir = containerWorkspace
+	cfg.Env = append(cfg.Env, "GITHUB_WORKSPACE="+containerWorkspace)
+	return nil
+}
+
+// populateWorkspace copies the host workspace into a running job container
+// when the workspace is not bind-mounted.
+func (e *Executor) populateWorkspace(mgr container.Runtime, containerID string) error {
+	if e.workspaceMode != WorkspaceCopy {
+		return nil
+	}
+	if err := mgr.CopyToContainer(containerID, e.workspace, containerWorkspace); err != nil {
+		return fmt.Errorf("failed to copy workspace: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aykay76/ici/internal/container"
+)
+
+func TestConfigureWorkspace(t *testing.T) {
+	e := NewExecutorWithOptions(Options{Workspace: "/src/repo"})
+	cfg := &container.ContainerConfig{}
+	if err := e.configureWorkspace(cfg); err != nil {
+		t.Fatalf("configureWorkspace failed: %v", err)
+	}
+	if !reflect.DeepEqual(cfg.Volumes, []string{"/src/repo:/github/workspace"}) {
+		t.Fatalf("unexpected volumes: %v", cfg.Volumes)
+	}
+	if cfg.WorkDir != "/github/workspace" {
+		t.Fatalf("unexpected workdir: %s", cfg.WorkDir)
+	}
+	if !reflect.DeepEqual(cfg.Env, []string{"GITHUB_WORKSPACE=/github/workspace"}) {
+		t.Fatalf("unexpected env: %v", cfg.Env)
+	}
+
+	e = NewExecutorWithOptions(Options{Workspace: "/src/repo", WorkspaceMode: WorkspaceCopy})
+	cfg = &container.ContainerConfig{}
+	if err := e.configureWorkspace(cfg); err != nil {
+		t.Fatalf("configureWorkspace failed: %v", err)
+	}
+	if len(cfg.Volumes) != 0 || cfg.WorkDir != "/github/workspace" {
+		t.Fatalf("copy mode should not mount the workspace: %+v", cfg)
+	}
+
+	e = NewExecutorWithOptions(Options{WorkspaceMode: "overlay"})
+	if err := e.configureWorkspace(&container.ContainerConfig{}); err == nil {
+		t.Fatalf("expected unsupported workspace mode to fail")
+	}
+}